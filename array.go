@@ -0,0 +1,61 @@
+package esl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FreeSWITCH encodes the lists of values in the headers and channel variables
+// as ARRAY::first|:second|:third.
+const (
+	arrayPrefix    = "ARRAY::"
+	arraySeparator = "|:"
+)
+
+// ErrArraySeparator is returned when an array value contains the "|:"
+// separator: FreeSWITCH has no escaping for it, so such a value cannot be
+// encoded without being split into two.
+var ErrArraySeparator = errors.New("array value contains the separator")
+
+// Array encodes the values as a FreeSWITCH array header value, which can be
+// used in the headers of SendMsg or NewEvent:
+//
+//	uuids, err := esl.Array(uuid1, uuid2)
+//
+// It returns ErrArraySeparator if any of the values contains "|:".
+func Array(values ...string) (string, error) {
+	var b strings.Builder
+
+	b.WriteString(arrayPrefix)
+
+	for i, v := range values {
+		if strings.Contains(v, arraySeparator) {
+			return "", fmt.Errorf("%w: %q", ErrArraySeparator, v)
+		}
+
+		if i > 0 {
+			b.WriteString(arraySeparator)
+		}
+
+		b.WriteString(v)
+	}
+
+	return b.String(), nil
+}
+
+// isArray reports whether the header value is an encoded array.
+func isArray(value string) bool {
+	return strings.HasPrefix(value, arrayPrefix)
+}
+
+// splitArray returns the values of the header: the elements of the
+// array, or the value itself if it is not an array.
+func splitArray(value string) []string {
+	list, ok := strings.CutPrefix(value, arrayPrefix)
+	if !ok {
+		return []string{value}
+	}
+
+	return strings.Split(list, arraySeparator)
+}
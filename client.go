@@ -1,24 +1,32 @@
 package esl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"runtime"
+	"strconv"
+	"sync"
 	"time"
 )
 
-// spell-checker:words myevents bgapi noevents nixevent sendevent
+// spell-checker:words myevents bgapi noevents nixevent sendevent nolog
 
 // Client represents a client FreeSWITCH connection.
 type Client struct {
-	conn   *conn
-	chErr  chan error
-	chResp chan response
-	closer io.Closer
-	done   chan struct{}
+	conn    *conn
+	closer  io.Closer
+	done    chan struct{}
+	subs    *subscriptions  // requested subscriptions and filters
+	mu      sync.Mutex      // guards the fields below
+	pending []chan Response // replies awaited in the order of sending commands
+	err     error           // reason the connection was closed; nil while it is open
+	stats   clientStats     // activity counters
+	hooks   hooks           // instrumentation callbacks
+	chain   interceptors    // commands middleware
 }
 
 // Default timeout options.
@@ -37,6 +45,11 @@ var (
 //
 // Returns a new Client and an error if there was a failure in connecting.
 func Connect(addr, password string, opts ...Option) (*Client, error) {
+	return connect(context.Background(), addr, password, opts...)
+}
+
+// connect is like Connect, but stops dialing when the context is done.
+func connect(ctx context.Context, addr, password string, opts ...Option) (*Client, error) {
 	// If the address doesn't contain a port, use the default port
 	if _, _, err := net.SplitHostPort(addr); err != nil {
 		var addrErr *net.AddrError
@@ -48,7 +61,13 @@ func Connect(addr, password string, opts ...Option) (*Client, error) {
 		addr = net.JoinHostPort(addr, defaultPort)
 	}
 
-	conn, err := net.DialTimeout("tcp", addr, DialTimeout)
+	cfg := getConfig(opts...)
+	start := time.Now()
+
+	dialer := net.Dialer{Timeout: DialTimeout} //nolint:exhaustruct
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	cfg.hooks.dial(addr, time.Since(start), err)
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial: %w", err)
 	}
@@ -62,29 +81,50 @@ func NewClient(rwc io.ReadWriteCloser, password string, opts ...Option) (*Client
 
 	conn := newConn(cfg.dumper(rwc), cfg.log)
 
-	if err := conn.AuthTimeout(password, AuthTimeout); err != nil {
+	_, err := cfg.interceptors.invoke(context.Background(), newCommand(cmd("auth", password)),
+		func(context.Context) (Response, error) {
+			start := time.Now()
+			err := conn.AuthTimeout(password, AuthTimeout)
+			cfg.hooks.auth(time.Since(start), err)
+
+			return Response{}, err
+		})
+	if err != nil {
 		rwc.Close()
 
 		return nil, fmt.Errorf("failed to auth: %w", err)
 	}
 
 	client := &Client{
-		conn:   conn,
-		chErr:  make(chan error, 1),
-		chResp: make(chan response),
-		closer: rwc,
-		done:   make(chan struct{}),
+		conn:    conn,
+		closer:  rwc,
+		done:    make(chan struct{}),
+		subs:    newSubscriptions(),
+		mu:      sync.Mutex{},
+		pending: nil,
+		err:     nil,
+		stats:   clientStats{},
+		hooks:   cfg.hooks,
+		chain:   cfg.interceptors,
 	}
 
-	go client.runReader(cfg.events, cfg.autoClose)
+	go client.runReader(cfg)
 	runtime.Gosched()
 
+	for _, f := range cfg.serverFilters() {
+		if err := client.Filter(f.Header, f.Value); err != nil {
+			client.Close()
+
+			return nil, fmt.Errorf("failed to set filter: %w", err)
+		}
+	}
+
 	return client, nil
 }
 
 // Close closes the client connection.
 func (c *Client) Close() error {
-	c.sendRecv(cmd("exit")) //nolint:errcheck // ignore send error
+	c.sendRecv(context.Background(), cmd("exit")) //nolint:errcheck // ignore send error
 
 	return c.closer.Close() //nolint:wrapcheck
 }
@@ -94,12 +134,23 @@ func (c *Client) Done() <-chan struct{} {
 	return c.done
 }
 
+// Stats returns the counters of the client activity since it was connected.
+func (c *Client) Stats() ClientStats {
+	return c.stats.snapshot()
+}
+
 // API sends a command to the API and returns the response body or an error.
 //
 // Send a FreeSWITCH API command, blocking mode. That is, the FreeSWITCH
 // instance won't accept any new commands until the api command finished execution.
 func (c *Client) API(command string) (string, error) {
-	resp, err := c.sendRecv(cmd("api", command))
+	return c.APIContext(context.Background(), command)
+}
+
+// APIContext is like API, but stops waiting for the reply when the context is
+// done. The context is passed to the hooks, see WithHooks.
+func (c *Client) APIContext(ctx context.Context, command string) (string, error) {
+	resp, err := c.sendRecv(ctx, cmd("api", command))
 	if err != nil {
 		return "", err
 	}
@@ -119,7 +170,13 @@ func (c *Client) API(command string) (string, error) {
 // and you can compare that to the Job-UUID to see what the result was. In order
 // to receive this event, you will need to subscribe to BACKGROUND_JOB events.
 func (c *Client) Job(command string) (id string, err error) { //nolint:nonamedreturns
-	resp, err := c.sendRecv(cmd("bgapi", command))
+	return c.JobContext(context.Background(), command)
+}
+
+// JobContext is like Job, but stops waiting for the reply when the context is
+// done. The context is passed to the hooks, see WithHooks.
+func (c *Client) JobContext(ctx context.Context, command string) (string, error) {
+	resp, err := c.sendRecv(ctx, cmd("bgapi", command))
 	if err != nil {
 		return "", err
 	}
@@ -137,7 +194,7 @@ func (c *Client) Job(command string) (id string, err error) { //nolint:nonamedre
 // and you can compare that to the Job-UUID to see what the result was. In order
 // to receive this event, you will need to subscribe to BACKGROUND_JOB events.
 func (c *Client) JobWithID(command, id string) error {
-	_, err := c.sendRecv(cmd("bgapi", command).WithJobUUID(id))
+	_, err := c.sendRecv(context.Background(), cmd("bgapi", command).WithJobUUID(id))
 
 	return err
 }
@@ -147,28 +204,64 @@ func (c *Client) JobWithID(command, id string) error {
 // You may specify any number events on the same line that should be separated with spaces.
 //
 // Subsequent calls to event won't override the previous event sets.
+//
+// The subscriptions are reference-counted: the event command is sent only
+// for the events that are not yet subscribed, and each subscription should be
+// canceled by the matching Unsubscribe call.
 func (c *Client) Subscribe(names ...string) error {
-	cmdNames := buildEventNamesCmd(names...)
-	_, err := c.sendRecv(cmd("event", cmdNames))
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
 
-	return err
+	saved := c.subs.save()
+
+	added := c.subs.subscribe(names...)
+	if len(added) == 0 {
+		return nil // already subscribed
+	}
+
+	cmdNames := buildEventNamesCmd(added...)
+	if _, err := c.sendRecv(context.Background(), cmd("event", cmdNames)); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	return nil
 }
 
 // Unsubscribe unsubscribes the client from one or more events.
 //
 // Suppress the specified type of event.
-// If name is empty then all events will be suppressed.
+// If name is empty then the subscription to all events is canceled.
+//
+// The events are suppressed only when there are no more subscriptions to
+// them made by Subscribe. The subscription to all events keeps all events
+// received until it is canceled.
 func (c *Client) Unsubscribe(names ...string) error {
-	var err error
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
 
-	cmdNames := buildEventNamesCmd(names...)
-	if cmdNames == eventAll {
-		_, err = c.sendRecv(cmd("noevents"))
-	} else {
-		_, err = c.sendRecv(cmd("nixevent", cmdNames))
+	saved := c.subs.save()
+
+	removed := c.subs.unsubscribe(names...)
+	for _, cmd := range c.subs.unsubscribeCmds(removed) {
+		if _, err := c.sendRecv(context.Background(), cmd); err != nil {
+			c.subs.restore(saved)
+
+			return err
+		}
 	}
 
-	return err
+	return nil
+}
+
+// Subscriptions returns the sorted names of the events the client is subscribed to.
+// The subscription to all events is returned as "all".
+func (c *Client) Subscriptions() []string {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	return c.subs.names()
 }
 
 // Filter performs a filter operation on the Client.
@@ -189,10 +282,25 @@ func (c *Client) Unsubscribe(names ...string) error {
 // To filter multiple unique IDs, you can just add another filter for events for
 // each UUID. This can be useful for example if you want to receive start/stop-talking
 // events for multiple users on a particular conference.
+//
+// The filters are reference-counted, just like the subscriptions.
 func (c *Client) Filter(eventHeader, valueToFilter string) error {
-	_, err := c.sendRecv(cmd("filter", eventHeader, valueToFilter))
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
 
-	return err
+	saved := c.subs.save()
+
+	if !c.subs.filter(EventFilter{Header: eventHeader, Value: valueToFilter}) {
+		return nil // already added
+	}
+
+	if _, err := c.sendRecv(context.Background(), cmd("filter", eventHeader, valueToFilter)); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	return nil
 }
 
 // FilterDelete removes a filter from the Client.
@@ -200,10 +308,40 @@ func (c *Client) Filter(eventHeader, valueToFilter string) error {
 // Specify the events which you want to revoke the filter.
 // filter delete can be used when some filters are applied wrongly or when there
 // is no use of the filter.
+//
+// The filter is deleted on the server when there are no more references to it.
+// The empty value deletes all filters for the header, regardless of the
+// references.
 func (c *Client) FilterDelete(eventHeader, valueToFilter string) error {
-	_, err := c.sendRecv(cmd("filter delete", eventHeader, valueToFilter))
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
 
-	return err
+	saved := c.subs.save()
+
+	if !c.subs.filterDelete(EventFilter{Header: eventHeader, Value: valueToFilter}) {
+		return nil // still referenced
+	}
+
+	params := []string{eventHeader}
+	if valueToFilter != "" {
+		params = append(params, valueToFilter)
+	}
+
+	if _, err := c.sendRecv(context.Background(), cmd("filter delete", params...)); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	return nil
+}
+
+// Filters returns the sorted list of the filters applied on the server.
+func (c *Client) Filters() []EventFilter {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	return c.subs.filterList()
 }
 
 // The 'myevents' subscription allows your inbound socket connection to behave
@@ -218,7 +356,7 @@ func (c *Client) FilterDelete(eventHeader, valueToFilter string) error {
 // channel/uuid and you need watch for other events as well then it is best to
 // use a filter.
 func (c *Client) MyEvent(uuid string) error {
-	_, err := c.sendRecv(cmd("myevents", uuid))
+	_, err := c.sendRecv(context.Background(), cmd("myevents", uuid))
 
 	return err
 }
@@ -237,15 +375,63 @@ func (c *Client) DivertEvents(on ...bool) error {
 		val = "on"
 	}
 
-	_, err := c.sendRecv(cmd("divert_events", val))
+	_, err := c.sendRecv(context.Background(), cmd("divert_events", val))
 
 	return err
 }
 
-// Send an event into the event system.
-func (c *Client) SendEvent(name string, headers map[string]string, body string) error {
-	_, err := c.sendRecv(
-		cmd("sendevent", name).WithMessage(headers, body))
+// Log enables the console log of the server at the given level and above.
+// The log lines are sent to the channel set with WithLogs.
+func (c *Client) Log(level LogLevel) error {
+	_, err := c.sendRecv(context.Background(), cmd("log", strconv.Itoa(int(level))))
+
+	return err
+}
+
+// NoLog disables the console log enabled with Log.
+func (c *Client) NoLog() error {
+	_, err := c.sendRecv(context.Background(), cmd("nolog"))
+
+	return err
+}
+
+// Do sends a raw command to the server and returns the full response.
+//
+// It is an escape hatch for the ESL commands that are not wrapped by the Client
+// methods. The first line of the command contains its name with parameters.
+// It may be followed by header lines and, after an empty line, by a body:
+//
+//	sendmsg d29a070f-40ff-43d8-8b9d-d369b2389dfe
+//	call-command: hangup
+//	hangup-cause: NORMAL_CLEARING
+//
+// If the server replies with an error, the response is returned along with it.
+func (c *Client) Do(ctx context.Context, command string) (Response, error) {
+	cmd, err := parseCommand(command)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return c.sendRecv(ctx, cmd)
+}
+
+// SendEvent sends an event into the event system.
+//
+// The multi-line header values are url-encoded, as in the events received from
+// the server, so the received event may be sent again without losing them.
+// The values without line breaks are sent as is.
+//
+//	event := esl.NewEvent("NOTIFY", map[string]string{
+//		"profile":      "internal",
+//		"event-string": "check-sync",
+//		"user":         "1000",
+//		"host":         "192.168.10.4",
+//		"content-type": "application/simple-message-summary",
+//	}, nil)
+//	err := client.SendEvent(event)
+func (c *Client) SendEvent(e Event) error {
+	_, err := c.sendRecv(context.Background(),
+		cmd("sendevent", e.Get("Event-Name")).WithEvent(e))
 
 	return err
 }
@@ -253,63 +439,78 @@ func (c *Client) SendEvent(name string, headers map[string]string, body string)
 // SendMsg is used to control the behavior of FreeSWITCH. UUID is mandatory,
 // and it refers to a specific call (i.e., a channel or call leg or session).
 func (c *Client) SendMsg(uuid string, headers map[string]string, body string) error {
-	_, err := c.sendRecv(
+	_, err := c.sendRecv(context.Background(),
 		cmd("sendmsg", uuid).WithMessage(headers, body))
 
 	return err
 }
 
 const (
+	apiResponse      = "api/response"
 	commandReply     = "command/reply"
 	disconnectNotice = "text/disconnect-notice"
+	logData          = "log/data"
 	eventPlain       = "text/event-plain"
+	eventJSON        = "text/event-json"
+	eventXML         = "text/event-xml"
 )
 
 // runReader is a method of the Client struct that reads responses from the connection and handles them accordingly.
-func (c *Client) runReader(events chan<- Event, autoClose bool) {
+func (c *Client) runReader(cfg config) {
 	c.conn.log.Info("esl: run response reading")
 
+	var err error
+
 	defer func() {
-		close(c.chResp)
-		close(c.chErr)
+		c.mu.Lock()
+		c.err = err
+
+		for _, reply := range c.pending {
+			close(reply) // notify about closing the connection
+		}
+
+		c.pending = nil
+		c.mu.Unlock()
+
+		c.hooks.disconnect(err)
 		close(c.done)
 
-		if autoClose && events != nil {
-			close(events)
+		if cfg.autoClose && cfg.events != nil {
+			close(cfg.events)
+		}
+
+		if cfg.logsAutoClose && cfg.logs != nil {
+			close(cfg.logs)
 		}
 
 		c.conn.log.Info("esl: response reader stopped")
 	}()
 
 	for {
-		resp, err := c.conn.Read()
-		if err != nil {
-			c.chErr <- err
+		var resp Response
 
+		resp, err = c.conn.Read()
+		if err != nil {
 			return // break on read error
 		}
 
 		switch contentType := resp.ContentType(); contentType {
-		case "api/response", commandReply:
-			c.chResp <- resp
+		case apiResponse, commandReply:
+			c.reply(resp)
 
-		case eventPlain:
-			if events == nil {
-				continue // ignore events if no events channel is provided
-			}
+		case eventPlain, eventJSON, eventXML:
+			c.handleEvent(cfg, resp)
 
-			event, err := resp.toEvent()
-			if err != nil {
-				c.conn.log.Error("esl: failed to parse event",
-					slog.String("err", err.Error()))
+		case logData:
+			c.stats.logLines.Add(1)
 
-				continue // ignore bad event
+			if cfg.logs != nil {
+				cfg.logs <- newLogLine(resp)
 			}
 
-			c.conn.log.Info("esl: handle", slog.Any("event", event))
-			events <- event
-
 		case disconnectNotice:
+			err = io.EOF
+
 			return // disconnect
 
 		default:
@@ -319,30 +520,164 @@ func (c *Client) runReader(events chan<- Event, autoClose bool) {
 	}
 }
 
-// sendRecv sends a command to the server and returns the response.
-func (c *Client) sendRecv(cmd command) (response, error) {
-	if err := c.conn.Write(cmd); err != nil {
-		return response{}, err
+// handleEvent parses the event and sends it to the events channel.
+func (c *Client) handleEvent(cfg config, resp Response) {
+	c.stats.events.Add(1)
+
+	if cfg.events == nil && cfg.sequence == nil && cfg.monitor == nil && cfg.limiter == nil && len(c.hooks) == 0 {
+		return // ignore events if no events channel is provided
+	}
+
+	event, err := resp.toEvent(cfg.pooled)
+	if err != nil {
+		c.stats.invalid.Add(1)
+		c.hooks.eventDropped(DropInvalid)
+		c.conn.log.Error("esl: failed to parse event",
+			slog.String("err", err.Error()))
+
+		return // ignore bad event
+	}
+
+	c.stats.event(event)
+	c.hooks.eventReceived(event)
+
+	if cfg.sequence != nil {
+		cfg.sequence.Observe(event)
+	}
+
+	if cfg.monitor != nil {
+		cfg.monitor.Observe(event)
+	}
+
+	if cfg.limiter != nil {
+		cfg.limiter.Observe(event)
+	}
+
+	if cfg.events == nil || !cfg.filter.Match(event) {
+		if cfg.events != nil {
+			c.stats.filtered.Add(1)
+			c.hooks.eventDropped(DropFiltered)
+		}
+
+		event.Release()
+
+		return // filtered out
+	}
+
+	c.conn.log.Info("esl: handle", slog.Any("event", event))
+	cfg.events <- event
+}
+
+// reply passes the response to the oldest command awaiting a reply.
+func (c *Client) reply(resp Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) == 0 {
+		c.conn.log.Warn("esl: unexpected reply", slog.Any("response", resp))
+
+		return
+	}
+
+	reply := c.pending[0]
+	c.pending[0] = nil
+	c.pending = c.pending[1:]
+	reply <- resp // buffered channel: never blocks
+}
+
+// sendRecv sends a command to the server through the interceptors and returns
+// the response.
+func (c *Client) sendRecv(ctx context.Context, cmd command) (Response, error) {
+	if len(c.chain) == 0 {
+		return c.invoke(ctx, cmd)
 	}
 
-	return c.read()
+	return c.chain.invoke(ctx, newCommand(cmd), func(ctx context.Context) (Response, error) {
+		return c.invoke(ctx, cmd)
+	})
 }
 
-// read reads the response from the client's channel and returns it along with any error.
-func (c *Client) read() (response, error) {
+// invoke sends a command to the server and returns the response, counting
+// it in the client stats and calling the hooks.
+func (c *Client) invoke(ctx context.Context, cmd command) (Response, error) {
+	if len(c.hooks) == 0 {
+		start := time.Now()
+		resp, err := c.roundTrip(ctx, cmd)
+		c.stats.command(time.Since(start), err)
+
+		return resp, err
+	}
+
+	view := newCommand(cmd)
+	ctx = c.hooks.commandSent(ctx, view)
+
+	start := time.Now()
+	resp, err := c.roundTrip(ctx, cmd)
+	latency := time.Since(start)
+
+	c.stats.command(latency, err)
+	c.hooks.replyReceived(ctx, view, resp, latency, err)
+
+	return resp, err
+}
+
+// roundTrip sends a command to the server and waits for the reply.
+//
+// If the context is done before the reply is received, the reply is discarded
+// when it arrives, so it never gets mixed up with the replies to other commands.
+func (c *Client) roundTrip(ctx context.Context, cmd command) (Response, error) {
+	reply := make(chan Response, 1)
+
+	c.mu.Lock()
+
+	if err := c.err; err != nil {
+		c.mu.Unlock()
+
+		return Response{}, unsentError{err: err} // connection closed
+	}
+
+	if err := c.conn.Write(cmd); err != nil {
+		c.mu.Unlock()
+
+		return Response{}, err
+	}
+
+	c.pending = append(c.pending, reply)
+	c.mu.Unlock()
+
 	select {
-	case err, ok := <-c.chErr:
-		if ok {
-			return response{}, err
+	case resp, ok := <-reply:
+		if !ok {
+			return Response{}, c.closeErr()
 		}
 
-		return response{}, io.EOF // connection closed
+		err := resp.AsErr()
 
-	case resp := <-c.chResp:
-		if err := resp.AsErr(); err != nil {
-			return response{}, err // response with error message
+		var replyErr *ReplyError
+		if errors.As(err, &replyErr) {
+			replyErr.Command = cmd.Line()
 		}
 
-		return resp, nil
+		return resp, err
+
+	case <-ctx.Done():
+		return Response{}, ctx.Err() //nolint:wrapcheck
 	}
 }
+
+// unsentError is the error of the command which was not sent to the server,
+// so it is safe to send it again over another connection.
+type unsentError struct {
+	err error
+}
+
+func (e unsentError) Error() string { return e.err.Error() }
+func (e unsentError) Unwrap() error { return e.err }
+
+// closeErr returns the reason the connection was closed.
+func (c *Client) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
@@ -1,8 +1,15 @@
 package esl
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
 	"log/slog"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -75,3 +82,248 @@ func TestClientDefault(t *testing.T) {
 
 	client.Close()
 }
+
+// fakeServer emulates the FreeSWITCH side of the event socket connection.
+type fakeServer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newFakeClient returns a client authenticated on the fake server.
+// After the authentication, the serve function is run in a separate goroutine
+// to handle the commands sent by the client.
+func newFakeClient(t *testing.T, serve func(srv *fakeServer), opts ...Option) *Client {
+	t.Helper()
+
+	client, server := net.Pipe()
+	srv := &fakeServer{t: t, conn: server, r: bufio.NewReader(server)}
+
+	go func() {
+		srv.send("Content-Type: auth/request\n\n")
+
+		if cmd := srv.recv(); cmd != "auth ClueCon" {
+			t.Errorf("unexpected auth command: %q", cmd)
+		}
+
+		srv.send("Content-Type: command/reply\nReply-Text: +OK accepted\n\n")
+
+		if serve != nil {
+			serve(srv)
+		}
+	}()
+
+	c, err := NewClient(client, "ClueCon", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { server.Close() })
+
+	return c
+}
+
+// send writes the raw message to the client.
+func (s *fakeServer) send(msg string) {
+	_, err := io.WriteString(s.conn, msg)
+	if err != nil && !errors.Is(err, io.ErrClosedPipe) && !errors.Is(err, net.ErrClosed) {
+		s.t.Error("fake server write:", err)
+	}
+}
+
+// reply sends the command reply with the given text.
+func (s *fakeServer) reply(text string) {
+	s.send("Content-Type: command/reply\nReply-Text: " + text + "\n\n")
+}
+
+// sendEvent sends the event with the given headers in the plain format.
+func (s *fakeServer) sendEvent(headers ...string) {
+	body := strings.Join(headers, "\n") + "\n\n"
+	s.send("Content-Type: text/event-plain\nContent-Length: " + strconv.Itoa(len(body)) + "\n\n" + body)
+}
+
+// recv reads the next command sent by the client, including the headers and body.
+func (s *fakeServer) recv() string {
+	var (
+		lines  []string
+		length int
+	)
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) && !errors.Is(err, net.ErrClosed) {
+				s.t.Error("fake server read:", err)
+			}
+
+			return ""
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+
+		if v, ok := strings.CutPrefix(line, "content-length: "); ok {
+			length, _ = strconv.Atoi(v)
+		}
+
+		lines = append(lines, line)
+	}
+
+	cmd := strings.Join(lines, "\n")
+	if length > 0 {
+		body := make([]byte, length)
+		if _, err := io.ReadFull(s.r, body); err != nil {
+			s.t.Error("fake server read body:", err)
+		}
+
+		cmd += "\n\n" + string(body)
+	}
+
+	return cmd
+}
+
+func TestClientDo(t *testing.T) {
+	client := newFakeClient(t, func(srv *fakeServer) {
+		if cmd := srv.recv(); cmd != "sendmsg 1234\ncall-command: hangup" {
+			t.Errorf("unexpected command: %q", cmd)
+		}
+
+		srv.send("Content-Type: command/reply\nReply-Text: +OK\nSocket-Mode: async\n" +
+			"Controlled-Session-UUID: 1234\n\n")
+
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 20\n\n-ERR no such channel")
+	})
+
+	resp, err := client.Do(context.Background(), "sendmsg 1234\ncall-command: hangup\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Text() != "+OK" || resp.Get("Socket-Mode") != "async" ||
+		resp.Get("Controlled-Session-UUID") != "1234" {
+		t.Errorf("unexpected response: %v", resp.Header())
+	}
+
+	resp, err = client.Do(context.Background(), "api uuid_kill 1234")
+	if !errors.Is(err, ErrNoSuchChannel) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var replyErr *ReplyError
+	if errors.As(err, &replyErr) && replyErr.Command != "api uuid_kill 1234" {
+		t.Errorf("unexpected error command: %q", replyErr.Command)
+	}
+
+	if resp.ContentType() != "api/response" {
+		t.Errorf("unexpected response: %s", resp)
+	}
+
+	if _, err := client.Do(context.Background(), " \n"); !errors.Is(err, ErrEmptyCommand) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClientDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan struct{})
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		srv.recv()
+		cancel()
+		<-canceled
+		srv.send("Content-Type: api/response\nContent-Length: 3\n\nOLD")
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 3\n\nNEW")
+	})
+
+	if _, err := client.Do(ctx, "api status"); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(canceled)
+
+	// the reply to the canceled command must not be mixed up with this one
+	msg, err := client.API("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg != "NEW" {
+		t.Errorf("unexpected reply: %q", msg)
+	}
+}
+
+func TestClientSendEvent(t *testing.T) {
+	received := make(chan string, 1)
+	client := newFakeClient(t, func(srv *fakeServer) {
+		received <- srv.recv()
+		srv.reply("+OK")
+	})
+
+	event := NewEvent("NOTIFY", map[string]string{
+		"profile":   "internal",
+		"sip-lines": "line 1\r\nline 2",
+	}, nil)
+
+	if err := client.SendEvent(event); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := <-received
+	line, headers, _ := strings.Cut(cmd, "\n")
+
+	if line != "sendevent NOTIFY" || strings.Count(cmd, "\n") != 2 {
+		t.Fatalf("unexpected command: %q", cmd)
+	}
+
+	sent, err := parseEvent([]byte(headers + "\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"profile", "sip-lines"} {
+		if sent.Get(key) != event.Get(key) {
+			t.Errorf("%s: got %q, want %q", key, sent.Get(key), event.Get(key))
+		}
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	events := make(chan Event, 2)
+	filter, err := ParseExpr(`Unique-ID =~ "^call"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 2\n\nUP")
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 4\n\n-ERR")
+
+		ts := strconv.FormatInt(time.Now().Add(-time.Second).UnixMicro(), 10)
+		srv.sendEvent("Event-Name: CHANNEL_CREATE", "Unique-ID: other", "Event-Date-Timestamp: "+ts)
+		srv.sendEvent("Event-Name: CHANNEL_CREATE", "Unique-ID: call-1", "Event-Date-Timestamp: "+ts)
+	}, WithEvents(events), WithEventFilter(filter))
+
+	client.API("status")         //nolint:errcheck
+	client.API("uuid_kill 1234") //nolint:errcheck
+
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	stats := client.Stats()
+	if stats.Commands != 2 || stats.CommandErrors != 1 || stats.CommandLatency <= 0 {
+		t.Errorf("unexpected command stats: %+v", stats)
+	}
+
+	if stats.Events != 2 || stats.EventsFiltered != 1 || stats.EventLag < time.Second {
+		t.Errorf("unexpected event stats: %+v", stats)
+	}
+}
@@ -0,0 +1,640 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NodeHeader is the header added by Cluster to the events with the address
+// of the node they are received from.
+const NodeHeader = "Esl-Node"
+
+// The delays between the attempts to reconnect the failed node of Cluster.
+// The delay is doubled after each failed attempt up to the maximum.
+//
+//nolint:gochecknoglobals
+var (
+	ReconnectDelay    = time.Second
+	ReconnectMaxDelay = time.Second * 30
+)
+
+// Errors returned by Cluster.
+var (
+	ErrNoNodes       = errors.New("no nodes available")
+	ErrNodeDown      = errors.New("node is down")
+	ErrUnknownNode   = errors.New("unknown node")
+	ErrClusterClosed = errors.New("cluster closed")
+)
+
+// Policy selects the node of Cluster to send the API command to.
+type Policy uint8
+
+// The routing policies.
+const (
+	PolicyRoundRobin    Policy = iota // the nodes in turn
+	PolicyLeastSessions               // the node with the least Session-Count
+)
+
+// String returns the name of the policy.
+func (p Policy) String() string {
+	switch p {
+	case PolicyRoundRobin:
+		return "round-robin"
+	case PolicyLeastSessions:
+		return "least-sessions"
+	default:
+		return "unknown"
+	}
+}
+
+// ClusterNode is the state of the node of Cluster.
+type ClusterNode struct {
+	Addr     string
+	Up       bool      // the node is connected and healthy
+	Sessions int       // the last known Session-Count
+	Since    time.Time // when the node went up or down
+	Err      error     // the reason the node is down
+}
+
+// NodeResult is the result of the API command sent to the node.
+type NodeResult struct {
+	Node   string // the address of the node
+	Result string
+	Err    error
+}
+
+// Cluster is the set of the clients connected to several FreeSWITCH nodes.
+//
+// The events of all nodes are merged into one channel, each event tagged with
+// the NodeHeader. The subscriptions and filters made with Cluster are applied
+// to all nodes and replayed on the reconnected ones.
+//
+// The API commands are routed to the nodes by the policy, to the specific node
+// with APIOn, or to all nodes with APIAll. The failed node is marked down when
+// its connection is closed, and is readmitted after it is reconnected and its
+// status is UP and ready.
+//
+// The cluster subscribes each node to the HEARTBEAT events to track the
+// Session-Count for PolicyLeastSessions. They are passed to the events channel
+// only if subscribed with Cluster.Subscribe.
+type Cluster struct {
+	password  string
+	opts      []Option
+	events    chan<- Event
+	nodes     []*clusterNode
+	policy    atomic.Uint32
+	next      atomic.Uint64 // round-robin counter
+	subs      *subscriptions
+	heartbeat atomic.Bool // HEARTBEAT events are subscribed by the application
+	mu        sync.Mutex  // orders the admission of the nodes with Close
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// clusterNode is the node of the cluster.
+type clusterNode struct {
+	addr     string
+	mu       sync.Mutex
+	client   *Client // nil if the node is down
+	sessions int
+	since    time.Time
+	err      error
+}
+
+// ConnectCluster connects to the nodes with the given addresses and returns
+// the Cluster. It returns an error only if none of the nodes are connected;
+// the rest of the nodes are reconnected in the background.
+//
+// The received events of all nodes are sent to the events channel, which may
+// be nil, and the channel is closed by Cluster.Close, but not when connecting
+// fails. With WithEventPool the receiver releases the events as usual. The options are applied
+// to the clients of all nodes, except for WithEvents.
+func ConnectCluster(addrs []string, password string, events chan<- Event, opts ...Option) (*Cluster, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	c := &Cluster{ //nolint:exhaustruct // the rest is zero
+		password: password,
+		opts:     opts,
+		events:   events,
+		nodes:    make([]*clusterNode, len(addrs)),
+		subs:     newSubscriptions(),
+		done:     make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+
+	for i, addr := range addrs {
+		n := &clusterNode{addr: addr} //nolint:exhaustruct // down until connected
+		c.nodes[i] = n
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := c.admit(n); err != nil {
+				n.setDown(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	var errs []error
+
+	for _, n := range c.nodes {
+		if n.get() != nil {
+			errs = nil
+
+			break
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", n.addr, n.err))
+	}
+
+	if errs != nil {
+		c.events = nil // owned by the caller until the cluster is returned
+		c.Close()
+
+		return nil, fmt.Errorf("%w: %w", ErrNoNodes, errors.Join(errs...))
+	}
+
+	for _, n := range c.nodes {
+		c.wg.Add(1)
+
+		go c.watch(n)
+	}
+
+	return c, nil
+}
+
+// Close closes the connections to all nodes and the events channel.
+func (c *Cluster) Close() error {
+	c.closeOnce.Do(func() {
+		// the nodes admitted before are closed below, the rest see done
+		c.mu.Lock()
+		close(c.done)
+		c.mu.Unlock()
+
+		for _, n := range c.nodes {
+			if client := n.get(); client != nil {
+				client.Close()
+			}
+		}
+
+		c.wg.Wait()
+
+		if c.events != nil {
+			close(c.events)
+		}
+	})
+
+	return nil
+}
+
+// SetPolicy changes the policy of routing the API commands.
+func (c *Cluster) SetPolicy(p Policy) {
+	c.policy.Store(uint32(p))
+}
+
+// Nodes returns the state of the nodes in the order of their addresses.
+func (c *Cluster) Nodes() []ClusterNode {
+	nodes := make([]ClusterNode, len(c.nodes))
+
+	for i, n := range c.nodes {
+		n.mu.Lock()
+		nodes[i] = ClusterNode{
+			Addr:     n.addr,
+			Up:       n.client != nil,
+			Sessions: n.sessions,
+			Since:    n.since,
+			Err:      n.err,
+		}
+		n.mu.Unlock()
+	}
+
+	return nodes
+}
+
+// Client returns the client of the node with the given address, or nil if
+// the node is down or unknown.
+func (c *Cluster) Client(addr string) *Client {
+	if n := c.node(addr); n != nil {
+		return n.get()
+	}
+
+	return nil
+}
+
+// API sends the API command to the node selected by the policy and returns
+// the response body. If the node is down or its connection is closed before
+// the command is sent, the command is sent to the next one. The command is
+// never sent again once it was written to the connection, even if the node
+// fails before replying, because the commands like originate must not be
+// executed twice: the error is returned instead.
+func (c *Cluster) API(command string) (string, error) {
+	return c.APIContext(context.Background(), command)
+}
+
+// APIContext is like API, but stops waiting for the reply when the context is
+// done.
+func (c *Cluster) APIContext(ctx context.Context, command string) (string, error) {
+	nodes := c.route()
+	if len(nodes) == 0 {
+		return "", ErrNoNodes
+	}
+
+	var err error
+
+	for _, n := range nodes {
+		client := n.get()
+		if client == nil {
+			continue // went down
+		}
+
+		var result string
+
+		result, err = client.APIContext(ctx, command)
+
+		var unsent unsentError
+		if !errors.As(err, &unsent) {
+			return result, err
+		}
+	}
+
+	if err == nil {
+		err = ErrNoNodes // all nodes went down
+	}
+
+	return "", err
+}
+
+// APIOn sends the API command to the node with the given address.
+func (c *Cluster) APIOn(ctx context.Context, addr, command string) (string, error) {
+	n := c.node(addr)
+	if n == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnknownNode, addr)
+	}
+
+	client := n.get()
+	if client == nil {
+		return "", fmt.Errorf("%w: %s", ErrNodeDown, addr)
+	}
+
+	return client.APIContext(ctx, command)
+}
+
+// APIAll sends the API command to all nodes concurrently and returns their
+// results in the order of the nodes. The nodes which are down get ErrNodeDown.
+func (c *Cluster) APIAll(ctx context.Context, command string) []NodeResult {
+	results := make([]NodeResult, len(c.nodes))
+
+	var wg sync.WaitGroup
+
+	for i, n := range c.nodes {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result, err := c.APIOn(ctx, n.addr, command)
+			results[i] = NodeResult{Node: n.addr, Result: result, Err: err}
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// Subscribe subscribes all nodes to the events, see Client.Subscribe.
+func (c *Cluster) Subscribe(names ...string) error {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	saved := c.subs.save()
+
+	added := c.subs.subscribe(names...)
+	c.heartbeat.Store(c.subs.events["HEARTBEAT"] > 0 || c.subs.events[eventAll] > 0)
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := c.each(func(client *Client) error { return client.Subscribe(added...) }); err != nil {
+		c.subs.restore(saved)
+		c.heartbeat.Store(c.subs.events["HEARTBEAT"] > 0 || c.subs.events[eventAll] > 0)
+
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe cancels the subscriptions of all nodes, see Client.Unsubscribe.
+func (c *Cluster) Unsubscribe(names ...string) error {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	saved := c.subs.save()
+
+	removed := c.subs.unsubscribe(names...)
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := c.each(func(client *Client) error { return client.Unsubscribe(removed...) }); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	c.heartbeat.Store(c.subs.events["HEARTBEAT"] > 0 || c.subs.events[eventAll] > 0)
+
+	return nil
+}
+
+// Filter adds the event filter to all nodes, see Client.Filter.
+func (c *Cluster) Filter(eventHeader, valueToFilter string) error {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	saved := c.subs.save()
+
+	if !c.subs.filter(EventFilter{Header: eventHeader, Value: valueToFilter}) {
+		return nil
+	}
+
+	if err := c.each(func(client *Client) error { return client.Filter(eventHeader, valueToFilter) }); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	return nil
+}
+
+// FilterDelete removes the event filter from all nodes, see Client.FilterDelete.
+func (c *Cluster) FilterDelete(eventHeader, valueToFilter string) error {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	saved := c.subs.save()
+
+	if !c.subs.filterDelete(EventFilter{Header: eventHeader, Value: valueToFilter}) {
+		return nil
+	}
+
+	if err := c.each(func(client *Client) error { return client.FilterDelete(eventHeader, valueToFilter) }); err != nil {
+		c.subs.restore(saved)
+
+		return err
+	}
+
+	return nil
+}
+
+// each calls the function for the clients of all nodes which are up and
+// returns the joined errors.
+func (c *Cluster) each(fn func(*Client) error) error {
+	var errs []error
+
+	for _, n := range c.nodes {
+		if client := n.get(); client != nil {
+			if err := fn(client); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", n.addr, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// node returns the node with the given address.
+func (c *Cluster) node(addr string) *clusterNode {
+	for _, n := range c.nodes {
+		if n.addr == addr {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// route returns the nodes which are up in the order of the policy.
+func (c *Cluster) route() []*clusterNode {
+	type candidate struct {
+		node     *clusterNode
+		sessions int
+	}
+
+	list := make([]candidate, 0, len(c.nodes))
+
+	for _, n := range c.nodes {
+		n.mu.Lock()
+		if n.client != nil {
+			list = append(list, candidate{node: n, sessions: n.sessions})
+		}
+		n.mu.Unlock()
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	// rotate to spread the commands between the nodes with the same load
+	start := int(c.next.Add(1) % uint64(len(list)))
+	list = slices.Concat(list[start:], list[:start])
+
+	if Policy(c.policy.Load()) == PolicyLeastSessions {
+		slices.SortStableFunc(list, func(a, b candidate) int { return a.sessions - b.sessions })
+	}
+
+	nodes := make([]*clusterNode, len(list))
+	for i, v := range list {
+		nodes[i] = v.node
+	}
+
+	return nodes
+}
+
+// admit connects the node, checks its status and restores the subscriptions.
+func (c *Cluster) admit(n *clusterNode) error {
+	events := make(chan Event, 1)
+	opts := append(slices.Clip(c.opts), WithEvents(events, true))
+
+	client, err := Connect(n.addr, c.password, opts...)
+	if err != nil {
+		return err
+	}
+
+	var status NodeStatus
+
+	result, err := client.API("status")
+	if err == nil {
+		status, err = ParseStatus(result)
+	}
+
+	if err == nil && !status.Ready {
+		err = fmt.Errorf("%w: not ready", ErrNodeDown)
+	}
+
+	if err == nil {
+		err = client.Subscribe("HEARTBEAT")
+	}
+
+	if err != nil {
+		client.Close()
+
+		return err
+	}
+
+	c.wg.Add(1)
+
+	go c.forward(n, events)
+
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	if names := c.subs.names(); len(names) > 0 {
+		err = client.Subscribe(names...)
+	}
+
+	for _, f := range c.subs.filterList() {
+		if err == nil {
+			err = client.Filter(f.Header, f.Value)
+		}
+	}
+
+	if err != nil {
+		client.Close()
+
+		return fmt.Errorf("failed to restore subscriptions: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		client.Close()
+
+		return ErrClusterClosed
+	default:
+	}
+
+	n.mu.Lock()
+	n.client = client
+	n.sessions = status.Sessions
+	n.since = time.Now()
+	n.err = nil
+	n.mu.Unlock()
+
+	return nil
+}
+
+// watch marks the node down when its connection is closed and reconnects it.
+func (c *Cluster) watch(n *clusterNode) {
+	defer c.wg.Done()
+
+	delay := ReconnectDelay
+
+	for {
+		if client := n.get(); client != nil {
+			select {
+			case <-client.Done():
+				n.setDown(client.closeErr())
+
+				delay = ReconnectDelay
+			case <-c.done:
+				return
+			}
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+
+			return
+		}
+
+		if err := c.admit(n); err != nil {
+			n.setDown(err)
+
+			delay = min(delay*2, ReconnectMaxDelay)
+		}
+	}
+}
+
+// forward passes the events of the node to the events channel.
+func (c *Cluster) forward(n *clusterNode, events <-chan Event) {
+	defer c.wg.Done()
+
+	for e := range events {
+		if e.Name() == "HEARTBEAT" {
+			if status, err := ParseHeartbeat(e); err == nil {
+				n.mu.Lock()
+				n.sessions = status.Sessions
+				n.mu.Unlock()
+			}
+
+			if !c.heartbeat.Load() {
+				e.Release()
+
+				continue
+			}
+		}
+
+		if c.events == nil {
+			e.Release()
+
+			continue
+		}
+
+		select {
+		case c.events <- e.withTag(NodeHeader, n.addr):
+		case <-c.done: // drain until the client is closed
+			e.Release()
+		}
+	}
+}
+
+// get returns the client of the node, or nil if the node is down.
+func (n *clusterNode) get() *Client {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.client
+}
+
+// setDown marks the node down with the reason.
+func (n *clusterNode) setDown(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.client != nil || n.since.IsZero() {
+		n.since = time.Now()
+	}
+
+	n.client = nil
+	n.err = err
+}
+
+// firstLine returns the first line of the text.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,319 @@
+package esl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNode is the fake FreeSWITCH server accepting the connections.
+type fakeNode struct {
+	t        *testing.T
+	ln       net.Listener
+	hostname string
+	sessions string
+	mu       sync.Mutex
+	srv      *fakeServer // the current connection
+	commands []string
+	notReady bool // the status reports the system is not ready
+}
+
+func newFakeNode(t *testing.T, hostname, sessions string) *fakeNode {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &fakeNode{t: t, ln: ln, hostname: hostname, sessions: sessions, mu: sync.Mutex{}, srv: nil, commands: nil, notReady: false}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() { conn.Close() })
+
+			srv := &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+			n.mu.Lock()
+			n.srv = srv
+			n.mu.Unlock()
+
+			go n.serve(srv)
+		}
+	}()
+
+	return n
+}
+
+func (n *fakeNode) addr() string { return n.ln.Addr().String() }
+
+func (n *fakeNode) serve(srv *fakeServer) {
+	n.send(srv, "Content-Type: auth/request\n\n")
+
+	for {
+		cmd := srv.recv()
+		if cmd == "" {
+			return
+		}
+
+		n.mu.Lock()
+		n.commands = append(n.commands, cmd)
+		n.mu.Unlock()
+
+		switch {
+		case strings.Contains(cmd, "FAIL"):
+			n.send(srv, "Content-Type: command/reply\nReply-Text: -ERR failed\n\n")
+		case strings.HasPrefix(cmd, "auth "), strings.HasPrefix(cmd, "event "), strings.HasPrefix(cmd, "filter "):
+			n.send(srv, "Content-Type: command/reply\nReply-Text: +OK accepted\n\n")
+		case cmd == "api status":
+			n.mu.Lock()
+			ready := "ready"
+			if n.notReady {
+				ready = "not ready"
+			}
+			n.mu.Unlock()
+
+			n.sendAPI(srv, "UP 0 years, 0 days\nFreeSWITCH (Version 1.10.9 -release 64bit) is "+ready+"\n"+
+				n.sessions+" session(s) - peak 5, last 5min 2\n")
+		case cmd == "api hostname":
+			n.sendAPI(srv, n.hostname)
+		case cmd == "api msleep 100":
+			time.Sleep(100 * time.Millisecond)
+			n.sendAPI(srv, "+OK")
+		case cmd == "api crash":
+			srv.conn.Close() // fails after receiving the command
+
+			return
+		case cmd == "exit":
+			n.send(srv, "Content-Type: command/reply\nReply-Text: +OK bye\n\n")
+			srv.conn.Close()
+
+			return
+		default:
+			n.sendAPI(srv, "-ERR command not found")
+		}
+	}
+}
+
+func (n *fakeNode) send(srv *fakeServer, msg string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	srv.send(msg)
+}
+
+func (n *fakeNode) sendAPI(srv *fakeServer, body string) {
+	n.send(srv, "Content-Type: api/response\nContent-Length: "+strconv.Itoa(len(body))+"\n\n"+body)
+}
+
+// sendEvent sends the event to the current connection.
+func (n *fakeNode) sendEvent(headers ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.srv.sendEvent(headers...)
+}
+
+// drop closes the current connection.
+func (n *fakeNode) drop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.srv.conn.Close()
+}
+
+// received returns the number of the received commands.
+func (n *fakeNode) received(cmd string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var count int
+
+	for _, c := range n.commands {
+		if c == cmd {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestCluster(t *testing.T) {
+	defer func(delay time.Duration) { ReconnectDelay = delay }(ReconnectDelay)
+	ReconnectDelay = 10 * time.Millisecond
+
+	nodeA, nodeB := newFakeNode(t, "a", "3"), newFakeNode(t, "b", "1")
+	down := "127.0.0.1:1" // nothing listens
+
+	events := make(chan Event, 10)
+
+	cluster, err := ConnectCluster([]string{nodeA.addr(), nodeB.addr(), down}, "ClueCon", events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer cluster.Close()
+
+	nodes := cluster.Nodes()
+	if !nodes[0].Up || nodes[0].Sessions != 3 || !nodes[1].Up || nodes[2].Up || nodes[2].Err == nil {
+		t.Errorf("unexpected nodes: %+v", nodes)
+	}
+
+	results := cluster.APIAll(context.Background(), "hostname")
+	if results[0].Result != "a" || results[1].Result != "b" || !errors.Is(results[2].Err, ErrNodeDown) {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	// round-robin
+	var hosts []string
+
+	for range 2 {
+		host, err := cluster.API("hostname")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if slices.Sort(hosts); !slices.Equal(hosts, []string{"a", "b"}) {
+		t.Errorf("unexpected round-robin hosts: %q", hosts)
+	}
+
+	// least sessions, updated by heartbeats which are not passed to the application
+	cluster.SetPolicy(PolicyLeastSessions)
+
+	if err := cluster.Subscribe("CHANNEL_CREATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeA.sendEvent("Event-Name: HEARTBEAT", "Session-Count: 0")
+	nodeA.sendEvent("Event-Name: CHANNEL_CREATE", "Unique-ID: call-1")
+
+	if e := <-events; e.Name() != "CHANNEL_CREATE" || e.Get(NodeHeader) != nodeA.addr() {
+		t.Errorf("unexpected event: %v", e)
+	}
+
+	if host, err := cluster.API("hostname"); err != nil || host != "a" {
+		t.Errorf("unexpected least sessions host: %q, %v", host, err)
+	}
+
+	// failover: the node is marked down and readmitted with the subscriptions
+	nodeB.drop()
+	waitFor(t, func() bool { return nodeB.received("api status") == 2 && cluster.Nodes()[1].Up })
+
+	if n := nodeB.received("event CHANNEL_CREATE"); n != 2 {
+		t.Errorf("subscription is not restored: %d", n)
+	}
+
+	if host, err := cluster.APIOn(context.Background(), nodeB.addr(), "hostname"); err != nil || host != "b" {
+		t.Errorf("unexpected host: %q, %v", host, err)
+	}
+
+	if _, err := cluster.APIOn(context.Background(), "unknown", "hostname"); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cluster.Close()
+
+	if _, ok := <-events; ok {
+		t.Error("events channel is not closed")
+	}
+}
+
+func TestClusterNoResend(t *testing.T) {
+	defer func(delay time.Duration) { ReconnectDelay = delay }(ReconnectDelay)
+	ReconnectDelay = time.Hour
+
+	nodeA, nodeB := newFakeNode(t, "a", "1"), newFakeNode(t, "b", "1")
+
+	cluster, err := ConnectCluster([]string{nodeA.addr(), nodeB.addr()}, "ClueCon", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	// the command written to the failed node must not be executed again
+	if _, err := cluster.API("crash"); err == nil {
+		t.Error("expected error")
+	}
+
+	if n := nodeA.received("api crash") + nodeB.received("api crash"); n != 1 {
+		t.Errorf("command is sent %d times", n)
+	}
+
+	// the node which is down is skipped
+	waitFor(t, func() bool { return len(cluster.route()) == 1 })
+
+	for range 2 {
+		if _, err := cluster.API("hostname"); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestClusterNotReady(t *testing.T) {
+	nodeA, nodeB := newFakeNode(t, "a", "1"), newFakeNode(t, "b", "1")
+	nodeB.mu.Lock()
+	nodeB.notReady = true
+	nodeB.mu.Unlock()
+
+	cluster, err := ConnectCluster([]string{nodeA.addr(), nodeB.addr()}, "ClueCon", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	if nodes := cluster.Nodes(); !nodes[0].Up || nodes[1].Up || !errors.Is(nodes[1].Err, ErrNodeDown) {
+		t.Errorf("unexpected nodes: %+v", nodes)
+	}
+}
+
+func TestClusterRollback(t *testing.T) {
+	node := newFakeNode(t, "a", "1")
+
+	cluster, err := ConnectCluster([]string{node.addr()}, "ClueCon", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	if err := cluster.Subscribe("HEARTBEAT", "CHANNEL_FAIL"); err == nil {
+		t.Error("expected subscribe error")
+	}
+
+	if err := cluster.Filter("Unique-ID", "FAIL"); err == nil {
+		t.Error("expected filter error")
+	}
+
+	cluster.subs.mu.Lock()
+	names, filters := cluster.subs.names(), cluster.subs.filterList()
+	cluster.subs.mu.Unlock()
+
+	if len(names) != 0 || len(filters) != 0 || cluster.heartbeat.Load() {
+		t.Errorf("registry is not rolled back: %v, %v", names, filters)
+	}
+}
+
+func TestClusterConnectFailure(t *testing.T) {
+	events := make(chan Event, 1)
+
+	if _, err := ConnectCluster([]string{"127.0.0.1:1"}, "ClueCon", events); !errors.Is(err, ErrNoNodes) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events <- Event{} // panics if the channel is closed
+}
@@ -2,6 +2,8 @@ package esl
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"slices"
@@ -33,6 +35,51 @@ func cmd(name string, params ...string) command {
 	}
 }
 
+// ErrEmptyCommand is returned when the raw command has no name.
+var ErrEmptyCommand = errors.New("empty command")
+
+// parseCommand parses the raw text of a command.
+//
+// The first line contains the command name with parameters. It may be followed
+// by header lines and, after an empty line, by a body. The Content-Length
+// header is ignored and calculated from the body when the command is written.
+func parseCommand(text string) (command, error) {
+	head, body, _ := strings.Cut(strings.TrimLeft(text, " \t\r\n"), "\n\n")
+	line, rest, _ := strings.Cut(head, "\n")
+
+	name, params, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if name == "" {
+		return command{}, ErrEmptyCommand
+	}
+
+	c := cmd(name, strings.TrimSpace(params))
+
+	for _, line := range strings.Split(rest, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return command{}, fmt.Errorf("malformed header line: %q", line)
+		}
+
+		switch value = strings.TrimLeft(value, " \t"); {
+		case strings.EqualFold(key, "Content-Length"):
+			continue // calculated from the body
+		case strings.EqualFold(key, "Job-UUID"):
+			c.jobUUID = value
+		default:
+			c.headers[key] = value
+		}
+	}
+
+	c.body = body
+
+	return c, nil
+}
+
 // WithJobUUID sets the jobUUID field of the command struct.
 func (c command) WithJobUUID(id string) command {
 	c.jobUUID = strings.TrimSpace(id)
@@ -48,6 +95,23 @@ func (c command) WithMessage(h map[string]string, body string) command {
 	return c
 }
 
+// WithEvent sets the headers and body of the command from the event.
+// The Event-Name header is skipped as it is passed in the command parameters.
+func (c command) WithEvent(e Event) command {
+	header := e.header()
+
+	c.headers = make(map[string]string, len(header))
+	for k, v := range header {
+		if k != "Event-Name" {
+			c.headers[k] = v
+		}
+	}
+
+	c.body = string(e.body)
+
+	return c
+}
+
 // WriteTo writes the command to the given writer.
 func (c command) WriteTo(w io.Writer) (int64, error) {
 	//nolint:errcheck // writing to buffer
@@ -76,7 +140,17 @@ func (c command) WriteTo(w io.Writer) (int64, error) {
 				buf.WriteByte('\n')
 				buf.WriteString(k)
 				buf.WriteString(": ")
-				buf.WriteString(c.headers[k])
+				// Since messaging format is similar to RFC 2822, if you are using any
+				// libraries that follow the line wrapping recommendation of RFC 2822 then
+				// make sure that you disable line wrapping as FreeSWITCH will ignore
+				// wrapped lines.
+				if v := c.headers[k]; c.name == "sendevent" && strings.ContainsAny(v, "\r\n") {
+					// keep the multi-line values of the events, encoded the same
+					// way as FreeSWITCH encodes them
+					buf.WriteString(urlEncode(v))
+				} else {
+					skipNewLines.WriteString(buf, v) //nolint:errcheck // writing to buffer
+				}
 			}
 		}
 
@@ -89,6 +163,19 @@ func (c command) WriteTo(w io.Writer) (int64, error) {
 	})
 }
 
+// Line returns the first line of the command: its name with parameters.
+// The password of the auth command is hidden.
+func (c command) Line() string {
+	switch {
+	case c.params == "":
+		return c.name
+	case c.name == "auth":
+		return c.name + " *****"
+	default:
+		return c.name + " " + c.params
+	}
+}
+
 // String returns the string representation of the command.
 func (c command) String() string {
 	return writeStr(c)
@@ -114,6 +201,11 @@ func (c command) LogValue() slog.Value {
 	return slog.GroupValue(attr...)
 }
 
+// skipNewLines replaces newline characters with spaces when writing
+// message headers. This ensures headers do not contain newlines, as
+// required by the FreeSWITCH messaging protocol.
+var skipNewLines = strings.NewReplacer("\r\n", " ", "\n", " ") //nolint:gochecknoglobals
+
 // IsZero checks if the command is zero.
 func (c command) IsZero() bool {
 	return c.name == ""
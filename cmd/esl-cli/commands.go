@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdigger/esl"
+)
+
+// errExit is returned by execute for the exit commands.
+var errExit = errors.New("exit")
+
+const help = `Commands:
+  <command>                    run the API command
+  /api <command>               run the API command
+  /bgapi <command>             run the API command in the background
+  /event [names...]            subscribe to the events or list subscriptions
+  /nixevent <names...>         unsubscribe from the events
+  /noevents                    unsubscribe from all events
+  /filter [<header> <value>]   add the event filter or list filters
+  /filter delete <header> [<value>]
+                               delete the event filter
+  /log [<level>]               show the console log, debug by default
+  /nolog                       hide the console log
+  /help                        show this help
+  /exit, /quit, /bye           exit`
+
+// ANSI colors of the output.
+const (
+	colorRed     = "31"
+	colorGreen   = "32"
+	colorYellow  = "33"
+	colorMagenta = "35"
+	colorCyan    = "36"
+	colorGray    = "90"
+)
+
+// palette colors the output text, if enabled.
+type palette bool
+
+// paint returns the text in the color.
+func (p palette) paint(color, text string) string {
+	if !p || text == "" {
+		return text
+	}
+
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
+
+// shell executes the commands of the user.
+type shell struct {
+	client *esl.Client
+	colors palette
+	print  func(text string)
+
+	mu   sync.Mutex
+	jobs map[string]string // the running background jobs by the Job-UUID
+}
+
+// execute runs the command line. The lines starting with the slash are the
+// shell commands, the other are the API commands.
+func (s *shell) execute(line string) error { //nolint:cyclop
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(line, "/") {
+		return s.api(line)
+	}
+
+	name, args, _ := strings.Cut(line[1:], " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(name) {
+	case "exit", "quit", "bye":
+		return errExit
+	case "help", "?":
+		s.print(help)
+
+		return nil
+	case "api":
+		return s.api(args)
+	case "bgapi":
+		return s.bgapi(args)
+	case "event":
+		return s.event(args)
+	case "nixevent":
+		return s.nixevent(args)
+	case "noevents":
+		return s.ok(s.client.Unsubscribe())
+	case "filter":
+		return s.filter(args)
+	case "log":
+		return s.log(args)
+	case "nolog":
+		return s.ok(s.client.NoLog())
+	default:
+		return fmt.Errorf("unknown command /%s, see /help", name) //nolint:err113
+	}
+}
+
+// api runs the API command and prints the result.
+func (s *shell) api(command string) error {
+	if command == "" {
+		return errors.New("command is required") //nolint:err113
+	}
+
+	result, err := s.client.API(command)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.print(result)
+
+	return nil
+}
+
+// bgapi runs the background job. Its result is printed when the job is done.
+func (s *shell) bgapi(command string) error {
+	if command == "" {
+		return errors.New("command is required") //nolint:err113
+	}
+
+	s.mu.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]string)
+
+		// subscribe once to receive the results of the jobs
+		if err := s.client.Subscribe("BACKGROUND_JOB"); err != nil {
+			s.jobs = nil
+			s.mu.Unlock()
+
+			return err //nolint:wrapcheck
+		}
+	}
+	s.mu.Unlock()
+
+	// register the job before it is started, so its result is not missed
+	id := newJobID()
+
+	s.mu.Lock()
+	s.jobs[id] = command
+	s.mu.Unlock()
+
+	if err := s.client.JobWithID(command, id); err != nil {
+		s.mu.Lock()
+		delete(s.jobs, id)
+		s.mu.Unlock()
+
+		return err //nolint:wrapcheck
+	}
+
+	s.print(s.colors.paint(colorGreen, "+OK Job-UUID: "+id))
+
+	return nil
+}
+
+// newJobID returns the random UUID for the background job.
+func newJobID() string {
+	var b [16]byte
+
+	rand.Read(b[:]) //nolint:errcheck // never fails
+
+	b[6] = b[6]&0x0f | 0x40 //nolint:mnd // version 4
+	b[8] = b[8]&0x3f | 0x80 //nolint:mnd // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// event subscribes to the events or lists the subscriptions without names.
+// The format names, as accepted by fs_cli, are ignored.
+func (s *shell) event(args string) error {
+	var names []string
+
+	for _, name := range strings.Fields(args) {
+		switch strings.ToLower(name) {
+		case "plain", "json", "xml":
+		default:
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		s.print("Subscriptions: " + strings.Join(s.client.Subscriptions(), " "))
+
+		return nil
+	}
+
+	return s.ok(s.client.Subscribe(names...))
+}
+
+// nixevent cancels the subscriptions to the events.
+func (s *shell) nixevent(args string) error {
+	names := strings.Fields(args)
+	if len(names) == 0 {
+		return errors.New("event names are required") //nolint:err113
+	}
+
+	return s.ok(s.client.Unsubscribe(names...))
+}
+
+// filter adds or deletes the filter, or lists the filters without arguments.
+func (s *shell) filter(args string) error {
+	if args == "" {
+		var b strings.Builder
+
+		b.WriteString("Filters:")
+
+		for _, f := range s.client.Filters() {
+			b.WriteString("\n  " + f.Header + " " + f.Value)
+		}
+
+		s.print(b.String())
+
+		return nil
+	}
+
+	if rest, ok := strings.CutPrefix(args, "delete "); ok {
+		header, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+
+		return s.ok(s.client.FilterDelete(header, strings.TrimSpace(value)))
+	}
+
+	header, value, ok := strings.Cut(args, " ")
+	if !ok {
+		return errors.New("filter value is required") //nolint:err113
+	}
+
+	return s.ok(s.client.Filter(header, strings.TrimSpace(value)))
+}
+
+// log enables the console log at the level, debug by default.
+func (s *shell) log(args string) error {
+	level := esl.LogDebug
+
+	if args != "" {
+		var err error
+		if level, err = esl.ParseLogLevel(args); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	if err := s.client.Log(level); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.print(s.colors.paint(colorGreen, "+OK log level "+level.String()))
+
+	return nil
+}
+
+// ok prints the success of the command without the output.
+func (s *shell) ok(err error) error {
+	if err == nil {
+		s.print(s.colors.paint(colorGreen, "+OK"))
+	}
+
+	return err
+}
+
+// logColors are the colors of the log lines by the level, as used by fs_cli.
+var logColors = map[esl.LogLevel]string{ //nolint:gochecknoglobals
+	esl.LogAlert:   colorRed,
+	esl.LogCrit:    colorRed,
+	esl.LogErr:     colorRed,
+	esl.LogWarning: colorMagenta,
+	esl.LogNotice:  colorCyan,
+	esl.LogInfo:    colorGreen,
+	esl.LogDebug:   colorYellow,
+}
+
+// showLog prints the console log line in the color of its level.
+func (s *shell) showLog(line esl.LogLine) {
+	if color, ok := logColors[line.Level]; ok {
+		s.print(s.colors.paint(color, line.Text))
+	} else {
+		s.print(line.Text)
+	}
+}
+
+// running returns the number of the background jobs waiting for the result.
+func (s *shell) running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.jobs)
+}
+
+// showEvent prints the event. The results of the background jobs started by
+// the shell are printed with the command.
+func (s *shell) showEvent(e esl.Event) {
+	if e.Name() == "BACKGROUND_JOB" {
+		id := e.Get("Job-UUID")
+
+		s.mu.Lock()
+		command, ok := s.jobs[id]
+		delete(s.jobs, id)
+		s.mu.Unlock()
+
+		if ok {
+			s.print(s.colors.paint(colorYellow, "Job "+id+" ("+command+"):") + "\n" + e.Body())
+
+			return
+		}
+	}
+
+	ts := e.Timestamp()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	title := s.colors.paint(colorGray, ts.Format(time.TimeOnly)) + " " +
+		s.colors.paint(colorCyan, "[EVENT] "+e.Name())
+
+	s.print(title + "\n" + e.String() + "\n")
+}
@@ -0,0 +1,238 @@
+// The esl-cli command is the interactive FreeSWITCH console, similar to
+// fs_cli.
+//
+// The lines are run as the API commands, and the lines starting with the slash
+// are the console commands, see /help. The events, the console log and the
+// background job results are shown between the prompts.
+//
+// With -x, the commands are run one by one and the command exits after the
+// results of the background jobs are received, which is useful in scripts:
+//
+//	esl-cli -x "status" -x "/bgapi reloadxml"
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/mdigger/esl"
+	"github.com/mdigger/esl/internal/env"
+)
+
+func main() {
+	if err := run(); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// commandFlags are the commands given with the repeated flag.
+type commandFlags []string
+
+func (c *commandFlags) String() string {
+	return strings.Join(*c, "; ")
+}
+
+func (c *commandFlags) Set(s string) error {
+	*c = append(*c, s)
+
+	return nil
+}
+
+func run() error { //nolint:cyclop,funlen
+	if err := env.Load(".env"); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	home, _ := os.UserHomeDir()
+
+	cfg := struct {
+		addr, password, history string
+		commands                commandFlags
+		noColor, debug          bool
+	}{
+		addr:     os.Getenv("ESL_ADDR"),
+		password: env.Default("ESL_PASSWORD", "ClueCon"),
+		history:  env.Default("ESL_CLI_HISTORY", filepath.Join(home, ".esl_cli_history")),
+		commands: nil,
+		noColor:  os.Getenv("NO_COLOR") != "",
+		debug:    false,
+	}
+
+	flag.StringVar(&cfg.addr, "addr", cfg.addr, "FreeSWITCH address")
+	flag.StringVar(&cfg.password, "password", cfg.password, "FreeSWITCH password")
+	flag.Var(&cfg.commands, "x", "run the `command` and exit, may be repeated")
+	flag.StringVar(&cfg.history, "history", cfg.history, "history file, none if empty")
+	flag.BoolVar(&cfg.noColor, "no-color", cfg.noColor, "disable colored output")
+	flag.BoolVar(&cfg.debug, "debug", cfg.debug, "show the client log")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if cfg.debug {
+		logger = slog.Default()
+	}
+
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer done()
+
+	// one-shot mode: run the commands and exit on the first error
+	if len(cfg.commands) > 0 {
+		return runCommands(ctx, cfg.addr, cfg.password, cfg.commands, logger)
+	}
+
+	editor := newLineEditor(os.Stdin, os.Stdout, "")
+	defer editor.Close()
+	colors := palette(!cfg.noColor && editor.terminal)
+
+	events := make(chan esl.Event, 100) //nolint:mnd
+	logs := make(chan esl.LogLine, 100) //nolint:mnd
+
+	client, err := esl.Connect(cfg.addr, cfg.password,
+		esl.WithEvents(events, true),
+		esl.WithLogs(logs, true),
+		esl.WithLog(logger),
+	)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	editor.prompt = colors.paint(colorGreen, "freeswitch@"+hostname(client)+"> ")
+
+	if cfg.history != "" {
+		if err := editor.LoadHistory(cfg.history); err != nil {
+			editor.Print(colors.paint(colorRed, err.Error()))
+		}
+
+		defer func() {
+			if err := editor.SaveHistory(cfg.history); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	sh := &shell{
+		client: client,
+		colors: colors,
+		print:  editor.Print,
+		jobs:   nil,
+	}
+
+	go func() {
+		for line := range logs {
+			sh.showLog(line)
+		}
+	}()
+
+	go func() {
+		for e := range events {
+			sh.showEvent(e)
+		}
+
+		if ctx.Err() == nil {
+			editor.Print(colors.paint(colorRed, "Connection to FreeSWITCH closed"))
+			done()
+		}
+	}()
+
+	editor.Print(`Type /help to see the commands, /exit or Ctrl-D to exit.`)
+
+	lines := make(chan error)
+
+	for {
+		go func() {
+			line, err := editor.ReadLine()
+			if err == nil {
+				err = sh.execute(line)
+			}
+
+			lines <- err
+		}()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-lines:
+			switch {
+			case err == nil, errors.Is(err, errInterrupted):
+			case errors.Is(err, errExit), errors.Is(err, io.EOF):
+				done() // not to report the closed connection
+
+				return nil
+			default:
+				editor.Print(colors.paint(colorRed, err.Error()))
+			}
+		}
+	}
+}
+
+// runCommands runs the commands one by one and exits on the first error. It
+// waits for the results of the background jobs started with /bgapi.
+func runCommands(ctx context.Context, addr, password string, commands []string, logger *slog.Logger) error {
+	events := make(chan esl.Event, 100) //nolint:mnd
+
+	client, err := esl.Connect(addr, password, esl.WithEvents(events, true), esl.WithLog(logger))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	sh := &shell{
+		client: client,
+		colors: false,
+		print:  func(text string) { fmt.Println(strings.TrimRight(text, "\n")) },
+		jobs:   nil,
+	}
+
+	finished := make(chan struct{}, 1)
+
+	go func() {
+		for e := range events {
+			sh.showEvent(e)
+
+			select {
+			case finished <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	for _, command := range commands {
+		if err := sh.execute(command); errors.Is(err, errExit) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for sh.running() > 0 {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return nil
+		case <-client.Done():
+			return errors.New("connection to FreeSWITCH closed") //nolint:err113
+		}
+	}
+
+	return nil
+}
+
+// hostname returns the name of the FreeSWITCH host for the prompt.
+func hostname(client *esl.Client) string {
+	name, err := client.API("global_getvar hostname")
+	if name = strings.TrimSpace(name); err != nil || name == "" {
+		return "localhost"
+	}
+
+	return name
+}
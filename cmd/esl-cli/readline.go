@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// errInterrupted is returned by ReadLine when the line is canceled with Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// maxHistory is the number of the lines kept in the history.
+const maxHistory = 1000
+
+// The special keys returned by readKey.
+const (
+	keyUnknown rune = -iota - 1
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// The control keys.
+const (
+	ctrlA     = 0x01
+	ctrlB     = 0x02
+	ctrlC     = 0x03
+	ctrlD     = 0x04
+	ctrlE     = 0x05
+	ctrlF     = 0x06
+	ctrlH     = 0x08
+	ctrlK     = 0x0b
+	ctrlL     = 0x0c
+	ctrlN     = 0x0e
+	ctrlP     = 0x10
+	ctrlU     = 0x15
+	ctrlW     = 0x17
+	escape    = 0x1b
+	backspace = 0x7f
+)
+
+// lineEditor reads the lines from the terminal with the basic editing and
+// history. The output printed with Print while the line is edited appears
+// above the prompt, which is redrawn after it.
+//
+// If the input is not a terminal, the lines are read as is, without prompt.
+type lineEditor struct {
+	mu       sync.Mutex
+	fd       uintptr
+	in       *bufio.Reader
+	out      io.Writer
+	terminal bool
+	prompt   string
+	line     []rune
+	pos      int  // cursor position in the line
+	reading  bool // the prompt is shown
+	history  []string
+	index    int    // the history line shown, len(history) for the new line
+	edited   []rune // the new line saved while browsing the history
+	restore  func() error
+}
+
+// newLineEditor returns the line editor for the input and output.
+func newLineEditor(in *os.File, out io.Writer, prompt string) *lineEditor {
+	return &lineEditor{
+		mu:       sync.Mutex{},
+		fd:       in.Fd(),
+		in:       bufio.NewReader(in),
+		out:      out,
+		terminal: isTerminal(in.Fd()),
+		prompt:   prompt,
+		line:     nil,
+		pos:      0,
+		reading:  false,
+		history:  nil,
+		index:    0,
+		edited:   nil,
+		restore:  nil,
+	}
+}
+
+// ReadLine reads the line. It returns errInterrupted on Ctrl-C and io.EOF on
+// Ctrl-D with the empty line or at the end of the input.
+func (l *lineEditor) ReadLine() (string, error) {
+	if !l.terminal {
+		line, err := l.in.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			return "", err //nolint:wrapcheck
+		}
+
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	restore, err := makeRaw(l.fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set terminal mode: %w", err)
+	}
+
+	l.mu.Lock()
+	l.restore = restore
+	l.line, l.pos, l.index, l.edited, l.reading = nil, 0, len(l.history), nil, true
+	l.redraw()
+	l.mu.Unlock()
+
+	defer l.Close()
+
+	for {
+		key, err := l.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		l.mu.Lock()
+		line, done, err := l.edit(key)
+		l.mu.Unlock()
+
+		if done || err != nil {
+			return line, err
+		}
+	}
+}
+
+// Close restores the terminal mode, if the line is being read. It is used to
+// end the editing when ReadLine is still waiting for the input.
+func (l *lineEditor) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.restore == nil {
+		return nil
+	}
+
+	if l.reading {
+		l.finish("\n")
+	}
+
+	err := l.restore()
+	l.restore = nil
+
+	return err
+}
+
+// Print writes the text above the edited line.
+func (l *lineEditor) Print(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reading {
+		io.WriteString(l.out, "\r\x1b[K") //nolint:errcheck
+	}
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	io.WriteString(l.out, text) //nolint:errcheck
+
+	if l.reading {
+		l.redraw()
+	}
+}
+
+// addHistory appends the line to the history, skipping the empty lines and
+// the repeats of the last one.
+func (l *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" ||
+		(len(l.history) > 0 && l.history[len(l.history)-1] == line) {
+		return
+	}
+
+	l.history = append(l.history, line)
+	if len(l.history) > maxHistory {
+		l.history = l.history[len(l.history)-maxHistory:]
+	}
+}
+
+// LoadHistory reads the history from the file. The missing file is ignored.
+func (l *lineEditor) LoadHistory(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range strings.Split(string(data), "\n") {
+		l.addHistory(line)
+	}
+
+	return nil
+}
+
+// SaveHistory writes the history to the file.
+func (l *lineEditor) SaveHistory(filename string) error {
+	l.mu.Lock()
+	data := strings.Join(l.history, "\n") + "\n"
+	empty := len(l.history) == 0
+	l.mu.Unlock()
+
+	if empty {
+		return nil
+	}
+
+	//nolint:mnd // rw-------
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+
+	return nil
+}
+
+// readKey reads the key, decoding the escape sequences of the special keys.
+func (l *lineEditor) readKey() (rune, error) {
+	r, _, err := l.in.ReadRune()
+	if err != nil || r != escape {
+		return r, err //nolint:wrapcheck
+	}
+
+	if r, _, err = l.in.ReadRune(); err != nil || (r != '[' && r != 'O') {
+		return keyUnknown, err //nolint:wrapcheck
+	}
+
+	var seq []rune
+
+	for len(seq) < 8 { //nolint:mnd // longest sequence
+		if r, _, err = l.in.ReadRune(); err != nil {
+			return keyUnknown, err //nolint:wrapcheck
+		}
+
+		if seq = append(seq, r); r < '0' || r > '9' && r != ';' {
+			break // final character
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	default:
+		return keyUnknown, nil
+	}
+}
+
+// edit applies the key to the edited line. It returns the line when the input
+// is done.
+func (l *lineEditor) edit(key rune) (string, bool, error) { //nolint:cyclop,funlen
+	switch key {
+	case '\r', '\n':
+		line := string(l.line)
+		l.finish("\n")
+		l.addHistory(line)
+
+		return line, true, nil
+	case ctrlC:
+		l.finish("^C\n")
+
+		return "", true, errInterrupted
+	case ctrlD:
+		if len(l.line) == 0 {
+			l.finish("\n")
+
+			return "", true, io.EOF
+		}
+
+		l.delete(l.pos, l.pos+1)
+	case ctrlH, backspace:
+		l.delete(l.pos-1, l.pos)
+	case keyDelete:
+		l.delete(l.pos, l.pos+1)
+	case ctrlA, keyHome:
+		l.pos = 0
+	case ctrlE, keyEnd:
+		l.pos = len(l.line)
+	case ctrlB, keyLeft:
+		l.pos = max(l.pos-1, 0)
+	case ctrlF, keyRight:
+		l.pos = min(l.pos+1, len(l.line))
+	case ctrlU:
+		l.delete(0, l.pos)
+	case ctrlK:
+		l.delete(l.pos, len(l.line))
+	case ctrlW:
+		start := l.pos
+		for start > 0 && unicode.IsSpace(l.line[start-1]) {
+			start--
+		}
+
+		for start > 0 && !unicode.IsSpace(l.line[start-1]) {
+			start--
+		}
+
+		l.delete(start, l.pos)
+	case ctrlL:
+		io.WriteString(l.out, "\x1b[H\x1b[2J") //nolint:errcheck
+	case ctrlP, keyUp:
+		l.browse(l.index - 1)
+	case ctrlN, keyDown:
+		l.browse(l.index + 1)
+	default:
+		if !unicode.IsPrint(key) {
+			return "", false, nil // ignore unsupported keys
+		}
+
+		l.line = append(l.line[:l.pos], append([]rune{key}, l.line[l.pos:]...)...)
+		l.pos++
+	}
+
+	l.redraw()
+
+	return "", false, nil
+}
+
+// delete removes the characters of the line in the range, moving the cursor
+// to its start.
+func (l *lineEditor) delete(from, to int) {
+	from, to = max(from, 0), min(to, len(l.line))
+	if from >= to {
+		return
+	}
+
+	l.line = append(l.line[:from], l.line[to:]...)
+	l.pos = from
+}
+
+// browse shows the history line with the index.
+func (l *lineEditor) browse(index int) {
+	if index < 0 || index > len(l.history) || index == l.index {
+		return
+	}
+
+	if l.index == len(l.history) {
+		l.edited = l.line
+	}
+
+	l.index = index
+
+	if index == len(l.history) {
+		l.line = l.edited
+	} else {
+		l.line = []rune(l.history[index])
+	}
+
+	l.pos = len(l.line)
+}
+
+// finish ends the line editing with the text.
+func (l *lineEditor) finish(text string) {
+	l.reading = false
+	io.WriteString(l.out, text) //nolint:errcheck
+}
+
+// redraw shows the prompt with the edited line and moves the cursor to its
+// position.
+func (l *lineEditor) redraw() {
+	var b strings.Builder
+
+	b.WriteString("\r\x1b[K")
+	b.WriteString(l.prompt)
+	b.WriteString(string(l.line))
+
+	if n := len(l.line) - l.pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", n)
+	}
+
+	io.WriteString(l.out, b.String()) //nolint:errcheck
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	var out strings.Builder
+
+	l := &lineEditor{out: &out, history: []string{"status"}}
+
+	// type the keys and return the line
+	enter := func(keys string) (string, error) {
+		l.in = bufio.NewReader(strings.NewReader(keys))
+		l.line, l.pos, l.index, l.edited, l.reading = nil, 0, len(l.history), nil, true
+
+		for {
+			key, err := l.readKey()
+			if err != nil {
+				return "", err
+			}
+
+			if line, done, err := l.edit(key); done || err != nil {
+				return line, err
+			}
+		}
+	}
+
+	tests := []struct {
+		keys string
+		want string
+	}{
+		{"show calls\r", "show calls"},
+		{"sho\x1b[D\x1b[Dx\x1b[Fw\r", "sxhow"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"one two\x17three\r", "one three"},
+		{"abc\x01\x1b[3~\x0bxyz\r", "xyz"},
+		{"\x1b[A\x1b[A\r", "one three"},
+		{"new\x10\x0e\r", "new"},
+	}
+
+	for _, tt := range tests {
+		if got, err := enter(tt.keys); err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.keys, got, err, tt.want)
+		}
+	}
+
+	if _, err := enter("abc\x03"); !errors.Is(err, errInterrupted) {
+		t.Errorf("Ctrl-C: %v", err)
+	}
+
+	if _, err := enter("\x04"); !errors.Is(err, io.EOF) {
+		t.Errorf("Ctrl-D: %v", err)
+	}
+
+	got := strings.Join(l.history, ",")
+	if want := "status,show calls,sxhow,ad,one three,xyz,one three,new"; got != want {
+		t.Errorf("unexpected history: %q", got)
+	}
+}
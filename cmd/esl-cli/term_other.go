@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// isTerminal reports whether the file descriptor is a terminal. The line
+// editing is not supported on this platform, so it always returns false.
+func isTerminal(uintptr) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform.
+func makeRaw(uintptr) (func() error, error) {
+	return nil, errors.New("raw terminal mode is not supported") //nolint:err113
+}
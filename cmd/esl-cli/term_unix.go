@@ -0,0 +1,64 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// getTermios returns the terminal settings of the file descriptor.
+func getTermios(fd uintptr) (*syscall.Termios, error) {
+	var t syscall.Termios
+
+	//nolint:gosec // ioctl with the pointer to the termios structure
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios,
+		uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return nil, errno
+	}
+
+	return &t, nil
+}
+
+// setTermios changes the terminal settings of the file descriptor.
+func setTermios(fd uintptr, t *syscall.Termios) error {
+	//nolint:gosec // ioctl with the pointer to the termios structure
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios,
+		uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// isTerminal reports whether the file descriptor is a terminal.
+func isTerminal(fd uintptr) bool {
+	_, err := getTermios(fd)
+
+	return err == nil
+}
+
+// makeRaw puts the terminal into the raw mode and returns the function
+// restoring the previous state. The output processing is kept, so the new
+// lines are still translated by the terminal.
+func makeRaw(fd uintptr) (func() error, error) {
+	old, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := setTermios(fd, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() error { return setTermios(fd, old) }, nil
+}
@@ -0,0 +1,125 @@
+// The esl-gateway command exposes the FreeSWITCH API commands and event
+// streams over HTTP, Server-Sent Events and WebSocket.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mdigger/esl"
+	"github.com/mdigger/esl/gateway"
+	"github.com/mdigger/esl/internal/env"
+)
+
+func main() {
+	if err := run(); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("ended", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := env.Load(".env"); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	cfg := struct {
+		addr, password, listen, tokens, allow, origins string
+	}{
+		addr:     os.Getenv("ESL_ADDR"),
+		password: env.Default("ESL_PASSWORD", "ClueCon"),
+		listen:   env.Default("GATEWAY_LISTEN", ":8080"),
+		tokens:   os.Getenv("GATEWAY_TOKENS"),
+		allow:    env.Default("GATEWAY_ALLOW", "status,uptime,version,show channels,show calls"),
+		origins:  os.Getenv("GATEWAY_ORIGINS"),
+	}
+
+	flag.StringVar(&cfg.addr, "addr", cfg.addr, "FreeSWITCH address")
+	flag.StringVar(&cfg.password, "password", cfg.password, "FreeSWITCH password")
+	flag.StringVar(&cfg.listen, "listen", cfg.listen, "HTTP server address")
+	flag.StringVar(&cfg.tokens, "tokens", cfg.tokens, "comma-separated access tokens, no auth if empty")
+	flag.StringVar(&cfg.allow, "allow", cfg.allow, "comma-separated allowed API commands, all if empty")
+	flag.StringVar(&cfg.origins, "origins", cfg.origins, "comma-separated allowed WebSocket origins besides the same origin")
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer done()
+
+	events := make(chan esl.Event, 100) //nolint:mnd
+
+	client, err := esl.Connect(cfg.addr, cfg.password,
+		esl.WithEvents(events, true),
+		esl.WithLog(slog.Default()),
+	)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	opts := []gateway.Option{
+		gateway.WithTokens(splitList(cfg.tokens)...),
+		gateway.WithOrigins(splitList(cfg.origins)...),
+		gateway.WithLog(slog.Default()),
+	}
+
+	if allow := splitList(cfg.allow); len(allow) > 0 {
+		opts = append(opts, gateway.WithAllowedCommands(allow...))
+	}
+
+	gw := gateway.New(client, opts...)
+	defer gw.Close()
+
+	go func() {
+		defer done() // the connection is closed
+
+		if err := esl.Pipe(ctx, events, gw); err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("events", slog.String("err", err.Error()))
+		}
+	}()
+
+	srv := &http.Server{ //nolint:exhaustruct
+		Addr:              cfg.listen,
+		Handler:           gw,
+		ReadHeaderTimeout: 10 * time.Second, //nolint:mnd
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		gw.Close() // end the streams before the shutdown
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:mnd
+		defer cancel()
+
+		srv.Shutdown(shutdownCtx) //nolint:errcheck,contextcheck
+	}()
+
+	slog.Info("gateway", slog.String("listen", cfg.listen))
+
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err //nolint:wrapcheck
+	}
+
+	return ctx.Err() //nolint:wrapcheck
+}
+
+// splitList returns the non-empty trimmed elements of the comma-separated list.
+func splitList(s string) []string {
+	var list []string
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,170 @@
+// The esl-proxy command multiplexes many ESL clients onto one connection to
+// FreeSWITCH.
+//
+// The accounts of the clients are read from the JSON file:
+//
+//	[
+//	  {"name": "monitor", "password": "secret", "allow": ["status", "show channels"]},
+//	  {"name": "admin", "password": "admin"}
+//	]
+//
+// Without the file, the single account with PROXY_PASSWORD is used.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mdigger/esl"
+	"github.com/mdigger/esl/internal/env"
+)
+
+func main() {
+	if err := run(); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("ended", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := env.Load(".env"); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	cfg := struct {
+		addr, password, listen, accounts, proxyPassword, allow, audit string
+	}{
+		addr:          os.Getenv("ESL_ADDR"),
+		password:      env.Default("ESL_PASSWORD", "ClueCon"),
+		listen:        env.Default("PROXY_LISTEN", ":8022"),
+		accounts:      os.Getenv("PROXY_ACCOUNTS"),
+		proxyPassword: env.Default("PROXY_PASSWORD", "ClueCon"),
+		allow:         os.Getenv("PROXY_ALLOW"),
+		audit:         os.Getenv("PROXY_AUDIT"),
+	}
+
+	flag.StringVar(&cfg.addr, "addr", cfg.addr, "FreeSWITCH address")
+	flag.StringVar(&cfg.password, "password", cfg.password, "FreeSWITCH password")
+	flag.StringVar(&cfg.listen, "listen", cfg.listen, "proxy listen address")
+	flag.StringVar(&cfg.accounts, "accounts", cfg.accounts, "JSON file with the client accounts")
+	flag.StringVar(&cfg.proxyPassword, "proxy-password", cfg.proxyPassword, "client password without the accounts file")
+	flag.StringVar(&cfg.allow, "allow", cfg.allow, "comma-separated allowed API commands without the accounts file")
+	flag.StringVar(&cfg.audit, "audit", cfg.audit, "audit log file, stderr if empty")
+	flag.Parse()
+
+	accounts, err := loadAccounts(cfg.accounts)
+	if err != nil {
+		return err
+	}
+
+	if accounts == nil {
+		accounts = []esl.ProxyAccount{{
+			Name:     "default",
+			Password: cfg.proxyPassword,
+			Allow:    splitList(cfg.allow),
+		}}
+	}
+
+	audit := os.Stderr
+	if cfg.audit != "" {
+		//nolint:mnd // rw-r-----
+		if audit, err = os.OpenFile(cfg.audit, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer audit.Close()
+	}
+
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer done()
+
+	events := make(chan esl.Event, 1000) //nolint:mnd
+
+	client, err := esl.Connect(cfg.addr, cfg.password,
+		esl.WithEvents(events, true),
+		esl.WithLog(slog.Default()),
+	)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	opts := []esl.ProxyOption{
+		esl.WithProxyAudit(slog.New(slog.NewJSONHandler(audit, nil))),
+		esl.WithProxyLog(slog.Default()),
+	}
+
+	for _, account := range accounts {
+		opts = append(opts, esl.WithProxyAccount(account))
+	}
+
+	proxy := esl.NewProxy(client, opts...)
+	defer proxy.Close()
+
+	go esl.Pipe(ctx, events, proxy) //nolint:errcheck // ends with the connection
+
+	ln, err := net.Listen("tcp", cfg.listen)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	go func() {
+		<-ctx.Done()
+		proxy.Close()
+	}()
+
+	slog.Info("proxy", slog.String("listen", cfg.listen), slog.Int("accounts", len(accounts)))
+
+	err = proxy.Serve(ln)
+	if errors.Is(err, esl.ErrProxyClosed) {
+		select {
+		case <-client.Done():
+			return errors.New("connection to FreeSWITCH closed") //nolint:err113
+		default:
+			return ctx.Err() //nolint:wrapcheck
+		}
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// loadAccounts reads the accounts from the JSON file. It returns nil if the
+// file name is empty.
+func loadAccounts(filename string) ([]esl.ProxyAccount, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+
+	var accounts []esl.ProxyAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// splitList returns the non-empty trimmed elements of the comma-separated list.
+func splitList(s string) []string {
+	var list []string
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
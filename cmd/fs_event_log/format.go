@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mdigger/esl"
+)
+
+// defaultColumns are the headers shown in the table and csv formats, unless
+// the headers are selected explicitly.
+var defaultColumns = []string{ //nolint:gochecknoglobals
+	"Event-Date-Local", "Event-Name", "Unique-ID",
+	"Caller-Caller-ID-Number", "Caller-Destination-Number",
+}
+
+// columnWidth is the minimal width of the table column.
+const columnWidth = 16
+
+// formatter converts the events to the output format.
+type formatter struct {
+	format  string
+	headers []string // selected headers, all if empty
+	started bool     // the header line of the table or csv is written
+}
+
+// newFormatter returns the formatter for the format and the selected headers.
+func newFormatter(format string, headers []string) (*formatter, error) {
+	switch format {
+	case "json", "plain", "logfmt":
+	case "table", "csv":
+		if len(headers) == 0 {
+			headers = defaultColumns
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", format)
+	}
+
+	return &formatter{format: format, headers: headers, started: false}, nil
+}
+
+// Format returns the event in the output format, ending with the new line.
+func (f *formatter) Format(e esl.Event) []byte {
+	var buf bytes.Buffer
+
+	switch f.format {
+	case "plain":
+		f.plain(&buf, e)
+	case "table":
+		f.table(&buf, e)
+	case "csv":
+		f.csv(&buf, e)
+	case "logfmt":
+		f.logfmt(&buf, e)
+	default:
+		f.json(&buf, e)
+	}
+
+	return buf.Bytes()
+}
+
+// keys returns the selected headers or all headers of the event.
+func (f *formatter) keys(e esl.Event) []string {
+	if len(f.headers) > 0 {
+		return f.headers
+	}
+
+	return e.Headers()
+}
+
+// value returns the header value. The Event-Name of the custom events is
+// shown with the subclass name.
+func value(e esl.Event, key string) string {
+	if key == "Event-Name" {
+		return e.Name()
+	}
+
+	return e.Get(key)
+}
+
+func (f *formatter) json(buf *bytes.Buffer, e esl.Event) {
+	enc := json.NewEncoder(buf)
+
+	if len(f.headers) == 0 {
+		enc.Encode(e) //nolint:errcheck,errchkjson
+
+		return
+	}
+
+	projection := make(map[string]any, len(f.headers))
+
+	for _, key := range f.headers {
+		switch values := e.Values(key); len(values) {
+		case 0:
+			continue
+		case 1:
+			projection[key] = values[0]
+		default:
+			projection[key] = values
+		}
+	}
+
+	enc.Encode(projection) //nolint:errcheck,errchkjson
+}
+
+func (f *formatter) plain(buf *bytes.Buffer, e esl.Event) {
+	if len(f.headers) == 0 {
+		buf.WriteString(e.String())
+		buf.WriteByte('\n')
+
+		return
+	}
+
+	for _, key := range f.headers {
+		if v := e.Get(key); v != "" {
+			buf.WriteString(key + ": " + v + "\n")
+		}
+	}
+
+	buf.WriteByte('\n')
+}
+
+func (f *formatter) table(buf *bytes.Buffer, e esl.Event) {
+	row := func(cells func(i int) string) {
+		for i, key := range f.headers {
+			width := max(len(key), columnWidth)
+			cell := truncate(cells(i), width)
+
+			if i == len(f.headers)-1 {
+				buf.WriteString(cell)
+
+				break
+			}
+
+			buf.WriteString(cell)
+			buf.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(cell)+2)) //nolint:mnd // gap
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	if !f.started {
+		f.started = true
+
+		row(func(i int) string { return f.headers[i] })
+	}
+
+	row(func(i int) string { return value(e, f.headers[i]) })
+}
+
+func (f *formatter) csv(buf *bytes.Buffer, e esl.Event) {
+	w := csv.NewWriter(buf)
+
+	if !f.started {
+		f.started = true
+
+		w.Write(f.headers) //nolint:errcheck // writing to buffer
+	}
+
+	record := make([]string, len(f.headers))
+	for i, key := range f.headers {
+		record[i] = value(e, key)
+	}
+
+	w.Write(record) //nolint:errcheck // writing to buffer
+	w.Flush()
+}
+
+func (f *formatter) logfmt(buf *bytes.Buffer, e esl.Event) {
+	for _, key := range f.keys(e) {
+		v := value(e, key)
+		if v == "" && len(f.headers) > 0 {
+			continue // missing selected header
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(key)
+		buf.WriteByte('=')
+
+		if needsQuote(v) {
+			buf.WriteString(strconv.Quote(v))
+		} else {
+			buf.WriteString(v)
+		}
+	}
+
+	buf.WriteByte('\n')
+}
+
+// needsQuote reports whether the logfmt value must be quoted.
+func needsQuote(s string) bool {
+	return s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r)
+	}) >= 0
+}
+
+// truncate shortens the string to the width, marking it with the ellipsis.
+func truncate(s string, width int) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+
+		return r
+	}, s)
+
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:width-1]) + "…"
+}
+
+// splitList returns the non-empty trimmed elements of the comma-separated list.
+func splitList(s string) []string {
+	var list []string
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
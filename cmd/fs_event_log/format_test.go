@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mdigger/esl"
+)
+
+func TestFormatter(t *testing.T) {
+	e := esl.NewEvent("CHANNEL_ANSWER", map[string]string{
+		"Unique-ID":       "call-1",
+		"Channel-Name":    "sofia/internal/1000@example.com",
+		"Answer-State":    "answered",
+		"Caller-Username": "",
+	}, nil)
+
+	tests := []struct {
+		format  string
+		headers []string
+		want    string
+	}{
+		{"json", []string{"Unique-ID", "Missing"}, "{\"Unique-ID\":\"call-1\"}\n"},
+		{"plain", []string{"Event-Name", "Unique-ID"}, "Event-Name: CHANNEL_ANSWER\nUnique-ID: call-1\n\n"},
+		{"csv", []string{"Event-Name", "Channel-Name"},
+			"Event-Name,Channel-Name\nCHANNEL_ANSWER,sofia/internal/1000@example.com\n"},
+		{"table", []string{"Event-Name", "Unique-ID"},
+			"Event-Name        Unique-ID\nCHANNEL_ANSWER    call-1\n"},
+		{"logfmt", []string{"Unique-ID", "Answer-State", "Channel-Name"},
+			"Unique-ID=call-1 Answer-State=answered Channel-Name=sofia/internal/1000@example.com\n"},
+	}
+
+	for _, tt := range tests {
+		f, err := newFormatter(tt.format, tt.headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := string(f.Format(e)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+
+	f, err := newFormatter("plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	heartbeat := esl.NewEvent("HEARTBEAT", map[string]string{"Unique-ID": "1"}, nil)
+	if got, want := string(f.Format(heartbeat)), "Event-Name: HEARTBEAT\nUnique-ID: 1\n\n"; got != want {
+		t.Errorf("plain: got %q, want %q", got, want)
+	}
+
+	if _, err := newFormatter("xml", nil); err == nil {
+		t.Error("unsupported format is accepted")
+	}
+}
+
+func TestNeedsQuote(t *testing.T) {
+	for s, want := range map[string]bool{
+		"":         true,
+		"answered": false,
+		"a b":      true,
+		"a=b":      true,
+		`"x"`:      true,
+		"x\n":      true,
+	} {
+		if got := needsQuote(s); got != want {
+			t.Errorf("needsQuote(%q) = %v", s, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("abcdefgh", 5); got != "abcd…" {
+		t.Errorf("unexpected truncate result: %q", got)
+	}
+
+	if got := truncate("a\tb", 5); got != "a b" {
+		t.Errorf("unexpected truncate result: %q", got)
+	}
+}
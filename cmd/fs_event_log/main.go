@@ -1,12 +1,21 @@
+// The fs_event_log command writes the FreeSWITCH events to the output.
+//
+// The events are selected by the names given as arguments, the server-side
+// filters (-filter, -uuid) and the client-side expression (-expr). The -uuid
+// is matched on the client side if there are other server-side filters. The
+// output can be limited to the selected headers (-headers) and written in one
+// of the formats: json, plain, table, csv or logfmt.
+//
+// Exit status is 0 when interrupted by a signal, 1 on error, 2 on invalid
+// arguments and 3 when the connection to FreeSWITCH is lost.
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -14,110 +23,188 @@ import (
 	"syscall"
 
 	"github.com/mdigger/esl"
+	"github.com/mdigger/esl/internal/env"
+)
+
+// Exit status codes.
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitDisconnected = 3
+)
+
+var (
+	errUsage        = errors.New("invalid arguments")
+	errDisconnected = errors.New("connection to FreeSWITCH lost")
 )
 
 func main() {
-	if err := run(); err != nil && !errors.Is(err, context.Canceled) {
-		slog.Error("ended", slog.String("err", err.Error()))
-		os.Exit(1)
+	os.Exit(exitCode(run()))
+}
+
+// exitCode logs the error and returns the exit status for it.
+func exitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+
+		return exitUsage
 	}
+
+	slog.Error("ended", slog.String("err", err.Error()))
+
+	if errors.Is(err, errDisconnected) {
+		return exitDisconnected
+	}
+
+	return exitError
+}
+
+// filterFlags are the server-side event filters in the Header=Value form.
+type filterFlags []esl.EventFilter
+
+func (f *filterFlags) String() string {
+	list := make([]string, len(*f))
+	for i, filter := range *f {
+		list[i] = filter.Header + "=" + filter.Value
+	}
+
+	return strings.Join(list, ",")
 }
 
-func run() error {
-	if err := initEnv(".env"); err != nil {
+func (f *filterFlags) Set(s string) error {
+	header, value, ok := strings.Cut(s, "=")
+	if header = strings.TrimSpace(header); !ok || header == "" {
+		return fmt.Errorf("filter must be Header=Value: %q", s) //nolint:err113
+	}
+
+	*f = append(*f, esl.EventFilter{Header: header, Value: value})
+
+	return nil
+}
+
+func run() error { //nolint:cyclop,funlen
+	if err := env.Load(".env"); err != nil {
 		return err
 	}
 
 	cfg := struct {
-		addr, password string
+		addr, password, expr, headers, format, uuid, out string
+		maxSize, maxFiles                                int
+		filters                                          filterFlags
 	}{
 		addr:     os.Getenv("ESL_ADDR"),
-		password: envDefault("ESL_PASSWORD", "ClueCon"),
+		password: env.Default("ESL_PASSWORD", "ClueCon"),
+		expr:     "",
+		headers:  "",
+		format:   "json",
+		uuid:     "",
+		out:      "",
+		maxSize:  100, //nolint:mnd
+		maxFiles: 5,   //nolint:mnd
+		filters:  nil,
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [event names...]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
 
 	flag.StringVar(&cfg.addr, "addr", cfg.addr, "FreeSWITCH address")
 	flag.StringVar(&cfg.password, "password", cfg.password, "FreeSWITCH password")
+	flag.Var(&cfg.filters, "filter", "server-side `Header=Value` filter, may be repeated")
+	flag.StringVar(&cfg.expr, "expr", cfg.expr,
+		`events filter expression, e.g. 'Event-Name == CHANNEL_ANSWER && Caller-Caller-ID-Number =~ "^\+7"'`)
+	flag.StringVar(&cfg.uuid, "uuid", cfg.uuid, "follow the events of the call with `Unique-ID`")
+	flag.StringVar(&cfg.headers, "headers", cfg.headers, "comma-separated headers to output")
+	flag.StringVar(&cfg.format, "format", cfg.format, "output format: json, plain, table, csv or logfmt")
+	flag.StringVar(&cfg.out, "out", cfg.out, "output file, stdout if empty")
+	flag.IntVar(&cfg.maxSize, "max-size", cfg.maxSize, "output file size in `MB` to rotate it, 0 to disable")
+	flag.IntVar(&cfg.maxFiles, "max-files", cfg.maxFiles, "number of rotated output files to keep")
 	flag.Parse()
 
-	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer done()
-
-	events := make(chan esl.Event, 1)
-	go func() {
-		enc := json.NewEncoder(os.Stdout)
-		for ev := range events {
-			if err := enc.Encode(ev); err != nil {
-				slog.Error("failed to encode event", slog.String("err", err.Error()))
+	format, err := newFormatter(cfg.format, splitList(cfg.headers))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
 
-				break
-			}
+	// the server filters are combined with OR, so the call is followed on
+	// the server only if there are no other filters
+	if cfg.uuid != "" && len(cfg.filters) == 0 {
+		cfg.filters = append(cfg.filters, esl.EventFilter{Header: "Unique-ID", Value: cfg.uuid})
+	} else if cfg.uuid != "" {
+		match := fmt.Sprintf("Unique-ID == %q", cfg.uuid)
+		if cfg.expr != "" {
+			match = "(" + cfg.expr + ") && " + match
 		}
 
-		done()
-	}()
-
-	client, err := esl.Connect(cfg.addr, cfg.password,
-		esl.WithEvents(events),
-		esl.WithLog(slog.Default()),
-	)
-	if err != nil {
-		return err //nolint:wrapcheck
+		cfg.expr = match
 	}
-	defer client.Close()
 
-	if err := client.Subscribe(flag.Args()...); err != nil {
-		return err //nolint:wrapcheck
+	var filter *esl.Expr
+
+	if cfg.expr != "" {
+		if filter, err = esl.ParseExpr(cfg.expr); err != nil {
+			return fmt.Errorf("%w: %w", errUsage, err)
+		}
 	}
 
-	<-ctx.Done()
+	var out io.Writer = os.Stdout
 
-	return ctx.Err() //nolint:wrapcheck
-}
+	if cfg.out != "" {
+		file, err := esl.OpenRotatingFile(cfg.out, int64(cfg.maxSize)<<20, cfg.maxFiles) //nolint:mnd // MB
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		defer file.Close()
 
-func envDefault(name, def string) string {
-	if v, ok := os.LookupEnv(name); ok {
-		return v
+		out = file
 	}
 
-	return def
-}
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer done()
+
+	events := make(chan esl.Event, 100) //nolint:mnd
 
-func initEnv(filename string) error {
-	f, err := os.Open(filename)
+	client, err := esl.Connect(cfg.addr, cfg.password,
+		esl.WithEvents(events, true),
+		esl.WithEventFilter(filter),
+		esl.WithLog(slog.Default()),
+	)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	for _, f := range cfg.filters {
+		if err := client.Filter(f.Header, f.Value); err != nil {
+			return err //nolint:wrapcheck
 		}
+	}
 
-		return fmt.Errorf("failed to open env file: %w", err)
+	if err := client.Subscribe(flag.Args()...); err != nil {
+		return err //nolint:wrapcheck
 	}
-	defer f.Close()
-
-	scan := bufio.NewScanner(f)
-	for scan.Scan() {
-		// split to key and value
-		keyValue := strings.SplitN(scan.Text(), "=", 2)
-		if len(keyValue) != 2 {
-			continue
-		}
 
-		// skip comments
-		if strings.HasPrefix(keyValue[1], "#") {
-			continue
-		}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case ev, ok := <-events:
+			if !ok {
+				return errDisconnected
+			}
 
-		// skip end of line comments
-		v, _, _ := strings.Cut(keyValue[1], "#")
+			_, err := out.Write(format.Format(ev))
+			ev.Release()
 
-		// set environment
-		if err := os.Setenv(keyValue[0], strings.TrimSpace(v)); err != nil {
-			return fmt.Errorf("failed to set env: %w", err)
+			if err != nil {
+				return fmt.Errorf("failed to write event: %w", err)
+			}
 		}
 	}
-
-	if err := scan.Err(); err != nil {
-		return fmt.Errorf("failed to parse env file: %w", err)
-	}
-
-	return nil
 }
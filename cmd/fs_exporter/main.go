@@ -0,0 +1,107 @@
+// The fs_exporter command exports the FreeSWITCH metrics, collected from the
+// events, in the Prometheus text exposition format.
+//
+// The command exits when the connection to FreeSWITCH is lost, so that it is
+// restarted by the supervisor and the counters are started from scratch.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mdigger/esl"
+	"github.com/mdigger/esl/internal/env"
+	"github.com/mdigger/esl/metrics"
+)
+
+func main() {
+	if err := run(); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("ended", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := env.Load(".env"); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	cfg := struct {
+		addr, password, listen, path string
+	}{
+		addr:     os.Getenv("ESL_ADDR"),
+		password: env.Default("ESL_PASSWORD", "ClueCon"),
+		listen:   env.Default("EXPORTER_LISTEN", ":9282"),
+		path:     env.Default("EXPORTER_PATH", "/metrics"),
+	}
+
+	flag.StringVar(&cfg.addr, "addr", cfg.addr, "FreeSWITCH address")
+	flag.StringVar(&cfg.password, "password", cfg.password, "FreeSWITCH password")
+	flag.StringVar(&cfg.listen, "listen", cfg.listen, "HTTP server address")
+	flag.StringVar(&cfg.path, "path", cfg.path, "HTTP path of the metrics")
+	flag.Parse()
+
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer done()
+
+	events := make(chan esl.Event, 1000) //nolint:mnd
+
+	client, err := esl.Connect(cfg.addr, cfg.password,
+		esl.WithEvents(events, true),
+		esl.WithLog(slog.Default()),
+	)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer client.Close()
+
+	collector := metrics.New(metrics.WithClient(client))
+
+	if err := client.Subscribe(collector.Events()...); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	go func() {
+		defer done() // the connection is closed
+
+		esl.Pipe(ctx, events, collector) //nolint:errcheck // never fails
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.path, collector)
+
+	srv := &http.Server{ //nolint:exhaustruct
+		Addr:              cfg.listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second, //nolint:mnd
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:mnd
+		defer cancel()
+
+		srv.Shutdown(shutdownCtx) //nolint:errcheck,contextcheck
+	}()
+
+	slog.Info("exporter", slog.String("listen", cfg.listen), slog.String("path", cfg.path))
+
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err //nolint:wrapcheck
+	}
+
+	select {
+	case <-client.Done():
+		return errors.New("connection to FreeSWITCH closed") //nolint:err113
+	default:
+		return ctx.Err() //nolint:wrapcheck
+	}
+}
@@ -255,6 +255,39 @@ func TestCmd(t *testing.T) {
 				"execute-app-arg: foo=bar\n" +
 				"execute-app-name: set",
 		},
+		{
+			cmd("sendmsg", "<uuid>").WithMessage(map[string]string{
+				"call-command":     "execute",
+				"execute-app-name": "export",
+				"sip_h_X-Tags":     mustArray(t, "first", "second"),
+			}, ""),
+			"sendmsg <uuid>\n" +
+				"call-command: execute\n" +
+				"execute-app-name: export\n" +
+				"sip_h_X-Tags: ARRAY::first|:second",
+		},
+		{
+			cmd("sendevent", "NOTIFY").WithEvent(NewEvent("NOTIFY", map[string]string{
+				"profile":      "internal",
+				"event-string": "check-sync",
+				"user":         "1005",
+			}, []byte("OK"))),
+			"sendevent NOTIFY\n" +
+				"event-string: check-sync\n" +
+				"profile: internal\n" +
+				"user: 1005\n" +
+				"content-length: 2\n" +
+				"\n" +
+				"OK",
+		},
+		{
+			cmd("sendevent", "CUSTOM").WithEvent(NewEvent("CUSTOM my::event", map[string]string{
+				"key": "value",
+			}, nil)),
+			"sendevent CUSTOM\n" +
+				"Event-Subclass: my::event\n" +
+				"key: value",
+		},
 		// spell-checker:enable
 	}
 
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -57,16 +59,15 @@ func (c *conn) Write(cmd command) error {
 // Read reads the response from the connection.
 //
 // It reads the response line by line from the connection and
-// parses the header values. It handles different header keys
-// such as "Content-Type", "Reply-Text", "Job-UUID", and
-// "Content-Length". If the "Content-Length" header is present,
-// it reads the specified number of bytes as the response body.
+// parses the header values. All headers are preserved in the
+// response, except "Content-Length": if it is present, the
+// specified number of bytes is read as the response body.
 // Finally, it logs the received response and returns it along
 // with any error encountered during the process.
-func (c *conn) Read() (response, error) {
+func (c *conn) Read() (Response, error) {
 	var (
 		contentLength int
-		resp          response
+		resp          Response
 	)
 
 	for {
@@ -88,26 +89,21 @@ func (c *conn) Read() (response, error) {
 			return resp, fmt.Errorf("malformed header line: %q", line)
 		}
 
-		key, value := string(line[:idx]), trimLeft(line[idx+1:])
-		switch key {
-		case "Content-Type":
-			resp.contentType = value
-		case "Reply-Text":
-			resp.text = value
-		case "Job-UUID":
-			resp.jobUUID = value
-		case "Content-Length":
-			contentLength, err = strconv.Atoi(value)
+		key := intern(line[:idx])
+		if key == "Content-Length" {
+			contentLength, err = strconv.Atoi(trimLeft(line[idx+1:]))
 			if err != nil {
-				return resp, fmt.Errorf("malformed content-length: %q", value)
+				return resp, fmt.Errorf("malformed content-length: %q", line[idx+1:])
 			}
-		default:
-			c.log.Warn(
-				"esl: unsupported response header",
-				slog.String("key", key),
-				slog.String("value", value),
-			)
+
+			continue
+		}
+
+		if resp.headers == nil {
+			resp.headers = make(map[string]string)
 		}
+
+		resp.headers[key] = intern(bytes.TrimLeft(line[idx+1:], " \t"))
 	}
 
 	if contentLength > 0 {
@@ -122,6 +118,101 @@ func (c *conn) Read() (response, error) {
 	return resp, nil
 }
 
+// readCommand reads the command sent by the client, as the server does.
+//
+// The command lines are read up to the empty line. If the Content-Length
+// header is present, the specified number of bytes is read as the body.
+func (c *conn) readCommand() (command, error) {
+	var (
+		text          bytes.Buffer
+		contentLength int
+	)
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return command{}, err
+		}
+
+		if len(bytes.TrimRight(line, "\r")) == 0 {
+			if text.Len() == 0 {
+				continue // skip empty lines before the command
+			}
+
+			break // the end of command headers
+		}
+
+		if key, value, ok := bytes.Cut(line, []byte{':'}); ok &&
+			text.Len() > 0 && string(bytes.TrimSpace(key)) == "Content-Length" {
+			if contentLength, err = strconv.Atoi(string(bytes.TrimSpace(value))); err != nil {
+				return command{}, fmt.Errorf("malformed content-length: %q", value)
+			}
+		}
+
+		text.Write(line)
+		text.WriteByte('\n')
+	}
+
+	if contentLength > 0 {
+		text.WriteByte('\n')
+
+		if _, err := io.CopyN(&text, c.r, int64(contentLength)); err != nil {
+			return command{}, fmt.Errorf("failed to read body: %w", err)
+		}
+	}
+
+	cmd, err := parseCommand(text.String())
+	if err != nil {
+		return command{}, err
+	}
+
+	c.log.Info("esl: receive", slog.Any("cmd", cmd))
+
+	return cmd, nil
+}
+
+// writeFrame writes the message with the content type, headers and body, as
+// the server does. The header values are written as is.
+func (c *conn) writeFrame(contentType string, headers map[string]string, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.w.WriteString("Content-Type: ") //nolint:errcheck // write to buffer
+	c.w.WriteString(contentType)      //nolint:errcheck // write to buffer
+	c.w.WriteByte('\n')               //nolint:errcheck // write to buffer
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		if k != "Content-Type" && !strings.EqualFold(k, "Content-Length") {
+			keys = append(keys, k)
+		}
+	}
+
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		c.w.WriteString(k)                        //nolint:errcheck // write to buffer
+		c.w.WriteString(": ")                     //nolint:errcheck // write to buffer
+		skipNewLines.WriteString(c.w, headers[k]) //nolint:errcheck // write to buffer
+		c.w.WriteByte('\n')                       //nolint:errcheck // write to buffer
+	}
+
+	if len(body) > 0 {
+		c.w.WriteString("Content-Length: ")      //nolint:errcheck // write to buffer
+		c.w.WriteString(strconv.Itoa(len(body))) //nolint:errcheck // write to buffer
+		c.w.WriteByte('\n')                      //nolint:errcheck // write to buffer
+	}
+
+	c.w.WriteByte('\n') //nolint:errcheck // write to buffer
+	c.w.Write(body)     //nolint:errcheck // write to buffer
+
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
 // readLine reads a line from the conn's reader.
 func (c *conn) readLine() ([]byte, error) {
 	var fullLine []byte // to accumulate full line
@@ -38,14 +38,14 @@ func TestConnection_Read(t *testing.T) {
 			continue
 		}
 
-		event, err := resp.toEvent()
+		event, err := resp.toEvent(false)
 		if err != nil {
 			t.Error(err)
 
 			continue
 		}
 
-		if len(event.headers) == 0 {
+		if len(event.Headers()) == 0 {
 			t.Error("event is empty")
 		}
 
@@ -0,0 +1,160 @@
+package esl
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dispatcher distributes the events among several worker goroutines, keeping
+// the order of the events related to the same call.
+//
+// The events are assigned to the workers by the hash of the Unique-ID header,
+// or Job-UUID for the background job results. The rest of the events are
+// distributed in the round-robin order. Each worker has a bounded queue: when
+// it is full, the dispatching blocks until the worker catches up.
+//
+// It can be plugged directly into the client:
+//
+//	d := esl.NewDispatcher(8, 100, handle)
+//	client, err := esl.Connect(addr, password, esl.WithEvents(d.Events(), true))
+//	...
+//	<-client.Done()
+//	d.Wait() // the events channel is closed by the client
+type Dispatcher struct {
+	input   chan Event
+	workers []*worker
+	handler func(Event)
+	next    atomic.Uint64 // round-robin counter
+	wg      sync.WaitGroup
+}
+
+// worker is the queue of events processed by one goroutine.
+type worker struct {
+	queue     chan queuedEvent
+	processed atomic.Uint64
+	lag       atomic.Int64 // time the last event spent in the queue
+}
+
+// queuedEvent is the event with the time it was queued.
+type queuedEvent struct {
+	event Event
+	at    time.Time
+}
+
+// WorkerStats contains the state of the worker.
+type WorkerStats struct {
+	Queued    int           // number of events in the queue
+	Capacity  int           // maximum size of the queue
+	Processed uint64        // number of processed events
+	Lag       time.Duration // time the last event spent in the queue
+}
+
+// NewDispatcher returns a new Dispatcher with the given number of workers and
+// queue size of each worker, calling the handler for each event.
+func NewDispatcher(workers, queueSize int, handler func(Event)) *Dispatcher {
+	workers = max(workers, 1)
+
+	d := &Dispatcher{
+		input:   make(chan Event),
+		workers: make([]*worker, workers),
+		handler: handler,
+		next:    atomic.Uint64{},
+		wg:      sync.WaitGroup{},
+	}
+
+	d.wg.Add(workers)
+
+	for i := range d.workers {
+		w := &worker{
+			queue:     make(chan queuedEvent, max(queueSize, 0)),
+			processed: atomic.Uint64{},
+			lag:       atomic.Int64{},
+		}
+		d.workers[i] = w
+
+		go d.run(w)
+	}
+
+	go func() {
+		for e := range d.input {
+			d.dispatch(e)
+		}
+
+		for _, w := range d.workers {
+			close(w.queue)
+		}
+	}()
+
+	return d
+}
+
+// Events returns the channel to send the events for dispatching.
+// Closing the channel stops the workers after they process the queued events.
+func (d *Dispatcher) Events() chan<- Event {
+	return d.input
+}
+
+// dispatch puts the event in the queue of the worker selected for it.
+// It blocks while the queue is full.
+func (d *Dispatcher) dispatch(e Event) {
+	d.workers[d.workerIndex(e)].queue <- queuedEvent{event: e, at: time.Now()}
+}
+
+// Close closes the events channel and waits for the workers to process the
+// queued events. Use Wait instead if the channel is closed by the client.
+func (d *Dispatcher) Close() {
+	close(d.input)
+	d.Wait()
+}
+
+// Wait waits for the workers to stop after the events channel is closed.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Stats returns the state of each worker.
+func (d *Dispatcher) Stats() []WorkerStats {
+	stats := make([]WorkerStats, len(d.workers))
+	for i, w := range d.workers {
+		stats[i] = WorkerStats{
+			Queued:    len(w.queue),
+			Capacity:  cap(w.queue),
+			Processed: w.processed.Load(),
+			Lag:       time.Duration(w.lag.Load()),
+		}
+	}
+
+	return stats
+}
+
+// workerIndex returns the index of the worker for the event.
+func (d *Dispatcher) workerIndex(e Event) int {
+	n := uint64(len(d.workers))
+
+	key := e.Get("Unique-ID")
+	if key == "" {
+		key = e.Get("Job-UUID")
+	}
+
+	if key == "" {
+		return int((d.next.Add(1) - 1) % n)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key)) //nolint:errcheck // never fails
+
+	return int(h.Sum64() % n)
+}
+
+// run processes the events of the worker queue.
+func (d *Dispatcher) run(w *worker) {
+	defer d.wg.Done()
+
+	for q := range w.queue {
+		w.lag.Store(int64(time.Since(q.at)))
+		d.handler(q.event)
+		w.processed.Add(1)
+	}
+}
@@ -0,0 +1,80 @@
+package esl
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	const calls, perCall = 20, 50
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+	)
+
+	d := NewDispatcher(4, 10, func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := e.Get("Unique-ID")
+		if id == "" {
+			id = e.Get("Job-UUID")
+		}
+
+		seq, _ := strconv.Atoi(e.Get("Seq"))
+		seen[id] = append(seen[id], seq)
+	})
+
+	go func() {
+		for i := range perCall {
+			for call := range calls {
+				key := "Unique-ID"
+				if call%5 == 0 {
+					key = "Job-UUID"
+				}
+
+				d.Events() <- NewEvent("CHANNEL_STATE", map[string]string{
+					key:   "call-" + strconv.Itoa(call),
+					"Seq": strconv.Itoa(i),
+				}, nil)
+			}
+
+			d.Events() <- NewEvent("HEARTBEAT", map[string]string{"Seq": strconv.Itoa(i)}, nil)
+		}
+
+		d.Close()
+	}()
+
+	d.Wait()
+
+	for call := range calls {
+		list := seen["call-"+strconv.Itoa(call)]
+		if len(list) != perCall {
+			t.Fatalf("call %d: got %d events", call, len(list))
+		}
+
+		for i, seq := range list {
+			if seq != i {
+				t.Fatalf("call %d: event %d out of order: %v", call, i, list)
+			}
+		}
+	}
+
+	if len(seen[""]) != perCall {
+		t.Errorf("got %d events without id", len(seen[""]))
+	}
+
+	var processed uint64
+	for _, s := range d.Stats() {
+		processed += s.Processed
+		if s.Capacity != 10 || s.Queued != 0 {
+			t.Errorf("unexpected stats: %+v", s)
+		}
+	}
+
+	if processed != calls*perCall+perCall {
+		t.Errorf("processed %d events", processed)
+	}
+}
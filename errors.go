@@ -0,0 +1,94 @@
+package esl
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors for the common failures reported by the server. They are
+// matched by ReplyError with errors.Is:
+//
+//	if errors.Is(err, esl.ErrNoSuchChannel) {
+//		// the call is already gone, no need to retry
+//	}
+var (
+	ErrUnknownCommand   = errors.New("command not found")
+	ErrNoSuchChannel    = errors.New("no such channel")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidArgs      = errors.New("invalid args")
+	ErrNoReply          = errors.New("no reply")
+	ErrHangup           = errors.New("call hangup")
+)
+
+// ReplyError is returned when the server replies to a command with an error,
+// that is, with the text starting with "-ERR" or "-USAGE".
+type ReplyError struct {
+	Command string // the command that caused the error, e.g. "api uuid_kill 1234"
+	Text    string // the raw text of the reply, e.g. "-ERR No such channel!"
+	Reason  string // the reason without the prefix, e.g. "No such channel!"
+}
+
+// newReplyError returns a new ReplyError for the reply text.
+func newReplyError(text string) *ReplyError {
+	reason, _, _ := strings.Cut(text, "\n")
+	reason = strings.TrimPrefix(reason, "-ERR")
+	reason = strings.TrimPrefix(reason, "-USAGE:")
+
+	return &ReplyError{
+		Command: "",
+		Text:    text,
+		Reason:  strings.TrimSpace(reason),
+	}
+}
+
+// isReplyError reports whether the reply text reports an error.
+func isReplyError(text string) bool {
+	return strings.HasPrefix(text, "-ERR") || strings.HasPrefix(text, "-USAGE")
+}
+
+// Error returns the text of the reply.
+func (e *ReplyError) Error() string {
+	return strings.TrimSpace(e.Text)
+}
+
+// Is reports whether the error matches one of the sentinel errors.
+func (e *ReplyError) Is(target error) bool {
+	reason := strings.ToLower(e.Reason)
+
+	switch target { //nolint:errorlint // compare with sentinel errors
+	case ErrUnknownCommand:
+		return containsAny(reason, "command not found", "unknown command")
+	case ErrNoSuchChannel:
+		return containsAny(reason, "no such channel", "invalid session id", "invalid uuid")
+	case ErrPermissionDenied:
+		return containsAny(reason, "permission denied", "not allowed")
+	case ErrInvalidArgs:
+		return strings.HasPrefix(e.Text, "-USAGE") ||
+			containsAny(reason, "invalid arg", "missing arg", "wrong number of arg", "usage:")
+	case ErrNoReply:
+		return reason == "no reply"
+	case ErrHangup:
+		return isHangupCause(e.Reason)
+	}
+
+	return false
+}
+
+// containsAny reports whether any of the substrings is within s.
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHangupCause reports whether the reason is a hangup cause name,
+// such as NO_ANSWER or USER_BUSY, which the originate command replies with.
+func isHangupCause(reason string) bool {
+	_, ok := hangupCausesByName[reason]
+
+	return ok
+}
@@ -0,0 +1,58 @@
+package esl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplyError(t *testing.T) {
+	tests := []struct {
+		text   string
+		reason string
+		target error
+	}{
+		// spell-checker:disable
+		{"-ERR command not found", "command not found", ErrUnknownCommand},
+		{"-ERR foo Command not found!\n", "foo Command not found!", ErrUnknownCommand},
+		{"-ERR No such channel!\n", "No such channel!", ErrNoSuchChannel},
+		{"-ERR invalid session id [1234]", "invalid session id [1234]", ErrNoSuchChannel},
+		{"-ERR permission denied!\n", "permission denied!", ErrPermissionDenied},
+		{"-ERR Invalid args\n", "Invalid args", ErrInvalidArgs},
+		{"-USAGE: <uuid> [cause]\n", "<uuid> [cause]", ErrInvalidArgs},
+		{"-ERR no reply", "no reply", ErrNoReply},
+		{"-ERR NO_ANSWER\n", "NO_ANSWER", ErrHangup},
+		{"-ERR USER_BUSY\n", "USER_BUSY", ErrHangup},
+		// spell-checker:enable
+	}
+
+	sentinels := []error{
+		ErrUnknownCommand, ErrNoSuchChannel, ErrPermissionDenied,
+		ErrInvalidArgs, ErrNoReply, ErrHangup,
+	}
+
+	for _, tc := range tests {
+		resp := Response{
+			headers: map[string]string{"Content-Type": apiResponse},
+			body:    []byte(tc.text),
+		}
+
+		err := resp.AsErr()
+
+		var replyErr *ReplyError
+		if !errors.As(err, &replyErr) {
+			t.Errorf("%q: not a reply error: %v", tc.text, err)
+
+			continue
+		}
+
+		if replyErr.Reason != tc.reason {
+			t.Errorf("%q: reason: %q, want: %q", tc.text, replyErr.Reason, tc.reason)
+		}
+
+		for _, target := range sentinels {
+			if got, want := errors.Is(err, target), target == tc.target; got != want {
+				t.Errorf("%q: errors.Is(%v) = %v", tc.text, target, got)
+			}
+		}
+	}
+}
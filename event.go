@@ -2,13 +2,12 @@ package esl
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log/slog"
 	"maps"
-	"net/url"
 	"slices"
 	"strconv"
 	"strings"
@@ -19,10 +18,21 @@ import (
 type Event struct {
 	headers map[string]string
 	body    []byte
+	lazy    *lazyHeaders // headers of the received event parsed on demand
+	tag     *eventTag    // header added on top of the others, see withTag
+}
+
+// eventTag is the header added to the event without parsing or copying the
+// rest of the headers.
+type eventTag struct {
+	key, value string
 }
 
 // NewEvent returns a new Event with the given name, headers and body.
 //
+// The headers and body are copied, so the caller may reuse them. The headers
+// may be nil.
+//
 // It panics if the name is empty or if the name is CUSTOM without the Event-Subclass name.
 func NewEvent(name string, headers map[string]string, body []byte) Event {
 	//nolint:forbidigo
@@ -33,27 +43,233 @@ func NewEvent(name string, headers map[string]string, body []byte) Event {
 		panic("event name cannot be CUSTOM without Event-Subclass name")
 	}
 
+	e := Event{
+		headers: make(map[string]string, len(headers)+2), //nolint:mnd // name & subclass
+		body:    slices.Clone(body),
+		lazy:    nil,
+		tag:     nil,
+	}
+
+	for k, v := range headers {
+		if !strings.EqualFold(k, "Content-Length") { // defined by the body
+			e.headers[k] = v
+		}
+	}
+
 	if name, ok := isCustomEvent(name); ok {
-		headers["Event-Name"] = "CUSTOM"
-		headers["Event-Subclass"] = name
+		e.headers["Event-Name"] = "CUSTOM"
+		e.headers["Event-Subclass"] = name
 	} else {
-		headers["Event-Name"] = name
-		delete(headers, "Event-Subclass")
+		e.headers["Event-Name"] = name
+		delete(e.headers, "Event-Subclass")
+	}
+
+	return e
+}
+
+// Get returns the value associated with the given key from the Event's headers.
+//
+// The values of the received events share the memory with the whole received
+// message, so keeping one of them keeps the whole message in memory. With the
+// WithEventPool option the values must not be used after Release. Use GetCopy
+// for the values kept longer than the event.
+func (e Event) Get(key string) string {
+	value, _ := e.lookup(key)
+
+	return value
+}
+
+// GetCopy is like Get, but returns the copy of the value, which does not
+// share the memory with the event.
+func (e Event) GetCopy(key string) string {
+	return strings.Clone(e.Get(key))
+}
+
+// lookup returns the value of the header and reports whether it is present.
+func (e Event) lookup(key string) (string, bool) {
+	if e.tag != nil && e.tag.key == key {
+		return e.tag.value, true
+	}
+
+	if e.lazy != nil {
+		return e.lazy.lookup(key)
+	}
+
+	value, ok := e.headers[key]
+
+	return value, ok
+}
+
+// header returns all headers of the event, parsing them if necessary.
+// The returned map must not be modified.
+func (e Event) header() map[string]string {
+	header := e.headers
+	if e.lazy != nil {
+		header = e.lazy.parse()
+	}
+
+	if e.tag != nil {
+		header = maps.Clone(header)
+		if header == nil {
+			header = make(map[string]string, 1)
+		}
+
+		header[e.tag.key] = e.tag.value
+	}
+
+	return header
+}
+
+// withTag returns a copy of the event with the header added on top of the
+// others. Unlike Set, it neither parses nor copies the headers of the event:
+// the copy shares them, so with the WithEventPool option releasing either of
+// them releases both.
+func (e Event) withTag(key, value string) Event {
+	e.tag = &eventTag{key: key, value: value}
+
+	return e
+}
+
+// Values returns all values of the header. The values of the array headers,
+// encoded as ARRAY::first|:second, are returned as separate elements.
+//
+// It returns nil if there is no such header.
+func (e Event) Values(key string) []string {
+	value, ok := e.lookup(key)
+	if !ok {
+		return nil
+	}
+
+	return splitArray(value)
+}
+
+// Headers returns the names of all event headers sorted in ascending order.
+func (e Event) Headers() []string {
+	header := e.header()
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Variables returns the names of all channel variables of the event, without
+// the "variable_" prefix, sorted in ascending order.
+func (e Event) Variables() []string {
+	var names []string
+
+	for k := range e.header() {
+		if name, ok := strings.CutPrefix(k, "variable_"); ok {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Set returns a copy of the event with the header set to the value.
+//
+// The original event is not changed, so it is safe to enrich the events
+// shared between goroutines. The Content-Length header is ignored because
+// it is defined by the body.
+func (e Event) Set(key, value string) Event {
+	if strings.EqualFold(key, "Content-Length") {
+		return e
+	}
+
+	header := e.header()
+	headers := make(map[string]string, len(header)+1)
+	maps.Copy(headers, header)
+	headers[key] = value
+
+	return Event{
+		headers: headers,
+		body:    e.body,
+		lazy:    nil,
+		tag:     nil,
 	}
+}
 
-	if _, ok := headers["Content-Length"]; !ok {
-		delete(headers, "Content-Length")
+// Del returns a copy of the event without the header.
+func (e Event) Del(key string) Event {
+	if _, ok := e.lookup(key); !ok {
+		return e
 	}
 
+	headers := maps.Clone(e.header())
+	delete(headers, key)
+
 	return Event{
 		headers: headers,
-		body:    body,
+		body:    e.body,
+		lazy:    nil,
+		tag:     nil,
 	}
 }
 
-// Get returns the value associated with the given key from the Event's headers.
-func (e Event) Get(key string) string {
-	return e.headers[key]
+// SetValues returns a copy of the event with the header set to the values.
+// Multiple values are encoded as an array, see Array. The header is removed
+// if there are no values.
+//
+// It returns ErrArraySeparator if the values cannot be encoded as an array.
+func (e Event) SetValues(key string, values ...string) (Event, error) {
+	switch len(values) {
+	case 0:
+		return e.Del(key), nil
+	case 1:
+		if !isArray(values[0]) {
+			return e.Set(key, values[0]), nil
+		}
+	}
+
+	value, err := Array(values...)
+	if err != nil {
+		return e, fmt.Errorf("header %s: %w", key, err)
+	}
+
+	return e.Set(key, value), nil
+}
+
+// Add returns a copy of the event with the value appended to the values of the header.
+func (e Event) Add(key, value string) (Event, error) {
+	return e.SetValues(key, append(e.Values(key), value)...)
+}
+
+// SetVariable returns a copy of the event with the channel variable set to the value.
+func (e Event) SetVariable(name, value string) Event {
+	return e.Set("variable_"+name, value)
+}
+
+// SetBody returns a copy of the event with the given body.
+func (e Event) SetBody(body []byte) Event {
+	return Event{
+		headers: e.headers,
+		body:    slices.Clone(body),
+		lazy:    e.lazy,
+		tag:     e.tag,
+	}
+}
+
+// Clone returns a deep copy of the event, which does not share the memory
+// with the received message and may be used after Release.
+func (e Event) Clone() Event {
+	headers := make(map[string]string, len(e.header()))
+	for k, v := range e.header() {
+		headers[strings.Clone(k)] = strings.Clone(v)
+	}
+
+	return Event{
+		headers: headers,
+		body:    slices.Clone(e.body),
+		lazy:    nil,
+		tag:     nil,
+	}
 }
 
 // Name returns the name of the event.
@@ -102,11 +318,15 @@ func (e Event) Body() string {
 	return string(e.body)
 }
 
-// WriteTo writes the event to the given writer.
+// WriteTo writes the event to the given writer in the plain format.
+//
+// The header values are url-encoded the same way as FreeSWITCH does, so the
+// event can be parsed back without any loss.
 func (e Event) WriteTo(w io.Writer) (int64, error) {
-	keys := make([]string, 0, len(e.headers))
+	header := e.header()
+	keys := make([]string, 0, len(header))
 
-	for k := range e.headers {
+	for k := range header {
 		if strings.EqualFold(k, "Content-Length") {
 			continue // ignore content-length
 		}
@@ -121,11 +341,7 @@ func (e Event) WriteTo(w io.Writer) (int64, error) {
 		for _, key := range keys {
 			buf.WriteString(key)
 			buf.WriteString(": ")
-			// Since messaging format is similar to RFC 2822, if you are using any
-			// libraries that follow the line wrapping recommendation of RFC 2822 then
-			// make sure that you disable line wrapping as FreeSWITCH will ignore
-			// wrapped lines.
-			skipNewLines.WriteString(buf, e.headers[key])
+			buf.WriteString(urlEncode(header[key]))
 			buf.WriteByte('\n')
 		}
 
@@ -144,94 +360,199 @@ func (e Event) String() string {
 }
 
 // MarshalJSON is a Go function that marshals the Event to JSON.
+//
+// The array headers are encoded as JSON arrays, just like FreeSWITCH does.
 func (e Event) MarshalJSON() ([]byte, error) {
-	header := e.headers
+	header := make(map[string]any, len(e.header())+1)
+	for k, v := range e.header() {
+		if isArray(v) {
+			header[k] = splitArray(v)
+		} else {
+			header[k] = v
+		}
+	}
+
 	if len(e.body) > 0 {
-		header = maps.Clone(header)
 		header["_body"] = string(e.body)
 	}
 
 	return json.Marshal(header) //nolint:wrapcheck
 }
 
-// LogValue returns the log value of the Event.
-//
-// It returns a slog.Value that contains the name and sequence of the Event.
-func (e Event) LogValue() slog.Value {
-	attr := make([]slog.Attr, 0, 3)
-	attr = append(attr,
-		slog.String("name", e.Name()),
-		slog.Int64("sequence", e.Sequence()),
-	)
-
-	if jobUUID := e.Get("Job-UUID"); jobUUID != "" {
-		attr = append(attr, slog.String("job-uuid", jobUUID))
+// UnmarshalJSON parses the event in the JSON format, as produced by MarshalJSON
+// or sent by FreeSWITCH for the "event json" subscription.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err //nolint:wrapcheck
 	}
 
-	return slog.GroupValue(attr...)
-}
+	var body string
 
-// skipNewLines replaces newline characters with spaces when writing
-// message headers. This ensures headers do not contain newlines, as
-// required by the FreeSWITCH messaging protocol.
-var skipNewLines = strings.NewReplacer("\r\n", " ", "\n", " ") //nolint:gochecknoglobals
+	header := make(map[string]string, len(raw))
 
-// parseEvent parses the given byte slice as an event and returns an Event and an error.
-func parseEvent(body []byte) (Event, error) {
-	event := Event{
-		headers: make(map[string]string, upcomingHeaderKeys(body)),
-		body:    nil,
-	}
+	for k, v := range raw {
+		if len(v) > 0 && v[0] == '[' {
+			var values []string
+			if err := json.Unmarshal(v, &values); err != nil {
+				return fmt.Errorf("header %s: %w", k, err)
+			}
+
+			value, err := Array(values...)
+			if err != nil {
+				return fmt.Errorf("header %s: %w", k, err)
+			}
+
+			header[k] = value
 
-	for len(body) > 0 {
-		var line []byte
-		if i := bytes.IndexByte(body, '\n'); i >= 0 {
-			line, body = body[:i], body[i+1:]
+			continue
 		}
 
-		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
-			break // the end of headers
+		var value string
+		if err := json.Unmarshal(v, &value); err != nil {
+			return fmt.Errorf("header %s: %w", k, err)
 		}
 
-		idx := bytes.IndexByte(line, ':')
-		if idx <= 0 {
-			return event, fmt.Errorf("malformed header line: %q", line)
+		header[k] = value
+	}
+
+	if value, ok := header["_body"]; ok {
+		body = value
+		delete(header, "_body")
+	}
+
+	delete(header, "Content-Length")
+
+	e.headers = header
+	e.body = nil
+	e.lazy = nil
+	e.tag = nil
+
+	if body != "" {
+		e.body = []byte(body)
+	}
+
+	return nil
+}
+
+// MarshalXML encodes the event in the XML format used by FreeSWITCH:
+//
+//	<event>
+//	  <headers>
+//	    <Event-Name>HEARTBEAT</Event-Name>
+//	  </headers>
+//	  <body>...</body>
+//	</event>
+//
+// The header values are url-encoded. The values of the array headers are
+// encoded as the repeated elements, except the one-element arrays, which are
+// kept as is to be decoded back as the arrays.
+func (e Event) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	header := e.header()
+	keys := e.Headers()
+
+	event := xml.StartElement{Name: xml.Name{Local: "event"}}     //nolint:exhaustruct
+	headers := xml.StartElement{Name: xml.Name{Local: "headers"}} //nolint:exhaustruct
+
+	tokens := make([]xml.Token, 0, len(keys)*3+6) //nolint:mnd // start, text & end for each header
+	tokens = append(tokens, event, headers)
+
+	for _, key := range keys {
+		elem := xml.StartElement{Name: xml.Name{Local: key}} //nolint:exhaustruct
+		values := splitArray(header[key])
+		if len(values) == 1 {
+			values[0] = header[key] // keep the prefix of the one-element array
 		}
 
-		key, value := string(line[:idx]), trimLeft(line[idx+1:])
-		if v, err := url.PathUnescape(value); err == nil {
-			value = v
+		for _, value := range values {
+			tokens = append(tokens, elem, xml.CharData(urlEncode(value)), elem.End())
 		}
+	}
 
-		event.headers[key] = value
+	tokens = append(tokens, headers.End())
+
+	if len(e.body) > 0 {
+		body := xml.StartElement{Name: xml.Name{Local: "body"}} //nolint:exhaustruct
+		tokens = append(tokens, body, xml.CharData(e.body), body.End())
 	}
 
-	if length, _ := strconv.Atoi(event.headers["Content-Length"]); length > 0 {
-		event.body = make([]byte, length)
-		if copy(event.body, body) != length {
-			return event, fmt.Errorf("failed to read body: %w", io.ErrUnexpectedEOF)
+	tokens = append(tokens, event.End())
+
+	for _, t := range tokens {
+		if err := enc.EncodeToken(t); err != nil {
+			return err //nolint:wrapcheck
 		}
 	}
 
-	return event, nil
+	return nil
 }
 
-// upcomingHeaderKeys returns the number of upcoming header keys in the given byte slice.
-func upcomingHeaderKeys(body []byte) int {
-	var n int
+// UnmarshalXML parses the event in the XML format, as produced by MarshalXML
+// or sent by FreeSWITCH for the "event xml" subscription.
+func (e *Event) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var raw struct {
+		Headers struct {
+			List []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"headers"`
+		Body string `xml:"body"`
+	}
+
+	if err := dec.DecodeElement(&raw, &start); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	values := make(map[string][]string, len(raw.Headers.List))
+	for _, h := range raw.Headers.List {
+		values[h.XMLName.Local] = append(values[h.XMLName.Local], urlDecode(h.Value))
+	}
+
+	headers := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) == 1 {
+			headers[k] = v[0]
 
-	for len(body) > 0 && n < 1000 {
-		var line []byte
-		if i := bytes.IndexByte(body, '\n'); i >= 0 {
-			line, body = body[:i], body[i+1:]
+			continue
 		}
 
-		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
-			break
+		value, err := Array(v...)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", k, err)
 		}
 
-		n++
+		headers[k] = value
 	}
 
-	return n
+	e.headers = headers
+
+	delete(e.headers, "Content-Length")
+
+	e.body = nil
+	e.lazy = nil
+	e.tag = nil
+
+	if raw.Body != "" {
+		e.body = []byte(raw.Body)
+	}
+
+	return nil
+}
+
+// LogValue returns the log value of the Event.
+//
+// It returns a slog.Value that contains the name and sequence of the Event.
+func (e Event) LogValue() slog.Value {
+	attr := make([]slog.Attr, 0, 3)
+	attr = append(attr,
+		slog.String("name", e.Name()),
+		slog.Int64("sequence", e.Sequence()),
+	)
+
+	if jobUUID := e.Get("Job-UUID"); jobUUID != "" {
+		attr = append(attr, slog.String("job-uuid", jobUUID))
+	}
+
+	return slog.GroupValue(attr...)
 }
@@ -0,0 +1,263 @@
+package esl
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	events := []Event{
+		NewEvent("HEARTBEAT", map[string]string{
+			"Event-Info":  "System Ready",
+			"Up-Time":     "0 years, 0 days, 1 hour",
+			"Idle-CPU":    "98.5%",
+			"Percent-Raw": "100%25 %zz %",
+		}, nil),
+		NewEvent("CUSTOM sofia::register", map[string]string{
+			"variable_caller_name": "Привет, мир",
+			"Multi-Line":           "first\nsecond\r\nthird",
+			"Unsafe":               `"#&+:;<=>?@[\]^` + "`{|}\t",
+		}, []byte("body with\nnew lines & <tags>\n")),
+		NewEvent("CHANNEL_BRIDGE", map[string]string{
+			"variable_bridge_uuids": mustArray(t, "a", "d%7Ce", "50%", "f|g"),
+			"variable_sip_h_X-List": mustArray(t, "one", "two"),
+			"variable_single":       mustArray(t, "single"),
+		}, nil),
+	}
+
+	formats := []struct {
+		name      string
+		marshal   func(Event) ([]byte, error)
+		unmarshal func([]byte) (Event, error)
+	}{
+		{
+			"plain",
+			func(e Event) ([]byte, error) { return []byte(e.String()), nil },
+			parseEvent,
+		},
+		{
+			"json",
+			func(e Event) ([]byte, error) { return json.Marshal(e) },
+			func(data []byte) (Event, error) {
+				var e Event
+				err := json.Unmarshal(data, &e)
+
+				return e, err
+			},
+		},
+		{
+			"xml",
+			func(e Event) ([]byte, error) { return xml.Marshal(e) },
+			func(data []byte) (Event, error) {
+				var e Event
+				err := xml.Unmarshal(data, &e)
+
+				return e, err
+			},
+		},
+	}
+
+	for _, format := range formats {
+		for _, want := range events {
+			data, err := format.marshal(want)
+			if err != nil {
+				t.Fatalf("%s: %v", format.name, err)
+			}
+
+			got, err := format.unmarshal(data)
+			if err != nil {
+				t.Fatalf("%s: %v\n%s", format.name, err, data)
+			}
+
+			if !maps.Equal(got.header(), want.header()) {
+				t.Errorf("%s: headers mismatch:\n got: %q\nwant: %q", format.name, got.header(), want.header())
+			}
+
+			if got.Body() != want.Body() {
+				t.Errorf("%s: body mismatch:\n got: %q\nwant: %q", format.name, got.Body(), want.Body())
+			}
+		}
+	}
+}
+
+func TestParseEventEncoded(t *testing.T) {
+	// spell-checker:disable
+	event, err := parseEvent([]byte("Event-Name: CHANNEL_ANSWER\n" +
+		"Event-Date-Local: 2024-01-15%2010%3A00%3A00\n" +
+		"Caller-Caller-ID-Name: %D0%98%D0%B2%D0%B0%D0%BD\n" +
+		"Content-Length: 2\n\nOK"))
+	// spell-checker:enable
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := event.Get("Event-Date-Local"); got != "2024-01-15 10:00:00" {
+		t.Errorf("unexpected date: %q", got)
+	}
+
+	if got := event.Get("Caller-Caller-ID-Name"); got != "Иван" {
+		t.Errorf("unexpected name: %q", got)
+	}
+
+	if event.Body() != "OK" {
+		t.Errorf("unexpected body: %q", event.Body())
+	}
+}
+
+func TestEventBuilder(t *testing.T) {
+	headers := map[string]string{"Unique-ID": "1234", "Content-Length": "10"}
+
+	event := NewEvent("CHANNEL_ANSWER", headers, nil)
+	if len(headers) != 2 {
+		t.Errorf("caller headers changed: %v", headers)
+	}
+
+	if event.Get("Content-Length") != "" {
+		t.Error("content-length header is not removed")
+	}
+
+	enriched := event.
+		Set("Call-Direction", "inbound").
+		SetVariable("sip_from_user", "1000").
+		SetVariable("direction", "inbound").
+		SetBody([]byte("body")).
+		Del("Unique-ID")
+
+	if event.Get("Call-Direction") != "" || event.Get("Unique-ID") != "1234" || event.Body() != "" {
+		t.Errorf("original event changed: %s", event)
+	}
+
+	if got, want := enriched.Headers(), []string{
+		"Call-Direction", "Event-Name", "variable_direction", "variable_sip_from_user",
+	}; !slices.Equal(got, want) {
+		t.Errorf("unexpected headers: %v", got)
+	}
+
+	if got := enriched.Variables(); !slices.Equal(got, []string{"direction", "sip_from_user"}) {
+		t.Errorf("unexpected variables: %v", got)
+	}
+
+	if enriched.Body() != "body" {
+		t.Errorf("unexpected body: %q", enriched.Body())
+	}
+
+	clone := enriched.Clone()
+	if !maps.Equal(clone.header(), enriched.header()) || clone.Body() != enriched.Body() {
+		t.Errorf("clone mismatch: %s", clone)
+	}
+
+	if NewEvent("HEARTBEAT", nil, nil).Name() != "HEARTBEAT" {
+		t.Error("nil headers are not supported")
+	}
+}
+
+func TestEventValues(t *testing.T) {
+	values := []string{"first", "with|pipe", "escaped%7C", "percent%25", "50%", ""}
+
+	event, err := NewEvent("CHANNEL_DATA", nil, nil).SetValues("variable_list", values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event = event.Set("Plain", "value")
+
+	for _, v := range []string{"one", "two"} {
+		if event, err = event.Add("Added", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := event.Values("variable_list"); !slices.Equal(got, values) {
+		t.Errorf("unexpected values: %q", got)
+	}
+
+	if got := event.Values("Plain"); !slices.Equal(got, []string{"value"}) {
+		t.Errorf("unexpected plain values: %q", got)
+	}
+
+	if got := event.Get("Added"); got != "ARRAY::one|:two" {
+		t.Errorf("unexpected encoded array: %q", got)
+	}
+
+	if got := event.Values("Missing"); got != nil {
+		t.Errorf("unexpected missing values: %q", got)
+	}
+
+	parsed, err := parseEvent([]byte("Event-Name: CHANNEL_DATA\n" +
+		"variable_bridge_uuids: ARRAY::1234%7C%3A5678\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parsed.Values("variable_bridge_uuids"); !slices.Equal(got, []string{"1234", "5678"}) {
+		t.Errorf("unexpected parsed values: %q", got)
+	}
+}
+
+func TestEventArraySeparator(t *testing.T) {
+	event := NewEvent("CHANNEL_DATA", nil, nil)
+
+	if _, err := Array("first", "with|:separator"); !errors.Is(err, ErrArraySeparator) {
+		t.Errorf("unexpected Array error: %v", err)
+	}
+
+	if _, err := event.SetValues("variable_list", "first", "a|:b"); !errors.Is(err, ErrArraySeparator) {
+		t.Errorf("unexpected SetValues error: %v", err)
+	}
+
+	if _, err := event.Add("variable_single", "a|:b"); err != nil {
+		t.Errorf("single value is not an array: %v", err)
+	}
+
+	var parsed Event
+	if err := json.Unmarshal([]byte(`{"list":["a|:b","c"]}`), &parsed); !errors.Is(err, ErrArraySeparator) {
+		t.Errorf("unexpected JSON error: %v", err)
+	}
+
+	data := []byte("<event><headers><list>a|:b</list><list>c</list></headers></event>")
+	if err := xml.Unmarshal(data, &parsed); !errors.Is(err, ErrArraySeparator) {
+		t.Errorf("unexpected XML error: %v", err)
+	}
+
+	// A value with only a part of the separator survives the round trip.
+	event, err := event.SetValues("variable_list", "a|", ":b", "|")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := event.Values("variable_list"); !slices.Equal(got, []string{"a|", ":b", "|"}) {
+		t.Errorf("unexpected values: %q", got)
+	}
+}
+
+func mustArray(t *testing.T, values ...string) string {
+	t.Helper()
+
+	value, err := Array(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func TestEventGetCopy(t *testing.T) {
+	buf := []byte("Event-Name: HEARTBEAT\nCore-UUID: 1234\n\n")
+
+	event, err := parseEvent(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, clone := event.GetCopy("Core-UUID"), event.Clone()
+	copy(buf, strings.Repeat("x", len(buf))) // the received message is reused
+
+	if value != "1234" || clone.Get("Core-UUID") != "1234" {
+		t.Errorf("the copies share the memory with the event: %q, %q", value, clone.Get("Core-UUID"))
+	}
+}
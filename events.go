@@ -107,11 +107,45 @@ var eventNames = map[string]struct{}{
 
 const eventAll = "all"
 
+// normalizeEventNames returns the event names in the form they are used in the
+// event commands: the native names as is, the custom event names without the
+// "CUSTOM " prefix, and the bare CUSTOM name, which means all custom events.
+// Empty names are skipped.
+//
+// It returns nil if all events are requested.
+func normalizeEventNames(names ...string) []string {
+	if len(names) == 0 || names[0] == "" || strings.EqualFold(names[0], eventAll) {
+		return nil
+	}
+
+	list := make([]string, 0, len(names))
+
+	for _, name := range names {
+		switch {
+		case name == "":
+			continue
+		case strings.EqualFold(name, eventAll):
+			return nil
+		case strings.EqualFold(name, "CUSTOM"):
+			list = append(list, "CUSTOM")
+		default:
+			list = append(list, strings.TrimPrefix(name, "CUSTOM "))
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list
+}
+
 // buildEventNamesCmd builds a command string for enabling/disabling FreeSWITCH
 // event types. It accepts a list of event names and returns a command string
 // suitable for passing to the 'event_subscribe' API.
 func buildEventNamesCmd(names ...string) string {
-	if len(names) == 0 || names[0] == "" || strings.EqualFold(names[0], eventAll) {
+	list := normalizeEventNames(names...)
+	if list == nil {
 		return eventAll
 	}
 
@@ -121,34 +155,29 @@ func buildEventNamesCmd(names ...string) string {
 		isCustom bool
 	)
 
-	for _, name := range names {
-		switch {
-		case name == "":
-			continue
-		case strings.EqualFold(name, eventAll):
-			return eventAll
-		case strings.EqualFold(name, "CUSTOM"):
+	for _, name := range list {
+		if name == "CUSTOM" {
 			isCustom = true
 
 			continue
-		default:
-			if _, ok := eventNames[name]; ok {
-				if native.Len() > 0 {
-					native.WriteByte(' ')
-				}
-
-				native.WriteString(name)
+		}
 
-				continue
+		if _, ok := eventNames[name]; ok {
+			if native.Len() > 0 {
+				native.WriteByte(' ')
 			}
 
-			// custom event name
-			if custom.Len() > 0 {
-				custom.WriteByte(' ')
-			}
+			native.WriteString(name)
 
-			custom.WriteString(strings.TrimPrefix(name, "CUSTOM "))
+			continue
+		}
+
+		// custom event name
+		if custom.Len() > 0 {
+			custom.WriteByte(' ')
 		}
+
+		custom.WriteString(name)
 	}
 
 	// join event names
@@ -165,10 +194,6 @@ func buildEventNamesCmd(names ...string) string {
 		}
 	}
 
-	if native.Len() == 0 {
-		return eventAll
-	}
-
 	return native.String()
 }
 
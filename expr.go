@@ -0,0 +1,413 @@
+package esl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled expression to filter events on the client side.
+//
+// The expression compares the event headers with the values:
+//
+//	Event-Name == "CHANNEL_ANSWER" && variable_direction == inbound &&
+//	  Caller-Caller-ID-Number =~ "^\+7"
+//
+// The supported operators are:
+//
+//	==  the header is equal to the value
+//	!=  the header is not equal to the value
+//	=~  the header matches the regular expression
+//	!~  the header does not match the regular expression
+//	&&  logical AND
+//	||  logical OR
+//	!   logical NOT
+//
+// The header name alone checks that the header is present and not empty.
+// The values are either double-quoted Go strings or bare words. The array
+// headers match if any of their values matches.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// EventFilter is a filter applied to the events on the server side:
+// only the events with the header equal to the value are received.
+type EventFilter struct {
+	Header string
+	Value  string
+}
+
+// ParseExpr compiles the event filter expression.
+func ParseExpr(s string) (*Expr, error) {
+	p := exprParser{src: s, pos: 0}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	return &Expr{src: s, root: root}, nil
+}
+
+// MustParseExpr is like ParseExpr but panics if the expression cannot be parsed.
+func MustParseExpr(s string) *Expr {
+	x, err := ParseExpr(s)
+	if err != nil {
+		panic(err) //nolint:forbidigo
+	}
+
+	return x
+}
+
+// Match reports whether the event matches the expression.
+// The nil expression matches all events.
+func (x *Expr) Match(e Event) bool {
+	return x == nil || x.root.match(e)
+}
+
+// String returns the source of the expression.
+func (x *Expr) String() string {
+	if x == nil {
+		return ""
+	}
+
+	return x.src
+}
+
+// ServerFilters returns the filters which can be applied on the server side
+// to reduce the number of events sent to the client. All events matching the
+// expression pass these filters, so the expression must still be applied to
+// the received events.
+//
+// The comparisons of the channel variables are not pushed down, because they
+// may be arrays. It returns nil if the expression cannot be pushed down to
+// the server.
+func (x *Expr) ServerFilters() []EventFilter {
+	if x == nil {
+		return nil
+	}
+
+	filters := x.root.pushdown()
+	sortFilters(filters)
+
+	return slices.Compact(filters)
+}
+
+// exprNode is a node of the compiled expression.
+type exprNode interface {
+	match(e Event) bool
+	// pushdown returns the server filters which pass all events matching the node,
+	// or nil if there are no such filters.
+	pushdown() []EventFilter
+}
+
+type (
+	orNode  []exprNode
+	andNode []exprNode
+	notNode struct{ x exprNode }
+	hasNode struct{ header string }
+	cmpNode struct {
+		header, op, value string
+		re                *regexp.Regexp
+	}
+)
+
+func (n orNode) match(e Event) bool {
+	for _, x := range n {
+		if x.match(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pushdown returns the union of filters only if every alternative can be filtered:
+// the server passes the event if it matches any of its filters.
+func (n orNode) pushdown() []EventFilter {
+	var filters []EventFilter
+
+	for _, x := range n {
+		f := x.pushdown()
+		if f == nil {
+			return nil
+		}
+
+		filters = append(filters, f...)
+	}
+
+	return filters
+}
+
+func (n andNode) match(e Event) bool {
+	for _, x := range n {
+		if !x.match(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pushdown returns the filters of the most selective operand: each of them
+// is necessary for the event to match.
+func (n andNode) pushdown() []EventFilter {
+	var filters []EventFilter
+
+	for _, x := range n {
+		if f := x.pushdown(); f != nil && (filters == nil || len(f) < len(filters)) {
+			filters = f
+		}
+	}
+
+	return filters
+}
+
+func (n notNode) match(e Event) bool    { return !n.x.match(e) }
+func (notNode) pushdown() []EventFilter { return nil }
+
+func (n hasNode) match(e Event) bool    { return e.Get(n.header) != "" }
+func (hasNode) pushdown() []EventFilter { return nil }
+
+func (n cmpNode) match(e Event) bool {
+	values := e.Values(n.header)
+	if values == nil {
+		values = []string{""} // compare missing header as empty
+	}
+
+	var ok bool
+
+	for _, v := range values {
+		if n.re != nil {
+			ok = n.re.MatchString(v)
+		} else {
+			ok = v == n.value
+		}
+
+		if ok {
+			break
+		}
+	}
+
+	if n.op == "!=" || n.op == "!~" {
+		return !ok
+	}
+
+	return ok
+}
+
+// pushdown skips the channel variables: they may be arrays, and the server
+// compares the filter value with the whole encoded array. It also skips the
+// values enclosed in slashes, which the server treats as regular expressions.
+func (n cmpNode) pushdown() []EventFilter {
+	if n.op != "==" || n.value == "" || strings.HasPrefix(n.header, "variable_") {
+		return nil
+	}
+
+	if len(n.value) > 1 && strings.HasPrefix(n.value, "/") && strings.HasSuffix(n.value, "/") {
+		return nil
+	}
+
+	return []EventFilter{{Header: n.header, Value: n.value}}
+}
+
+// exprParser is a recursive descent parser of the expressions.
+type exprParser struct {
+	src string
+	pos int
+}
+
+// ErrExprSyntax is returned when the expression cannot be parsed.
+var ErrExprSyntax = errors.New("expression syntax error")
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at %d: %s", ErrExprSyntax, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume skips the token if it is next in the source.
+func (p *exprParser) consume(token string) bool {
+	p.skipSpaces()
+
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+
+		return true
+	}
+
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	list := orNode{x}
+
+	for p.consume("||") {
+		if x, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+
+		list = append(list, x)
+	}
+
+	if len(list) == 1 {
+		return list[0], nil
+	}
+
+	return list, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	list := andNode{x}
+
+	for p.consume("&&") {
+		if x, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+
+		list = append(list, x)
+	}
+
+	if len(list) == 1 {
+		return list[0], nil
+	}
+
+	return list, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.consume("!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{x: x}, nil
+	}
+
+	if p.consume("(") {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.consume(")") {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+
+		return x, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	header := p.parseWord()
+	if header == "" {
+		return nil, p.errorf("header name expected")
+	}
+
+	var op string
+
+	for _, token := range []string{"==", "!=", "=~", "!~"} {
+		if p.consume(token) {
+			op = token
+
+			break
+		}
+	}
+
+	if op == "" {
+		return hasNode{header: header}, nil
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	node := cmpNode{header: header, op: op, value: value, re: nil}
+
+	if op == "=~" || op == "!~" {
+		if node.re, err = regexp.Compile(value); err != nil {
+			return nil, p.errorf("bad regular expression: %v", err)
+		}
+	}
+
+	return node, nil
+}
+
+// parseWord returns the bare word: the header name or the unquoted value.
+func (p *exprParser) parseWord() string {
+	p.skipSpaces()
+
+	start := p.pos
+	for p.pos < len(p.src) && isWordChar(p.src[p.pos]) {
+		p.pos++
+	}
+
+	return p.src[start:p.pos]
+}
+
+// isWordChar reports whether the character may be a part of the bare word.
+func isWordChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("_-:.@+/*", c) >= 0
+}
+
+// parseValue returns the quoted string or the bare word.
+func (p *exprParser) parseValue() (string, error) {
+	p.skipSpaces()
+
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		if word := p.parseWord(); word != "" {
+			return word, nil
+		}
+
+		return "", p.errorf("value expected")
+	}
+
+	// find the closing quote, skipping the escaped characters
+	end := p.pos + 1
+	for ; end < len(p.src) && p.src[end] != '"'; end++ {
+		if p.src[end] == '\\' {
+			end++
+		}
+	}
+
+	if end >= len(p.src) {
+		return "", p.errorf("unterminated string")
+	}
+
+	value, err := strconv.Unquote(p.src[p.pos : end+1])
+	if err != nil {
+		// keep regular expression escapes like \+ or \d as is
+		value = p.src[p.pos+1 : end]
+	}
+
+	p.pos = end + 1
+
+	return value, nil
+}
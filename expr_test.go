@@ -0,0 +1,149 @@
+package esl
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	event := NewEvent("CHANNEL_ANSWER", map[string]string{
+		"Caller-Caller-ID-Number": "+79161234567",
+		"variable_direction":      "inbound",
+		"variable_bridge_uuids":   mustArray(t, "1111", "2222"),
+	}, nil)
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`Event-Name == "CHANNEL_ANSWER"`, true},
+		{`Event-Name == CHANNEL_ANSWER`, true},
+		{`Event-Name != CHANNEL_ANSWER`, false},
+		{`Event-Name == "CHANNEL_ANSWER" && variable_direction == "inbound" && ` +
+			`Caller-Caller-ID-Number =~ "^\+7"`, true},
+		{`Caller-Caller-ID-Number !~ "^\+7"`, false},
+		{`Event-Name == CHANNEL_HANGUP || variable_direction == inbound`, true},
+		{`Event-Name == CHANNEL_HANGUP || variable_direction == outbound`, false},
+		{`!(Event-Name == CHANNEL_HANGUP)`, true},
+		{`variable_direction && !Unique-ID`, true},
+		{`variable_bridge_uuids == 2222`, true},
+		{`variable_bridge_uuids != 3333`, true},
+		{`Unique-ID == ""`, true},
+	}
+
+	for _, tc := range tests {
+		x, err := ParseExpr(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+
+			continue
+		}
+
+		if got := x.Match(event); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	var nilExpr *Expr
+	if !nilExpr.Match(event) {
+		t.Error("nil expression does not match")
+	}
+}
+
+func TestExprSyntaxError(t *testing.T) {
+	for _, s := range []string{
+		``,
+		`Event-Name ==`,
+		`(Event-Name == A`,
+		`Event-Name == "A`,
+		`Event-Name =~ "("`,
+		`Event-Name == A B`,
+		`&& A`,
+	} {
+		if _, err := ParseExpr(s); !errors.Is(err, ErrExprSyntax) {
+			t.Errorf("%q: unexpected error: %v", s, err)
+		}
+	}
+}
+
+func TestExprServerFilters(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []EventFilter
+	}{
+		{`Event-Name == CHANNEL_ANSWER`, []EventFilter{{"Event-Name", "CHANNEL_ANSWER"}}},
+		{
+			`Event-Name == CHANNEL_ANSWER || Event-Name == CHANNEL_HANGUP`,
+			[]EventFilter{{"Event-Name", "CHANNEL_ANSWER"}, {"Event-Name", "CHANNEL_HANGUP"}},
+		},
+		{
+			`(Event-Name == CHANNEL_ANSWER || Event-Name == CHANNEL_HANGUP) && Unique-ID == 1234`,
+			[]EventFilter{{"Unique-ID", "1234"}},
+		},
+		{`Event-Name == CHANNEL_ANSWER && Caller-Caller-ID-Number =~ "^1"`, []EventFilter{{"Event-Name", "CHANNEL_ANSWER"}}},
+		{`Event-Name == CHANNEL_ANSWER || Caller-Caller-ID-Number =~ "^1"`, nil},
+		{`Event-Name != CHANNEL_ANSWER`, nil},
+		{`!(Event-Name == CHANNEL_ANSWER)`, nil},
+		{`variable_bridge_uuids == 1234`, nil},
+		{`Event-Name == CHANNEL_BRIDGE && variable_bridge_uuids == 1234`, []EventFilter{{"Event-Name", "CHANNEL_BRIDGE"}}},
+		{`Caller-Destination-Number == "/^1"`, []EventFilter{{"Caller-Destination-Number", "/^1"}}},
+		{`Caller-Destination-Number == "/^1.*/"`, nil},
+		{`Event-Name == CHANNEL_ANSWER && Caller-Destination-Number == "/1/"`, []EventFilter{{"Event-Name", "CHANNEL_ANSWER"}}},
+	}
+
+	for _, tc := range tests {
+		if got := MustParseExpr(tc.expr).ServerFilters(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestConfigServerFilters(t *testing.T) {
+	x := MustParseExpr("Event-Name == CHANNEL_ANSWER")
+
+	if got := getConfig(WithEventFilter(x)).serverFilters(); len(got) != 4 {
+		t.Errorf("unexpected default filters: %v", got)
+	}
+
+	if got := getConfig(WithEventFilter(x, false)).serverFilters(); got != nil {
+		t.Errorf("unexpected filters with pushdown disabled: %v", got)
+	}
+
+	if got := getConfig(WithEventFilter(x), WithSequenceMonitor(NewSequenceMonitor(nil))).serverFilters(); got != nil {
+		t.Errorf("unexpected filters with sequence monitor: %v", got)
+	}
+}
+
+func TestClientEventFilter(t *testing.T) {
+	events := make(chan Event, 3)
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		for _, name := range []string{"BACKGROUND_JOB", "CHANNEL_ANSWER", "HEARTBEAT", "SHUTDOWN_REQUESTED"} {
+			if cmd := srv.recv(); cmd != "filter Event-Name "+name {
+				t.Errorf("unexpected command: %q", cmd)
+			}
+
+			srv.reply("+OK filter added. [Event-Name]=[" + name + "]")
+		}
+
+		srv.sendEvent("Event-Name: CHANNEL_ANSWER", "variable_direction: inbound")
+		srv.sendEvent("Event-Name: CHANNEL_ANSWER", "variable_direction: outbound")
+		srv.sendEvent("Event-Name: CHANNEL_HANGUP", "variable_direction: inbound")
+		srv.send("Content-Type: text/disconnect-notice\n\n")
+	},
+		WithEvents(events, true),
+		WithEventFilter(MustParseExpr("Event-Name == CHANNEL_ANSWER && variable_direction == inbound")),
+	)
+
+	<-client.Done()
+
+	var names []string
+	for ev := range events {
+		names = append(names, ev.Name()+"/"+ev.Variable("direction"))
+	}
+
+	if !slices.Equal(names, []string{"CHANNEL_ANSWER/inbound"}) {
+		t.Errorf("unexpected events: %v", names)
+	}
+}
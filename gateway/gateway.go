@@ -0,0 +1,397 @@
+// Package gateway exposes the FreeSWITCH API commands and event streams over
+// HTTP, Server-Sent Events and WebSocket, for the clients that can't speak ESL,
+// such as browsers.
+//
+// The routes are:
+//
+//	POST /api     execute the API command and return its result
+//	POST /bgapi   execute the API command in the background and return the job UUID
+//	GET  /events  stream the events as Server-Sent Events
+//	GET  /ws      stream the events over WebSocket
+//
+// The command is passed as the request body, either as the plain text or as the
+// JSON object {"command": "status"}. The results are returned as JSON objects
+// {"result": "..."}, {"job_uuid": "..."} or {"error": "..."}.
+//
+// The events are encoded in JSON, and can be filtered per connection with the
+// query parameters:
+//
+//	name   comma-separated event names to subscribe to, can be repeated
+//	uuid   the Unique-ID of the channel
+//	expr   the filter expression, see esl.ParseExpr
+//
+// If the tokens are configured, each request must have the token in the
+// Authorization header ("Bearer <token>"). The WebSocket upgrade may pass it in
+// the "token" query parameter instead, because browsers can't set the headers
+// for WebSocket. The browsers may open the WebSocket only from the same origin
+// or from the origins permitted with WithOrigins.
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mdigger/esl"
+)
+
+// Client is the part of esl.Client used by the gateway.
+type Client interface {
+	API(command string) (string, error)
+	Job(command string) (string, error)
+	Subscribe(names ...string) error
+	Unsubscribe(names ...string) error
+}
+
+// Gateway is the http.Handler serving the API commands and the event streams.
+//
+// It implements esl.EventSink, so the events of the client can be passed to it
+// with esl.Pipe.
+type Gateway struct {
+	client  Client
+	tokens  [][]byte
+	allowed []string
+	origins []string
+	buffer  int
+	log     *slog.Logger
+	mux     *http.ServeMux
+
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+// Option configures the Gateway.
+type Option func(*Gateway)
+
+// WithTokens returns an Option that requires one of the tokens for every request.
+func WithTokens(tokens ...string) Option {
+	return func(g *Gateway) {
+		for _, token := range tokens {
+			if token != "" {
+				g.tokens = append(g.tokens, []byte(token))
+			}
+		}
+	}
+}
+
+// WithAllowedCommands returns an Option that permits only the given API
+// commands. The entry matches the command itself or the command with more
+// arguments, so "show channels" permits "show channels like 1000", but not
+// "show calls". If the list is not set, all commands are permitted.
+func WithAllowedCommands(commands ...string) Option {
+	return func(g *Gateway) {
+		for _, cmd := range commands {
+			if cmd = normalizeCommand(cmd); cmd != "" {
+				g.allowed = append(g.allowed, cmd)
+			}
+		}
+	}
+}
+
+// WithOrigins returns an Option that permits the WebSocket connections from
+// the browser pages of the given origins, such as "https://example.com", in
+// addition to the same origin. The "*" entry permits any origin.
+func WithOrigins(origins ...string) Option {
+	return func(g *Gateway) {
+		for _, origin := range origins {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				g.origins = append(g.origins, strings.ToLower(origin))
+			}
+		}
+	}
+}
+
+// WithBufferSize returns an Option that sets the number of events queued for
+// each stream. When a slow client falls behind, the events are dropped.
+func WithBufferSize(size int) Option {
+	return func(g *Gateway) {
+		g.buffer = max(size, 1)
+	}
+}
+
+// WithLog returns an Option that sets the logger.
+func WithLog(log *slog.Logger) Option {
+	return func(g *Gateway) {
+		g.log = log
+	}
+}
+
+// New returns a new Gateway for the client.
+func New(client Client, opts ...Option) *Gateway {
+	g := &Gateway{
+		client:  client,
+		tokens:  nil,
+		allowed: nil,
+		origins: nil,
+		buffer:  64, //nolint:mnd
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		mux:     http.NewServeMux(),
+		mu:      sync.RWMutex{},
+		subs:    make(map[*subscriber]struct{}),
+		closed:  false,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	g.mux.HandleFunc("POST /api", g.handleAPI)
+	g.mux.HandleFunc("POST /bgapi", g.handleJob)
+	g.mux.HandleFunc("GET /events", g.handleSSE)
+	g.mux.HandleFunc("GET /ws", g.handleWebSocket)
+
+	return g
+}
+
+// ServeHTTP checks the token and serves the request.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !g.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+
+		return
+	}
+
+	g.mux.ServeHTTP(w, r)
+}
+
+// WriteEvent sends the event to all streams with the matching filters. The
+// streams that can't keep up lose the event.
+func (g *Gateway) WriteEvent(_ context.Context, e esl.Event) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var data []byte // encoded only if someone needs it
+
+	for sub := range g.subs {
+		if !sub.match(e) {
+			continue
+		}
+
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(e); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+
+		select {
+		case sub.events <- data:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+
+	return nil
+}
+
+// Close ends all event streams.
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+
+	for sub := range g.subs {
+		close(sub.events)
+		delete(g.subs, sub)
+	}
+
+	return nil
+}
+
+// authorized reports whether the request has a valid token. The token in the
+// query is accepted only for the WebSocket upgrade, so that it does not leak
+// into the logs with the other requests.
+func (g *Gateway) authorized(r *http.Request) bool {
+	if len(g.tokens) == 0 {
+		return true
+	}
+
+	var token string
+	if r.Method == http.MethodGet && headerContains(r.Header, "Upgrade", "websocket") {
+		token = r.URL.Query().Get("token")
+	}
+
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(auth)
+	}
+
+	for _, t := range g.tokens {
+		if subtle.ConstantTimeCompare(t, []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// originAllowed reports whether the WebSocket connection is permitted from the
+// origin of the request. The requests without the Origin header are not sent
+// by the browsers and are permitted.
+func (g *Gateway) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+
+	return slices.ContainsFunc(g.origins, func(allowed string) bool {
+		return allowed == "*" || allowed == origin
+	})
+}
+
+// permitted reports whether the command is in the allowlist.
+func (g *Gateway) permitted(command string) bool {
+	if g.allowed == nil {
+		return true
+	}
+
+	for _, allowed := range g.allowed {
+		if command == allowed || strings.HasPrefix(command, allowed+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type (
+	commandRequest struct {
+		Command string `json:"command"`
+	}
+
+	resultResponse struct {
+		Result string `json:"result"`
+	}
+
+	jobResponse struct {
+		JobUUID string `json:"job_uuid"` //nolint:tagliatelle
+	}
+
+	errorResponse struct {
+		Error string `json:"error"`
+	}
+)
+
+// handleAPI executes the API command.
+func (g *Gateway) handleAPI(w http.ResponseWriter, r *http.Request) {
+	command, ok := g.command(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := g.client.API(command)
+	if err != nil {
+		g.writeError(w, command, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resultResponse{Result: result})
+}
+
+// handleJob executes the background API command.
+func (g *Gateway) handleJob(w http.ResponseWriter, r *http.Request) {
+	command, ok := g.command(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := g.client.Job(command)
+	if err != nil {
+		g.writeError(w, command, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, jobResponse{JobUUID: id})
+}
+
+// command reads the command from the request and checks it is permitted.
+// It writes the error response and returns false otherwise.
+func (g *Gateway) command(w http.ResponseWriter, r *http.Request) (string, bool) {
+	const maxCommandSize = 64 << 10
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCommandSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+
+		return "", false
+	}
+
+	command := string(data)
+
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+		var req commandRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+
+			return "", false
+		}
+
+		command = req.Command
+	}
+
+	// the command must be a single line: the rest would be sent as ESL headers
+	if command = normalizeCommand(command); command == "" || strings.ContainsAny(command, "\r\n") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid command"})
+
+		return "", false
+	}
+
+	if !g.permitted(command) {
+		g.log.Warn("gateway: command not permitted", slog.String("cmd", command))
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: "command not permitted"})
+
+		return "", false
+	}
+
+	return command, true
+}
+
+// writeError writes the error of the command.
+func (g *Gateway) writeError(w http.ResponseWriter, command string, err error) {
+	status := http.StatusBadGateway // connection problems
+
+	var replyErr *esl.ReplyError
+	if errors.As(err, &replyErr) {
+		status = http.StatusUnprocessableEntity // the command has failed
+	}
+
+	g.log.Info("gateway: command failed",
+		slog.String("cmd", command),
+		slog.String("err", err.Error()))
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON writes the value as the JSON response with the status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck,errchkjson
+}
+
+// normalizeCommand trims the command and collapses the spaces between the arguments.
+func normalizeCommand(s string) string {
+	if s = strings.TrimSpace(s); strings.ContainsAny(s, "\r\n") {
+		return s // rejected by the caller
+	}
+
+	return strings.Join(strings.Fields(s), " ")
+}
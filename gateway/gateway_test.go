@@ -0,0 +1,383 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mdigger/esl"
+)
+
+type fakeClient struct {
+	mu   sync.Mutex
+	subs []string
+}
+
+func (*fakeClient) API(command string) (string, error) {
+	if command == "fail" {
+		return "", &esl.ReplyError{Command: "api fail", Text: "-ERR fail Command not found!", Reason: "fail Command not found!"}
+	}
+
+	return "result of " + command, nil
+}
+
+func (*fakeClient) Job(string) (string, error) {
+	return "job-1", nil
+}
+
+// Subscribe and Unsubscribe count the subscriptions to all events without
+// names as "all", just like the esl.Client.
+func (c *fakeClient) Subscribe(names ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(names) == 0 {
+		names = []string{"all"}
+	}
+
+	c.subs = append(c.subs, names...)
+
+	return nil
+}
+
+func (c *fakeClient) Unsubscribe(names ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(names) == 0 {
+		names = []string{"all"}
+	}
+
+	for _, name := range names {
+		if i := slices.Index(c.subs, name); i >= 0 {
+			c.subs = slices.Delete(c.subs, i, i+1)
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeClient) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.subs)
+}
+
+func TestGatewayAPI(t *testing.T) {
+	gw := New(new(fakeClient),
+		WithTokens("secret"),
+		WithAllowedCommands("status", "show  channels", "fail"),
+	)
+
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	tests := []struct {
+		path, token, contentType, body string
+		status                         int
+		want                           string
+	}{
+		{"/api", "secret", "text/plain", "status\n", http.StatusOK, `{"result":"result of status"}`},
+		{"/api", "secret", "application/json", `{"command":"show channels like 1000"}`, http.StatusOK, `{"result":"result of show channels like 1000"}`},
+		{"/api", "secret", "text/plain", "show calls", http.StatusForbidden, `{"error":"command not permitted"}`},
+		{"/api", "secret", "text/plain", "statusx", http.StatusForbidden, `{"error":"command not permitted"}`},
+		{"/api", "secret", "text/plain", "status\nContent-Type: x", http.StatusBadRequest, `{"error":"invalid command"}`},
+		{"/api", "secret", "text/plain", "fail", http.StatusUnprocessableEntity, `{"error":"-ERR fail Command not found!"}`},
+		{"/api", "wrong", "text/plain", "status", http.StatusUnauthorized, `{"error":"unauthorized"}`},
+		{"/bgapi", "secret", "text/plain", "status", http.StatusAccepted, `{"job_uuid":"job-1"}`},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		req.Header.Set("Content-Type", tt.contentType)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status || strings.TrimSpace(string(data)) != tt.want {
+			t.Errorf("%s %q: got %d %s, want %d %s", tt.path, tt.body, resp.StatusCode, data, tt.status, tt.want)
+		}
+	}
+}
+
+func TestGatewaySSE(t *testing.T) {
+	client := new(fakeClient)
+	gw := New(client, WithTokens("secret"))
+
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	query := url.Values{
+		"name": {"CHANNEL_ANSWER,CHANNEL_HANGUP"},
+		"expr": {`Caller-Caller-ID-Number == 1000`},
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events?"+query.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+
+	if got := client.subscriptions(); len(got) != 2 {
+		t.Errorf("unexpected subscriptions: %v", got)
+	}
+
+	events := []esl.Event{
+		esl.NewEvent("CHANNEL_ANSWER", map[string]string{"Caller-Caller-ID-Number": "2000"}, nil),
+		esl.NewEvent("HEARTBEAT", map[string]string{"Caller-Caller-ID-Number": "1000"}, nil),
+		esl.NewEvent("CHANNEL_ANSWER", map[string]string{"Caller-Caller-ID-Number": "1000"}, nil),
+	}
+
+	for _, e := range events {
+		if err := gw.WriteEvent(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event esl.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Name() != "CHANNEL_ANSWER" || event.Get("Caller-Caller-ID-Number") != "1000" {
+		t.Errorf("unexpected event: %s", event)
+	}
+
+	gw.Close() // ends the stream
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Error(err)
+	}
+
+	for range 100 {
+		if len(client.subscriptions()) == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("subscriptions are not canceled: %v", client.subscriptions())
+}
+
+func TestGatewaySSEAllEvents(t *testing.T) {
+	client := new(fakeClient)
+	gw := New(client, WithTokens("secret"))
+	defer gw.Close()
+
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := client.subscriptions(); !slices.Equal(got, []string{"all"}) {
+		t.Errorf("unexpected subscriptions: %v", got)
+	}
+
+	cancel() // closes the stream
+
+	for range 100 {
+		if len(client.subscriptions()) == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("subscriptions are not canceled: %v", client.subscriptions())
+}
+
+func TestGatewayWebSocket(t *testing.T) {
+	gw := New(new(fakeClient), WithTokens("secret"))
+
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	conn, r, resp := dialWebSocket(t, srv, "/ws?uuid=call-1&token=secret", "")
+	defer conn.Close()
+
+	// the accept key from the RFC 6455 example for the same nonce
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake: %s %v", resp.Status, resp.Header)
+	}
+
+	gw.WriteEvent(context.Background(), esl.NewEvent("CHANNEL_ANSWER", map[string]string{"Unique-ID": "call-2"}, nil))
+	gw.WriteEvent(context.Background(), esl.NewEvent("CHANNEL_ANSWER", map[string]string{"Unique-ID": "call-1"}, nil))
+
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if header[0] != 0x80|opText || header[1]&0x80 != 0 {
+		t.Fatalf("unexpected frame header: %x", header)
+	}
+
+	payload := make([]byte, header[1])
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(payload), `"Unique-ID":"call-1"`) {
+		t.Errorf("unexpected message: %s", payload)
+	}
+
+	// masked close frame from the client
+	mask := []byte{1, 2, 3, 4}
+	body := binary.BigEndian.AppendUint16(nil, closeNormal)
+
+	for i := range body {
+		body[i] ^= mask[i%4]
+	}
+
+	conn.Write(append([]byte{0x80 | opClose, 0x80 | byte(len(body))}, append(mask, body...)...))
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if header[0] != 0x80|opClose {
+		t.Errorf("unexpected close reply: %x", header)
+	}
+}
+
+func TestGatewayWebSocketUnmasked(t *testing.T) {
+	srv := httptest.NewServer(New(new(fakeClient)))
+	defer srv.Close()
+
+	conn, r, resp := dialWebSocket(t, srv, "/ws", "")
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected handshake: %s", resp.Status)
+	}
+
+	conn.Write([]byte{0x80 | opPing, 0}) // unmasked frame
+
+	frame := make([]byte, 4)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if frame[0] != 0x80|opClose || binary.BigEndian.Uint16(frame[2:]) != closeProtocol {
+		t.Errorf("unexpected close frame: %x", frame)
+	}
+}
+
+func TestGatewayWebSocketOrigin(t *testing.T) {
+	srv := httptest.NewServer(New(new(fakeClient), WithOrigins("https://example.com")))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://" + host, http.StatusSwitchingProtocols},
+		{"https://EXAMPLE.com", http.StatusSwitchingProtocols},
+		{"https://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		conn, _, resp := dialWebSocket(t, srv, "/ws", tt.origin)
+		conn.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("origin %q: got %s, want %d", tt.origin, resp.Status, tt.status)
+		}
+	}
+}
+
+func TestGatewayQueryToken(t *testing.T) {
+	srv := httptest.NewServer(New(new(fakeClient), WithTokens("secret")))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api?token=secret", "text/plain", strings.NewReader("status"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("query token accepted for POST: %s", resp.Status)
+	}
+}
+
+// dialWebSocket sends the WebSocket handshake request with the origin and
+// returns the connection, its reader and the handshake response.
+func dialWebSocket(t *testing.T, srv *httptest.Server, path, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + base64.StdEncoding.EncodeToString([]byte("the sample nonce")) + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+
+	io.WriteString(conn, request+"\r\n")
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+
+	return conn, r, resp
+}
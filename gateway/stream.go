@@ -0,0 +1,227 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdigger/esl"
+)
+
+// keepAlive is the interval of the keep-alive messages of the idle streams.
+const keepAlive = 30 * time.Second
+
+// errClosed is returned when the gateway is closed.
+var errClosed = errors.New("gateway closed")
+
+// subscriber is the event stream of one connection.
+type subscriber struct {
+	names   map[string]bool // event names, all if empty
+	uuid    string          // Unique-ID of the channel
+	filter  *esl.Expr
+	events  chan []byte
+	dropped atomic.Uint64
+}
+
+// match reports whether the event passes the filters of the stream.
+func (s *subscriber) match(e esl.Event) bool {
+	if len(s.names) > 0 && !s.names[e.Name()] && !s.names[e.Get("Event-Name")] {
+		return false
+	}
+
+	if s.uuid != "" && e.Get("Unique-ID") != s.uuid {
+		return false
+	}
+
+	return s.filter.Match(e)
+}
+
+// subscribe registers the stream with the filters from the query parameters
+// and subscribes the client to the requested events.
+func (g *Gateway) subscribe(r *http.Request) (*subscriber, error) {
+	query := r.URL.Query()
+
+	var filter *esl.Expr
+
+	if expr := query.Get("expr"); expr != "" {
+		var err error
+		if filter, err = esl.ParseExpr(expr); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+	}
+
+	sub := &subscriber{
+		names:   make(map[string]bool),
+		uuid:    query.Get("uuid"),
+		filter:  filter,
+		events:  make(chan []byte, g.buffer),
+		dropped: atomic.Uint64{},
+	}
+
+	for _, names := range query["name"] {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				sub.names[name] = true
+			}
+		}
+	}
+
+	if err := g.client.Subscribe(sub.eventNames()...); err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		g.cancel(sub)
+
+		return nil, errClosed
+	}
+
+	g.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// unsubscribe removes the stream and cancels its event subscriptions.
+func (g *Gateway) unsubscribe(sub *subscriber) {
+	g.mu.Lock()
+	delete(g.subs, sub)
+	g.mu.Unlock()
+
+	g.cancel(sub)
+
+	if dropped := sub.dropped.Load(); dropped > 0 {
+		g.log.Warn("gateway: slow stream", slog.Uint64("dropped", dropped))
+	}
+}
+
+// cancel cancels the event subscriptions of the stream.
+func (g *Gateway) cancel(sub *subscriber) {
+	if err := g.client.Unsubscribe(sub.eventNames()...); err != nil {
+		g.log.Warn("gateway: failed to unsubscribe", slog.String("err", err.Error()))
+	}
+}
+
+// eventNames returns the names of the events of the stream.
+func (s *subscriber) eventNames() []string {
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// handleSSE streams the events as Server-Sent Events.
+func (g *Gateway) handleSSE(w http.ResponseWriter, r *http.Request) {
+	sub, err := g.subscribe(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+
+		return
+	}
+	defer g.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return // streaming is not supported
+	}
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = w.Write([]byte(": keep-alive\n\n"))
+		case data, ok := <-sub.events:
+			if !ok {
+				return // gateway closed
+			}
+
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			return // client is gone
+		}
+	}
+}
+
+// handleWebSocket streams the events over WebSocket as the text messages.
+func (g *Gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !g.originAllowed(r) {
+		g.log.Warn("gateway: websocket origin not permitted", slog.String("origin", r.Header.Get("Origin")))
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: "origin not permitted"})
+
+		return
+	}
+
+	sub, err := g.subscribe(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+
+		return
+	}
+	defer g.unsubscribe(sub)
+
+	ws, err := upgrade(w, r)
+	if err != nil {
+		g.log.Info("gateway: websocket upgrade failed", slog.String("err", err.Error()))
+
+		return
+	}
+	defer ws.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		ws.readLoop() // handles pings and waits for the close frame
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			err = ws.writeFrame(opPing, nil)
+		case data, ok := <-sub.events:
+			if !ok {
+				ws.writeClose(closeGoingAway, "gateway closed") //nolint:errcheck
+
+				return
+			}
+
+			err = ws.writeFrame(opText, data)
+		}
+
+		if err != nil {
+			return // client is gone
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The minimal server side of the WebSocket protocol (RFC 6455), enough to
+// stream the events to the browsers: the messages sent by the client are
+// ignored, except the control frames.
+
+// WebSocket opcodes.
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// WebSocket close status codes.
+const (
+	closeNormal    = 1000
+	closeGoingAway = 1001
+	closeProtocol  = 1002
+	closeTooBig    = 1009
+)
+
+// websocketGUID is used to compute the Sec-WebSocket-Accept header.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize limits the size of the messages read from the client.
+const maxMessageSize = 64 << 10
+
+// writeTimeout limits the time to write a frame to the slow client.
+const writeTimeout = 10 * time.Second
+
+var errBadHandshake = errors.New("bad websocket handshake")
+
+// websocket is the server side of the WebSocket connection.
+type websocket struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex // serializes the writes
+}
+
+// upgrade performs the WebSocket handshake and takes over the connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*websocket, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-Websocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-Websocket-Version", "13")
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errBadHandshake.Error()})
+
+		return nil, errBadHandshake
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+
+		return nil, err //nolint:wrapcheck
+	}
+
+	hash := sha1.Sum([]byte(key + websocketGUID)) //nolint:gosec
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+
+		return nil, err //nolint:wrapcheck
+	}
+
+	conn.SetDeadline(time.Time{}) //nolint:errcheck // reset server timeouts
+
+	return &websocket{
+		conn: conn,
+		r:    rw.Reader,
+		mu:   sync.Mutex{},
+	}, nil
+}
+
+// Close closes the connection.
+func (ws *websocket) Close() error {
+	return ws.conn.Close() //nolint:wrapcheck
+}
+
+// writeFrame writes the unmasked final frame with the payload.
+func (ws *websocket) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10) //nolint:mnd // max header size
+	header[0] = 0x80 | opcode     // FIN
+
+	switch n := len(payload); {
+	case n < 126: //nolint:mnd
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.conn.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
+
+	_, err := (&net.Buffers{header, payload}).WriteTo(ws.conn)
+
+	return err //nolint:wrapcheck
+}
+
+// writeClose sends the close frame with the status code and the reason.
+func (ws *websocket) writeClose(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+
+	return ws.writeFrame(opClose, append(payload, reason...))
+}
+
+// readLoop reads the frames from the client until the connection is closed,
+// answering the pings and the close frame.
+func (ws *websocket) readLoop() {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			switch {
+			case errors.Is(err, errTooBig):
+				ws.writeClose(closeTooBig, "message too big") //nolint:errcheck
+			case errors.Is(err, errUnmasked):
+				ws.writeClose(closeProtocol, "unmasked frame") //nolint:errcheck
+			}
+
+			return
+		}
+
+		switch opcode {
+		case opPing:
+			if ws.writeFrame(opPong, payload) != nil {
+				return
+			}
+		case opClose:
+			ws.writeClose(closeNormal, "") //nolint:errcheck
+
+			return
+		}
+	}
+}
+
+var (
+	errTooBig   = errors.New("websocket message too big")
+	errUnmasked = errors.New("websocket frame is not masked")
+)
+
+// readFrame reads the frame sent by the client and unmasks its payload.
+// The client must mask all frames (RFC 6455, section 5.1).
+func (ws *websocket) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return 0, nil, err //nolint:wrapcheck
+	}
+
+	opcode := header[0] & 0x0F
+	length := uint64(header[1] & 0x7F)
+
+	if header[1]&0x80 == 0 {
+		return 0, nil, errUnmasked
+	}
+
+	switch length {
+	case 126: //nolint:mnd
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err //nolint:wrapcheck
+		}
+
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127: //nolint:mnd
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err //nolint:wrapcheck
+		}
+
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > maxMessageSize {
+		return 0, nil, errTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return 0, nil, err //nolint:wrapcheck
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return 0, nil, err //nolint:wrapcheck
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return opcode, payload, nil
+}
+
+// headerContains reports whether the comma-separated header contains the token.
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
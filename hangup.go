@@ -0,0 +1,375 @@
+package esl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// HangupCause is a FreeSWITCH hangup cause.
+//
+// Its value is the numeric code of the cause: the Q.850 cause code for the
+// standard causes (up to 127), or the FreeSWITCH specific code for the rest.
+type HangupCause uint16
+
+// CauseClass is a class of the hangup causes with the similar outcome of the call.
+type CauseClass uint8
+
+// Classes of the hangup causes.
+const (
+	ClassOther          CauseClass = iota // not classified
+	ClassNormal                           // the call was completed normally
+	ClassBusy                             // the called party is busy
+	ClassNoAnswer                         // the called party did not answer or is absent
+	ClassNetworkFailure                   // temporary failure of the network or equipment
+	ClassRejected                         // the call was rejected or can not be routed
+)
+
+// String returns the name of the class.
+func (c CauseClass) String() string {
+	switch c {
+	case ClassNormal:
+		return "normal"
+	case ClassBusy:
+		return "busy"
+	case ClassNoAnswer:
+		return "no answer"
+	case ClassNetworkFailure:
+		return "network failure"
+	case ClassRejected:
+		return "rejected"
+	default:
+		return "other"
+	}
+}
+
+// String returns the FreeSWITCH name of the cause, e.g. NORMAL_CLEARING.
+func (c HangupCause) String() string {
+	if info, ok := hangupCauses[c]; ok {
+		return info.name
+	}
+
+	return "HangupCause(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Code returns the numeric code of the cause.
+func (c HangupCause) Code() int {
+	return int(c)
+}
+
+// IsQ850 reports whether the cause is defined by Q.850 rather than by FreeSWITCH.
+func (c HangupCause) IsQ850() bool {
+	const maxQ850 = 127
+
+	return c <= maxQ850
+}
+
+// SIPStatus returns the SIP response status code FreeSWITCH replies with for
+// this cause, or 0 if there is no such mapping (e.g. for NORMAL_CLEARING,
+// which results in BYE).
+func (c HangupCause) SIPStatus() int {
+	return hangupCauses[c].sip
+}
+
+// Class returns the class of the cause.
+func (c HangupCause) Class() CauseClass {
+	return hangupCauses[c].class
+}
+
+// Known reports whether the cause is present in the FreeSWITCH cause table.
+func (c HangupCause) Known() bool {
+	_, ok := hangupCauses[c]
+
+	return ok
+}
+
+// ParseHangupCause returns the hangup cause by its name, e.g. NORMAL_CLEARING,
+// or by its numeric code.
+func ParseHangupCause(s string) (HangupCause, bool) {
+	s = strings.TrimSpace(s)
+	if cause, ok := hangupCausesByName[strings.ToUpper(s)]; ok {
+		return cause, true
+	}
+
+	if code, err := strconv.ParseUint(s, 10, 16); err == nil {
+		cause := HangupCause(code)
+
+		return cause, cause.Known()
+	}
+
+	return CauseNone, false
+}
+
+// HangupCauseFromSIP returns the hangup cause FreeSWITCH sets when the call
+// fails with the given SIP response status code.
+func HangupCauseFromSIP(status int) HangupCause {
+	//nolint:mnd // SIP status codes
+	switch status {
+	case 200:
+		return CauseNormalClearing
+	case 401, 402, 403, 407, 603, 608:
+		return CauseCallRejected
+	case 404:
+		return CauseUnallocatedNumber
+	case 485, 604:
+		return CauseNoRouteDestination
+	case 408, 504:
+		return CauseRecoveryOnTimerExpire
+	case 410:
+		return CauseNumberChanged
+	case 413, 414, 416, 420, 421, 423, 505, 513:
+		return CauseInterworking
+	case 480:
+		return CauseNoUserResponse
+	case 400, 481, 500, 503:
+		return CauseNormalTemporaryFailure
+	case 486, 600:
+		return CauseUserBusy
+	case 484:
+		return CauseInvalidNumberFormat
+	case 488, 606:
+		return CauseIncompatibleDestination
+	case 502:
+		return CauseNetworkOutOfOrder
+	case 405:
+		return CauseServiceUnavailable
+	case 406, 415, 501:
+		return CauseServiceNotImplemented
+	case 482, 483:
+		return CauseExchangeRoutingError
+	case 487:
+		return CauseOriginatorCancel
+	case 428:
+		return CauseNoIdentity
+	case 429:
+		return CauseBadIdentityInfo
+	case 437:
+		return CauseUnsupportedCertificate
+	case 438:
+		return CauseInvalidIdentity
+	case 607:
+		return CauseUnwanted
+	default:
+		return CauseNormalUnspecified
+	}
+}
+
+// HangupCause returns the hangup cause of the event from the Hangup-Cause
+// header or from the hangup_cause channel variable.
+func (e Event) HangupCause() (HangupCause, bool) {
+	name := e.Get("Hangup-Cause")
+	if name == "" {
+		name = e.Variable("hangup_cause")
+	}
+
+	if name == "" {
+		return CauseNone, false
+	}
+
+	return ParseHangupCause(name)
+}
+
+// HangupCauseOf returns the hangup cause the command failed with, such as
+// the originate command replying "-ERR NO_ANSWER".
+func HangupCauseOf(err error) (HangupCause, bool) {
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) {
+		return CauseNone, false
+	}
+
+	return ParseHangupCause(replyErr.Reason)
+}
+
+// hangupCausesByName maps the names of the hangup causes to their values.
+//
+//nolint:gochecknoglobals
+var hangupCausesByName = func() map[string]HangupCause {
+	names := make(map[string]HangupCause, len(hangupCauses))
+	for cause, info := range hangupCauses {
+		names[info.name] = cause
+	}
+
+	return names
+}()
+
+// Hangup causes.
+//
+// spell-checker:disable
+const (
+	CauseNone                        HangupCause = 0
+	CauseUnallocatedNumber           HangupCause = 1
+	CauseNoRouteTransitNet           HangupCause = 2
+	CauseNoRouteDestination          HangupCause = 3
+	CauseChannelUnacceptable         HangupCause = 6
+	CauseCallAwardedDelivered        HangupCause = 7
+	CauseNormalClearing              HangupCause = 16
+	CauseUserBusy                    HangupCause = 17
+	CauseNoUserResponse              HangupCause = 18
+	CauseNoAnswer                    HangupCause = 19
+	CauseSubscriberAbsent            HangupCause = 20
+	CauseCallRejected                HangupCause = 21
+	CauseNumberChanged               HangupCause = 22
+	CauseRedirectionToNewDestination HangupCause = 23
+	CauseExchangeRoutingError        HangupCause = 25
+	CauseDestinationOutOfOrder       HangupCause = 27
+	CauseInvalidNumberFormat         HangupCause = 28
+	CauseFacilityRejected            HangupCause = 29
+	CauseResponseToStatusEnquiry     HangupCause = 30
+	CauseNormalUnspecified           HangupCause = 31
+	CauseNormalCircuitCongestion     HangupCause = 34
+	CauseNetworkOutOfOrder           HangupCause = 38
+	CauseNormalTemporaryFailure      HangupCause = 41
+	CauseSwitchCongestion            HangupCause = 42
+	CauseAccessInfoDiscarded         HangupCause = 43
+	CauseRequestedChanUnavail        HangupCause = 44
+	CausePreEmpted                   HangupCause = 45
+	CauseFacilityNotSubscribed       HangupCause = 50
+	CauseOutgoingCallBarred          HangupCause = 52
+	CauseIncomingCallBarred          HangupCause = 54
+	CauseBearerCapabilityNotAuth     HangupCause = 57
+	CauseBearerCapabilityNotAvail    HangupCause = 58
+	CauseServiceUnavailable          HangupCause = 63
+	CauseBearerCapabilityNotImpl     HangupCause = 65
+	CauseChanNotImplemented          HangupCause = 66
+	CauseFacilityNotImplemented      HangupCause = 69
+	CauseServiceNotImplemented       HangupCause = 79
+	CauseInvalidCallReference        HangupCause = 81
+	CauseIncompatibleDestination     HangupCause = 88
+	CauseInvalidMsgUnspecified       HangupCause = 95
+	CauseMandatoryIEMissing          HangupCause = 96
+	CauseMessageTypeNonExist         HangupCause = 97
+	CauseWrongMessage                HangupCause = 98
+	CauseIENonExist                  HangupCause = 99
+	CauseInvalidIEContents           HangupCause = 100
+	CauseWrongCallState              HangupCause = 101
+	CauseRecoveryOnTimerExpire       HangupCause = 102
+	CauseMandatoryIELengthError      HangupCause = 103
+	CauseProtocolError               HangupCause = 111
+	CauseInterworking                HangupCause = 127
+	CauseSuccess                     HangupCause = 142
+	CauseOriginatorCancel            HangupCause = 487
+	CauseCrash                       HangupCause = 700
+	CauseSystemShutdown              HangupCause = 701
+	CauseLoseRace                    HangupCause = 702
+	CauseManagerRequest              HangupCause = 703
+	CauseBlindTransfer               HangupCause = 800
+	CauseAttendedTransfer            HangupCause = 801
+	CauseAllottedTimeout             HangupCause = 802
+	CauseUserChallenge               HangupCause = 803
+	CauseMediaTimeout                HangupCause = 804
+	CausePickedOff                   HangupCause = 805
+	CauseUserNotRegistered           HangupCause = 806
+	CauseProgressTimeout             HangupCause = 807
+	CauseInvalidGateway              HangupCause = 808
+	CauseGatewayDown                 HangupCause = 809
+	CauseInvalidURL                  HangupCause = 810
+	CauseInvalidProfile              HangupCause = 811
+	CauseNoPickup                    HangupCause = 812
+	CauseSRTPReadError               HangupCause = 813
+	CauseBowout                      HangupCause = 814
+	CauseBusyEverywhere              HangupCause = 815
+	CauseDecline                     HangupCause = 816
+	CauseDoesNotExistAnywhere        HangupCause = 817
+	CauseNotAcceptable               HangupCause = 818
+	CauseUnwanted                    HangupCause = 819
+	CauseNoIdentity                  HangupCause = 820
+	CauseBadIdentityInfo             HangupCause = 821
+	CauseUnsupportedCertificate      HangupCause = 822
+	CauseInvalidIdentity             HangupCause = 823
+	CauseStaleDate                   HangupCause = 824
+	CauseRejectAll                   HangupCause = 825
+)
+
+// hangupCauses contains the names, SIP response codes and classes of the known causes.
+//
+//nolint:gochecknoglobals
+var hangupCauses = map[HangupCause]struct {
+	name  string
+	sip   int
+	class CauseClass
+}{
+	CauseNone:                        {"NONE", 0, ClassOther},
+	CauseUnallocatedNumber:           {"UNALLOCATED_NUMBER", 404, ClassRejected},
+	CauseNoRouteTransitNet:           {"NO_ROUTE_TRANSIT_NET", 404, ClassRejected},
+	CauseNoRouteDestination:          {"NO_ROUTE_DESTINATION", 404, ClassRejected},
+	CauseChannelUnacceptable:         {"CHANNEL_UNACCEPTABLE", 0, ClassRejected},
+	CauseCallAwardedDelivered:        {"CALL_AWARDED_DELIVERED", 0, ClassNormal},
+	CauseNormalClearing:              {"NORMAL_CLEARING", 0, ClassNormal},
+	CauseUserBusy:                    {"USER_BUSY", 486, ClassBusy},
+	CauseNoUserResponse:              {"NO_USER_RESPONSE", 408, ClassNoAnswer},
+	CauseNoAnswer:                    {"NO_ANSWER", 480, ClassNoAnswer},
+	CauseSubscriberAbsent:            {"SUBSCRIBER_ABSENT", 480, ClassNoAnswer},
+	CauseCallRejected:                {"CALL_REJECTED", 603, ClassRejected},
+	CauseNumberChanged:               {"NUMBER_CHANGED", 410, ClassRejected},
+	CauseRedirectionToNewDestination: {"REDIRECTION_TO_NEW_DESTINATION", 410, ClassRejected},
+	CauseExchangeRoutingError:        {"EXCHANGE_ROUTING_ERROR", 483, ClassNetworkFailure},
+	CauseDestinationOutOfOrder:       {"DESTINATION_OUT_OF_ORDER", 502, ClassNetworkFailure},
+	CauseInvalidNumberFormat:         {"INVALID_NUMBER_FORMAT", 484, ClassRejected},
+	CauseFacilityRejected:            {"FACILITY_REJECTED", 501, ClassRejected},
+	CauseResponseToStatusEnquiry:     {"RESPONSE_TO_STATUS_ENQUIRY", 0, ClassOther},
+	CauseNormalUnspecified:           {"NORMAL_UNSPECIFIED", 480, ClassNormal},
+	CauseNormalCircuitCongestion:     {"NORMAL_CIRCUIT_CONGESTION", 503, ClassNetworkFailure},
+	CauseNetworkOutOfOrder:           {"NETWORK_OUT_OF_ORDER", 502, ClassNetworkFailure},
+	CauseNormalTemporaryFailure:      {"NORMAL_TEMPORARY_FAILURE", 503, ClassNetworkFailure},
+	CauseSwitchCongestion:            {"SWITCH_CONGESTION", 503, ClassNetworkFailure},
+	CauseAccessInfoDiscarded:         {"ACCESS_INFO_DISCARDED", 0, ClassNetworkFailure},
+	CauseRequestedChanUnavail:        {"REQUESTED_CHAN_UNAVAIL", 503, ClassNetworkFailure},
+	CausePreEmpted:                   {"PRE_EMPTED", 0, ClassNetworkFailure},
+	CauseFacilityNotSubscribed:       {"FACILITY_NOT_SUBSCRIBED", 0, ClassRejected},
+	CauseOutgoingCallBarred:          {"OUTGOING_CALL_BARRED", 403, ClassRejected},
+	CauseIncomingCallBarred:          {"INCOMING_CALL_BARRED", 403, ClassRejected},
+	CauseBearerCapabilityNotAuth:     {"BEARERCAPABILITY_NOTAUTH", 403, ClassRejected},
+	CauseBearerCapabilityNotAvail:    {"BEARERCAPABILITY_NOTAVAIL", 503, ClassRejected},
+	CauseServiceUnavailable:          {"SERVICE_UNAVAILABLE", 503, ClassNetworkFailure},
+	CauseBearerCapabilityNotImpl:     {"BEARERCAPABILITY_NOTIMPL", 488, ClassRejected},
+	CauseChanNotImplemented:          {"CHAN_NOT_IMPLEMENTED", 0, ClassRejected},
+	CauseFacilityNotImplemented:      {"FACILITY_NOT_IMPLEMENTED", 501, ClassRejected},
+	CauseServiceNotImplemented:       {"SERVICE_NOT_IMPLEMENTED", 501, ClassRejected},
+	CauseInvalidCallReference:        {"INVALID_CALL_REFERENCE", 0, ClassNetworkFailure},
+	CauseIncompatibleDestination:     {"INCOMPATIBLE_DESTINATION", 488, ClassRejected},
+	CauseInvalidMsgUnspecified:       {"INVALID_MSG_UNSPECIFIED", 0, ClassNetworkFailure},
+	CauseMandatoryIEMissing:          {"MANDATORY_IE_MISSING", 0, ClassNetworkFailure},
+	CauseMessageTypeNonExist:         {"MESSAGE_TYPE_NONEXIST", 0, ClassNetworkFailure},
+	CauseWrongMessage:                {"WRONG_MESSAGE", 0, ClassNetworkFailure},
+	CauseIENonExist:                  {"IE_NONEXIST", 0, ClassNetworkFailure},
+	CauseInvalidIEContents:           {"INVALID_IE_CONTENTS", 0, ClassNetworkFailure},
+	CauseWrongCallState:              {"WRONG_CALL_STATE", 0, ClassNetworkFailure},
+	CauseRecoveryOnTimerExpire:       {"RECOVERY_ON_TIMER_EXPIRE", 504, ClassNetworkFailure},
+	CauseMandatoryIELengthError:      {"MANDATORY_IE_LENGTH_ERROR", 0, ClassNetworkFailure},
+	CauseProtocolError:               {"PROTOCOL_ERROR", 0, ClassNetworkFailure},
+	CauseInterworking:                {"INTERWORKING", 500, ClassNetworkFailure},
+	CauseSuccess:                     {"SUCCESS", 0, ClassNormal},
+	CauseOriginatorCancel:            {"ORIGINATOR_CANCEL", 487, ClassNoAnswer},
+	CauseCrash:                       {"CRASH", 0, ClassNetworkFailure},
+	CauseSystemShutdown:              {"SYSTEM_SHUTDOWN", 0, ClassNetworkFailure},
+	CauseLoseRace:                    {"LOSE_RACE", 0, ClassNormal},
+	CauseManagerRequest:              {"MANAGER_REQUEST", 0, ClassNormal},
+	CauseBlindTransfer:               {"BLIND_TRANSFER", 0, ClassNormal},
+	CauseAttendedTransfer:            {"ATTENDED_TRANSFER", 0, ClassNormal},
+	CauseAllottedTimeout:             {"ALLOTTED_TIMEOUT", 0, ClassNormal},
+	CauseUserChallenge:               {"USER_CHALLENGE", 0, ClassRejected},
+	CauseMediaTimeout:                {"MEDIA_TIMEOUT", 0, ClassNetworkFailure},
+	CausePickedOff:                   {"PICKED_OFF", 0, ClassNormal},
+	CauseUserNotRegistered:           {"USER_NOT_REGISTERED", 0, ClassNoAnswer},
+	CauseProgressTimeout:             {"PROGRESS_TIMEOUT", 0, ClassNoAnswer},
+	CauseInvalidGateway:              {"INVALID_GATEWAY", 0, ClassRejected},
+	CauseGatewayDown:                 {"GATEWAY_DOWN", 503, ClassNetworkFailure},
+	CauseInvalidURL:                  {"INVALID_URL", 0, ClassRejected},
+	CauseInvalidProfile:              {"INVALID_PROFILE", 0, ClassRejected},
+	CauseNoPickup:                    {"NO_PICKUP", 0, ClassNoAnswer},
+	CauseSRTPReadError:               {"SRTP_READ_ERROR", 0, ClassNetworkFailure},
+	CauseBowout:                      {"BOWOUT", 0, ClassNormal},
+	CauseBusyEverywhere:              {"BUSY_EVERYWHERE", 600, ClassBusy},
+	CauseDecline:                     {"DECLINE", 603, ClassRejected},
+	CauseDoesNotExistAnywhere:        {"DOES_NOT_EXIST_ANYWHERE", 604, ClassRejected},
+	CauseNotAcceptable:               {"NOT_ACCEPTABLE", 606, ClassRejected},
+	CauseUnwanted:                    {"UNWANTED", 607, ClassRejected},
+	CauseNoIdentity:                  {"NO_IDENTITY", 428, ClassRejected},
+	CauseBadIdentityInfo:             {"BAD_IDENTITY_INFO", 429, ClassRejected},
+	CauseUnsupportedCertificate:      {"UNSUPPORTED_CERTIFICATE", 437, ClassRejected},
+	CauseInvalidIdentity:             {"INVALID_IDENTITY", 438, ClassRejected},
+	CauseStaleDate:                   {"STALE_DATE", 403, ClassRejected},
+	CauseRejectAll:                   {"REJECT_ALL", 603, ClassRejected},
+}
+
+// spell-checker:enable
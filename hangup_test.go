@@ -0,0 +1,76 @@
+package esl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHangupCause(t *testing.T) {
+	tests := []struct {
+		name  string
+		cause HangupCause
+		code  int
+		sip   int
+		class CauseClass
+	}{
+		// spell-checker:disable
+		{"NORMAL_CLEARING", CauseNormalClearing, 16, 0, ClassNormal},
+		{"USER_BUSY", CauseUserBusy, 17, 486, ClassBusy},
+		{"NO_ANSWER", CauseNoAnswer, 19, 480, ClassNoAnswer},
+		{"CALL_REJECTED", CauseCallRejected, 21, 603, ClassRejected},
+		{"NETWORK_OUT_OF_ORDER", CauseNetworkOutOfOrder, 38, 502, ClassNetworkFailure},
+		{"ORIGINATOR_CANCEL", CauseOriginatorCancel, 487, 487, ClassNoAnswer},
+		{"GATEWAY_DOWN", CauseGatewayDown, 809, 503, ClassNetworkFailure},
+		{"BEARERCAPABILITY_NOTAUTH", CauseBearerCapabilityNotAuth, 57, 403, ClassRejected},
+		// spell-checker:enable
+	}
+
+	for _, tc := range tests {
+		cause, ok := ParseHangupCause(tc.name)
+		if !ok || cause != tc.cause {
+			t.Errorf("%s: parsed as %v", tc.name, cause)
+		}
+
+		if cause.String() != tc.name {
+			t.Errorf("%s: name %s", tc.name, cause)
+		}
+
+		if cause.Code() != tc.code || cause.SIPStatus() != tc.sip || cause.Class() != tc.class {
+			t.Errorf("%s: code %d, sip %d, class %s", tc.name, cause.Code(), cause.SIPStatus(), cause.Class())
+		}
+	}
+
+	if cause, ok := ParseHangupCause("17"); !ok || cause != CauseUserBusy {
+		t.Errorf("unexpected cause: %v", cause)
+	}
+
+	if _, ok := ParseHangupCause("NO_SUCH_CAUSE"); ok {
+		t.Error("unknown cause parsed")
+	}
+
+	if cause := HangupCauseFromSIP(486); cause != CauseUserBusy {
+		t.Errorf("unexpected cause for 486: %v", cause)
+	}
+}
+
+func TestHangupCauseOf(t *testing.T) {
+	event := NewEvent("CHANNEL_HANGUP", map[string]string{
+		"variable_hangup_cause": "NO_ANSWER",
+	}, nil)
+	if cause, ok := event.HangupCause(); !ok || cause != CauseNoAnswer {
+		t.Errorf("unexpected event cause: %v", cause)
+	}
+
+	err := error(newReplyError("-ERR USER_BUSY\n"))
+	if cause, ok := HangupCauseOf(err); !ok || cause != CauseUserBusy {
+		t.Errorf("unexpected error cause: %v", cause)
+	}
+
+	if !errors.Is(err, ErrHangup) {
+		t.Error("error is not a hangup")
+	}
+
+	if _, ok := HangupCauseOf(errors.New("-ERR USER_BUSY")); ok {
+		t.Error("cause of not a reply error")
+	}
+}
@@ -0,0 +1,140 @@
+package esl
+
+import (
+	"context"
+	"time"
+)
+
+// Command is the view of the command sent to the server, passed to the hooks.
+type Command struct {
+	Name    string // the command name, e.g. "api"
+	Params  string // the command parameters, the password of auth is hidden
+	JobUUID string // the Job-UUID of the background job, if specified
+}
+
+// newCommand returns the view of the command.
+func newCommand(c command) Command {
+	params := c.params
+	if c.name == "auth" && params != "" {
+		params = "*****"
+	}
+
+	return Command{Name: c.name, Params: params, JobUUID: c.jobUUID}
+}
+
+// Line returns the command name with parameters.
+func (c Command) Line() string {
+	if c.Params == "" {
+		return c.Name
+	}
+
+	return c.Name + " " + c.Params
+}
+
+// The reasons of the dropped events passed to Hooks.EventDropped.
+const (
+	DropFiltered = "filtered" // the event does not match the client-side filter
+	DropInvalid  = "invalid"  // the event failed to parse
+)
+
+// Hooks are the callbacks called by the client on its activity, used to
+// collect the metrics and traces. All callbacks are optional.
+//
+// The callbacks are called synchronously, so they should return quickly.
+// The event callbacks are called by the goroutine reading the connection.
+type Hooks struct {
+	// Dial is called after the connection is dialed by Connect.
+	Dial func(addr string, duration time.Duration, err error)
+
+	// Auth is called after the authentication.
+	Auth func(duration time.Duration, err error)
+
+	// CommandSent is called before the command is sent. The context of the
+	// command is passed to it and the returned context is passed to the
+	// ReplyReceived, so a span of the command can be started in the trace of
+	// the caller.
+	CommandSent func(ctx context.Context, cmd Command) context.Context
+
+	// ReplyReceived is called when the reply to the command is received, or
+	// the command failed. The latency is the time since the command was sent.
+	ReplyReceived func(ctx context.Context, cmd Command, resp Response, latency time.Duration, err error)
+
+	// EventReceived is called for each received event, before filtering.
+	EventReceived func(e Event)
+
+	// EventDropped is called when the event is not delivered, with the reason:
+	// DropFiltered or DropInvalid.
+	EventDropped func(reason string)
+
+	// Disconnect is called when the connection is closed, with the reason.
+	Disconnect func(err error)
+}
+
+// WithHooks returns an Option that adds the hooks to the client. Several
+// hooks are called in the order they are added.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, h)
+	}
+}
+
+// hooks is the list of the hooks added to the client.
+type hooks []Hooks
+
+func (hs hooks) dial(addr string, d time.Duration, err error) {
+	for _, h := range hs {
+		if h.Dial != nil {
+			h.Dial(addr, d, err)
+		}
+	}
+}
+
+func (hs hooks) auth(d time.Duration, err error) {
+	for _, h := range hs {
+		if h.Auth != nil {
+			h.Auth(d, err)
+		}
+	}
+}
+
+func (hs hooks) commandSent(ctx context.Context, cmd Command) context.Context {
+	for _, h := range hs {
+		if h.CommandSent != nil {
+			ctx = h.CommandSent(ctx, cmd)
+		}
+	}
+
+	return ctx
+}
+
+func (hs hooks) replyReceived(ctx context.Context, cmd Command, resp Response, latency time.Duration, err error) {
+	for _, h := range hs {
+		if h.ReplyReceived != nil {
+			h.ReplyReceived(ctx, cmd, resp, latency, err)
+		}
+	}
+}
+
+func (hs hooks) eventReceived(e Event) {
+	for _, h := range hs {
+		if h.EventReceived != nil {
+			h.EventReceived(e)
+		}
+	}
+}
+
+func (hs hooks) eventDropped(reason string) {
+	for _, h := range hs {
+		if h.EventDropped != nil {
+			h.EventDropped(reason)
+		}
+	}
+}
+
+func (hs hooks) disconnect(err error) {
+	for _, h := range hs {
+		if h.Disconnect != nil {
+			h.Disconnect(err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package esl
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	type ctxKey struct{}
+
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+
+	record := func(s string) {
+		mu.Lock()
+		calls = append(calls, s)
+		mu.Unlock()
+	}
+
+	disconnected := make(chan struct{})
+
+	hooks := Hooks{
+		Auth: func(_ time.Duration, err error) { record("auth " + errString(err)) },
+		CommandSent: func(ctx context.Context, cmd Command) context.Context {
+			record("sent " + cmd.Line())
+
+			return context.WithValue(ctx, ctxKey{}, cmd.Line())
+		},
+		ReplyReceived: func(ctx context.Context, cmd Command, _ Response, latency time.Duration, err error) {
+			if ctx.Value(ctxKey{}) != cmd.Line() || latency <= 0 {
+				t.Errorf("unexpected reply context or latency: %v", latency)
+			}
+
+			record("reply " + cmd.Line() + " " + errString(err))
+		},
+		EventReceived: func(e Event) { record("event " + e.Name()) },
+		EventDropped:  func(reason string) { record("dropped " + reason) },
+		Disconnect:    func(error) { close(disconnected) },
+	}
+
+	filter, err := ParseExpr(`Unique-ID =~ "1$"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan Event, 1)
+	replied := make(chan struct{})
+	client := newFakeClient(t, func(srv *fakeServer) {
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 10\n\n-ERR fail\n")
+		<-replied // keep the order of the hook calls
+		srv.sendEvent("Event-Name: CHANNEL_CREATE", "Unique-ID: call-2")
+		srv.sendEvent("Event-Name: CHANNEL_ANSWER", "Unique-ID: call-1")
+		srv.send("Content-Type: text/disconnect-notice\n\n")
+	}, WithEvents(events), WithEventFilter(filter), WithHooks(hooks))
+
+	if _, err := client.APIContext(context.Background(), "uuid_kill 1"); err == nil {
+		t.Error("expected error")
+	}
+
+	close(replied)
+
+	<-events
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("no disconnect")
+	}
+
+	want := []string{
+		"auth ok",
+		"sent api uuid_kill 1", "reply api uuid_kill 1 error",
+		"event CHANNEL_CREATE", "dropped filtered", "event CHANNEL_ANSWER",
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected calls: %q", calls)
+	}
+
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d: got %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestCommandView(t *testing.T) {
+	if got := newCommand(cmd("auth", "secret")).Line(); got != "auth *****" {
+		t.Errorf("password is not hidden: %q", got)
+	}
+
+	if got := newCommand(cmd("bgapi", "status").WithJobUUID("job-1")); got.JobUUID != "job-1" {
+		t.Errorf("unexpected command: %+v", got)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "ok"
+	}
+
+	return "error"
+}
@@ -0,0 +1,160 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// Invoker sends the command to the server and returns the reply.
+type Invoker func(ctx context.Context) (Response, error)
+
+// Interceptor is the middleware called around every command sent by the
+// client, including auth. It gets the read-only view of the command and calls
+// next to send it, possibly several times, or returns an error without
+// sending it at all.
+//
+//	func timing(ctx context.Context, cmd esl.Command, next esl.Invoker) (esl.Response, error) {
+//		start := time.Now()
+//		resp, err := next(ctx)
+//		log.Println(cmd.Line(), time.Since(start), err)
+//		return resp, err
+//	}
+type Interceptor func(ctx context.Context, cmd Command, next Invoker) (Response, error)
+
+// WithInterceptors returns an Option that adds the interceptors of the
+// commands. The first interceptor is the outermost one: it is called first and
+// its next calls the second one, and so on.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *config) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// interceptors is the chain of the interceptors added to the client.
+type interceptors []Interceptor
+
+// invoke calls the chain of the interceptors ending with the invoker.
+func (is interceptors) invoke(ctx context.Context, cmd Command, invoker Invoker) (Response, error) {
+	if len(is) == 0 {
+		return invoker(ctx)
+	}
+
+	return is[0](ctx, cmd, func(ctx context.Context) (Response, error) {
+		return is[1:].invoke(ctx, cmd, invoker)
+	})
+}
+
+// ErrCommandNotAllowed is returned by AllowInterceptor for the commands not
+// in the allowlist.
+var ErrCommandNotAllowed = errors.New("command not allowed")
+
+// AllowInterceptor returns the interceptor allowing only the listed commands.
+// The command is allowed if its line, e.g. "api show channels", is equal to
+// the listed one or starts with it followed by a space, so "api show" allows
+// all show commands. The auth and exit commands are always allowed.
+func AllowInterceptor(allowed ...string) Interceptor {
+	return func(ctx context.Context, cmd Command, next Invoker) (Response, error) {
+		if cmd.Name == "auth" || cmd.Name == "exit" {
+			return next(ctx)
+		}
+
+		line := cmd.Line()
+		for _, prefix := range allowed {
+			if line == prefix || strings.HasPrefix(line, prefix+" ") {
+				return next(ctx)
+			}
+		}
+
+		return Response{}, fmt.Errorf("%w: %s", ErrCommandNotAllowed, line)
+	}
+}
+
+// AuditInterceptor returns the interceptor logging each command with its
+// result and duration. The parameters of the commands starting with one of
+// the redacted prefixes are hidden, e.g. "api user_data" hides the user data
+// arguments. The password of auth is always hidden.
+func AuditInterceptor(log *slog.Logger, redacted ...string) Interceptor {
+	return func(ctx context.Context, cmd Command, next Invoker) (Response, error) {
+		start := time.Now()
+		resp, err := next(ctx)
+
+		attrs := []slog.Attr{
+			slog.String("cmd", redact(cmd.Line(), redacted)),
+			slog.Duration("duration", time.Since(start)),
+		}
+
+		if cmd.JobUUID != "" {
+			attrs = append(attrs, slog.String("job-uuid", cmd.JobUUID))
+		}
+
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.String("err", err.Error()))
+		}
+
+		log.LogAttrs(ctx, level, "esl: command", attrs...)
+
+		return resp, err
+	}
+}
+
+// redact hides the rest of the line after the matched prefix.
+func redact(line string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix+" ") {
+			return prefix + " *****"
+		}
+	}
+
+	return line
+}
+
+// RetryInterceptor returns the interceptor repeating the failed command up
+// to the given number of attempts, while the error is transient. The delay
+// between attempts is doubled after each one. If retryable is nil,
+// IsTransient is used.
+//
+// The auth command is never repeated. Note that the repeated command is
+// executed by the server again, so only the idempotent commands should be
+// retried.
+func RetryInterceptor(attempts int, delay time.Duration, retryable func(error) bool) Interceptor {
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	return func(ctx context.Context, cmd Command, next Invoker) (Response, error) {
+		resp, err := next(ctx)
+
+		for attempt := 1; attempt < attempts && err != nil && cmd.Name != "auth" && retryable(err); attempt++ {
+			timer := time.NewTimer(delay << (attempt - 1))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return resp, err
+			case <-timer.C:
+			}
+
+			resp, err = next(ctx)
+		}
+
+		return resp, err
+	}
+}
+
+// IsTransient reports whether the command failed because of the temporary
+// condition, so it may succeed if repeated: the network timeout or the
+// "no reply" error of the server.
+func IsTransient(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, ErrNoReply) || errors.Is(err, ErrTimeout) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,105 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterceptors(t *testing.T) {
+	var order []string
+
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, cmd Command, next Invoker) (Response, error) {
+			order = append(order, name+">"+cmd.Name)
+			resp, err := next(ctx)
+			order = append(order, name+"<"+cmd.Name)
+
+			return resp, err
+		}
+	}
+
+	var audit syncBuffer
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		if cmd := srv.recv(); cmd != "api status" {
+			t.Errorf("unexpected command: %q", cmd)
+		}
+
+		srv.send("Content-Type: api/response\nContent-Length: 14\n\n-ERR no reply\n")
+
+		srv.recv()
+		srv.send("Content-Type: api/response\nContent-Length: 2\n\nUP")
+
+		if cmd := srv.recv(); cmd != "api user_data 1000@example.com param password" {
+			t.Errorf("unexpected command: %q", cmd)
+		}
+
+		srv.send("Content-Type: api/response\nContent-Length: 6\n\nsecret")
+	}, WithInterceptors(
+		trace("outer"),
+		trace("inner"),
+		AuditInterceptor(slog.New(slog.NewTextHandler(&audit, nil)), "api user_data"),
+		AllowInterceptor("api status", "api user_data"),
+		RetryInterceptor(3, time.Millisecond, nil),
+	))
+
+	if result, err := client.API("status"); err != nil || result != "UP" {
+		t.Errorf("unexpected result: %q, %v", result, err)
+	}
+
+	if _, err := client.API("user_data 1000@example.com param password"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := client.API("version"); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	want := "outer>auth,inner>auth,inner<auth,outer<auth,outer>api,inner>api,inner<api,outer<api"
+	if got := strings.Join(order[:8], ","); got != want {
+		t.Errorf("unexpected order: %s", got)
+	}
+
+	log := audit.String()
+	for _, want := range []string{
+		`cmd="auth *****"`, `cmd="api status"`, `cmd="api user_data *****"`, `cmd="api version"`,
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("missing %s in audit log:\n%s", want, log)
+		}
+	}
+
+	if strings.Contains(log, "password") {
+		t.Errorf("audit log is not redacted:\n%s", log)
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	var calls int
+
+	retry := RetryInterceptor(3, time.Millisecond, nil)
+	invoker := func(context.Context) (Response, error) {
+		calls++
+
+		return Response{}, newReplyError("-ERR no reply")
+	}
+
+	if _, err := retry(context.Background(), Command{Name: "api", Params: "status"}, invoker); !errors.Is(err, ErrNoReply) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("unexpected number of attempts: %d", calls)
+	}
+
+	calls = 0
+	retry(context.Background(), Command{Name: "auth", Params: "*****"}, invoker) //nolint:errcheck
+
+	if calls != 1 {
+		t.Errorf("auth is repeated: %d", calls)
+	}
+}
@@ -0,0 +1,60 @@
+// Package env loads the environment variables for the commands.
+package env
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Default returns the value of the environment variable, or def if it is not set.
+func Default(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+
+	return def
+}
+
+// Load sets the environment variables from the file with the KEY=value lines.
+// It does nothing if the file does not exist.
+func Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		// split to key and value
+		keyValue := strings.SplitN(scan.Text(), "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		// skip comments
+		if strings.HasPrefix(keyValue[1], "#") {
+			continue
+		}
+
+		// skip end of line comments
+		v, _, _ := strings.Cut(keyValue[1], "#")
+
+		// set environment
+		if err := os.Setenv(keyValue[0], strings.TrimSpace(v)); err != nil {
+			return fmt.Errorf("failed to set env: %w", err)
+		}
+	}
+
+	if err := scan.Err(); err != nil {
+		return fmt.Errorf("failed to parse env file: %w", err)
+	}
+
+	return nil
+}
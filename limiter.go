@@ -0,0 +1,474 @@
+package esl
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority is the class of the command used by Limiter to order the commands
+// waiting to be sent. The values above PriorityBulk are treated as
+// PriorityBulk.
+type Priority uint8
+
+// Priorities of the commands, from the highest one.
+const (
+	PriorityControl Priority = iota // call control: sendmsg, uuid_kill, hupall
+	PriorityNormal                  // all other commands, e.g. originate
+	PriorityBulk                    // bulk queries: show, status, list_users
+
+	priorities = 3 // number of the priority classes
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityControl:
+		return "control"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+// class returns the valid priority, treating the unknown ones as PriorityBulk.
+func (p Priority) class() Priority {
+	return min(p, PriorityBulk)
+}
+
+// bulkCommands are the API commands classified as PriorityBulk.
+//
+//nolint:gochecknoglobals
+var bulkCommands = []string{"show", "status", "list_users", "sofia status", "sofia xmlstatus"}
+
+// ClassifyCommand returns the default priority of the command:
+//
+//   - PriorityControl for sendmsg, sendevent and the uuid_* and hupall API
+//     commands, which control the calls;
+//   - PriorityBulk for the show, status, list_users and sofia status API
+//     commands, which may return a lot of data;
+//   - PriorityNormal for the rest.
+func ClassifyCommand(cmd Command) Priority {
+	switch cmd.Name {
+	case "sendmsg", "sendevent":
+		return PriorityControl
+	case "api", "bgapi":
+	default:
+		return PriorityNormal
+	}
+
+	if strings.HasPrefix(cmd.Params, "uuid_") || strings.HasPrefix(cmd.Params, "hupall") {
+		return PriorityControl
+	}
+
+	for _, prefix := range bulkCommands {
+		if cmd.Params == prefix || strings.HasPrefix(cmd.Params, prefix+" ") {
+			return PriorityBulk
+		}
+	}
+
+	return PriorityNormal
+}
+
+// DefaultJobTimeout is the time after which the slot of the background job is
+// released by Limiter if its BACKGROUND_JOB event is not received.
+const DefaultJobTimeout = time.Minute
+
+// Limiter limits the rate of the commands sent to the server with the token
+// bucket, to avoid starving the ESL thread of FreeSWITCH on bursts like the
+// mass uuid_kill or originate. The commands waiting for the token are sent in
+// the order of their priority, so the call control commands go ahead of the
+// bulk queries.
+//
+// Limiter also caps the number of the background jobs of each priority still
+// in flight: the bgapi command waits until a previous job of the same class
+// is completed. The job is completed when its BACKGROUND_JOB event is
+// received, or after the job timeout. So the client should be subscribed to
+// the BACKGROUND_JOB events and must not filter them out on the server, e.g.
+// with Filter; otherwise each slot is held for the whole job timeout.
+//
+// The limits may be changed at any time. The same Limiter may be shared by
+// several clients to limit their total rate.
+//
+//	limiter := esl.NewLimiter(50, 10)              // 50 commands per second, bursts of 10
+//	limiter.SetJobLimit(esl.PriorityNormal, 20)   // at most 20 originate jobs at once
+//	client, err := esl.Connect(addr, password, esl.WithLimiter(limiter))
+type Limiter struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second; unlimited if zero
+	burst    float64 // bucket size
+	tokens   float64 // available tokens
+	last     time.Time
+	waiters  [priorities][]*limitWaiter
+	timer    *time.Timer // wakes up the waiters when the next token is available
+	classify func(Command) Priority
+
+	jobLimits  [priorities]int // the in-flight jobs caps; unlimited if zero
+	inFlight   [priorities]int
+	jobs       map[string]*limitJob
+	jobTimeout time.Duration
+	awaiting   int                 // bgapi commands sent but not replied yet
+	early      map[string]struct{} // job events received before the reply
+	released   chan struct{}       // closed when a job slot is released or the limits changed
+}
+
+// limitWaiter is the command waiting for the token.
+type limitWaiter struct {
+	ready   chan struct{} // closed when the token is granted
+	granted bool
+}
+
+// limitJob is the background job in flight.
+type limitJob struct {
+	priority Priority
+	timer    *time.Timer
+}
+
+// NewLimiter returns a new Limiter sending up to rate commands per second with
+// bursts up to burst commands. If rate is zero or negative, the rate is not
+// limited and only the job limits are applied.
+func NewLimiter(rate float64, burst int) *Limiter {
+	l := &Limiter{ //nolint:exhaustruct // the rest is zero
+		classify:   ClassifyCommand,
+		jobs:       make(map[string]*limitJob),
+		jobTimeout: DefaultJobTimeout,
+		early:      make(map[string]struct{}),
+		released:   make(chan struct{}),
+	}
+	l.SetRate(rate, burst)
+
+	return l
+}
+
+// SetRate changes the rate limit of the commands. If rate is zero or negative,
+// the rate is not limited. The burst is at least one.
+func (l *Limiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+
+	if l.timer != nil {
+		l.timer.Stop() // rescheduled by dispatch with the new rate
+		l.timer = nil
+	}
+
+	l.rate = math.Max(rate, 0)
+	l.burst = math.Max(float64(burst), 1)
+	l.tokens = math.Min(l.tokens, l.burst)
+
+	if l.last.IsZero() {
+		l.tokens = l.burst // the bucket is full initially
+	}
+
+	l.dispatch()
+}
+
+// Rate returns the current rate limit and burst.
+func (l *Limiter) Rate() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rate, int(l.burst)
+}
+
+// SetJobLimit changes the maximum number of the background jobs of the given
+// priority in flight. If n is zero or negative, the number is not limited.
+func (l *Limiter) SetJobLimit(p Priority, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.jobLimits[p.class()] = max(n, 0)
+	l.notifyReleased()
+}
+
+// SetJobTimeout changes the time after which the slot of the background job
+// is released if its BACKGROUND_JOB event is not received. It is applied to
+// the jobs sent after the change.
+func (l *Limiter) SetJobTimeout(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.jobTimeout = d
+}
+
+// SetClassifier replaces the function returning the priority of the
+// command, ClassifyCommand by default.
+func (l *Limiter) SetClassifier(classify func(Command) Priority) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.classify = classify
+}
+
+// InFlight returns the number of the background jobs of the given priority
+// in flight.
+func (l *Limiter) InFlight(p Priority) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight[p.class()]
+}
+
+// Wait blocks until the command of the given priority may be sent, or the
+// context is done.
+func (l *Limiter) Wait(ctx context.Context, p Priority) error {
+	p = p.class()
+
+	l.mu.Lock()
+	l.refill(time.Now())
+
+	if l.rate == 0 || (l.tokens >= 1 && !l.waiting(p)) {
+		if l.rate != 0 {
+			l.tokens--
+		}
+
+		l.mu.Unlock()
+
+		return nil
+	}
+
+	w := &limitWaiter{ready: make(chan struct{}), granted: false}
+	l.waiters[p] = append(l.waiters[p], w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w.granted {
+		l.tokens = math.Min(l.tokens+1, l.burst) // return the unused token
+		l.dispatch()
+	} else {
+		l.remove(p, w)
+	}
+
+	return ctx.Err() //nolint:wrapcheck
+}
+
+// Interceptor returns the interceptor limiting the commands, see
+// WithLimiter. The auth and exit commands are not limited.
+func (l *Limiter) Interceptor() Interceptor {
+	return func(ctx context.Context, cmd Command, next Invoker) (Response, error) {
+		if cmd.Name == "auth" || cmd.Name == "exit" {
+			return next(ctx)
+		}
+
+		l.mu.Lock()
+		p := l.classify(cmd).class()
+		l.mu.Unlock()
+
+		if cmd.Name == "bgapi" {
+			if err := l.acquireJob(ctx, p); err != nil {
+				return Response{}, err
+			}
+		}
+
+		if err := l.Wait(ctx, p); err != nil {
+			if cmd.Name == "bgapi" {
+				l.releaseJob(p)
+			}
+
+			return Response{}, err
+		}
+
+		if cmd.Name != "bgapi" {
+			return next(ctx)
+		}
+
+		l.mu.Lock()
+		l.awaiting++
+		l.mu.Unlock()
+
+		resp, err := next(ctx)
+
+		id := cmd.JobUUID
+		if id == "" {
+			id = resp.JobUUID()
+		}
+
+		l.startJob(id, p, err)
+
+		return resp, err
+	}
+}
+
+// Observe completes the background job of the BACKGROUND_JOB event,
+// releasing its slot. It is called by the client for all received events
+// when the limiter is set with WithLimiter.
+func (l *Limiter) Observe(e Event) {
+	if e.Name() != "BACKGROUND_JOB" {
+		return
+	}
+
+	id := e.Get("Job-UUID")
+	if id == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if job, ok := l.jobs[id]; ok {
+		l.finishJob(id, job)
+	} else if l.awaiting > 0 {
+		l.early[id] = struct{}{} // the event is ahead of the bgapi reply
+	}
+}
+
+// acquireJob waits for the free slot of the background job.
+func (l *Limiter) acquireJob(ctx context.Context, p Priority) error {
+	for {
+		l.mu.Lock()
+
+		if l.jobLimits[p] == 0 || l.inFlight[p] < l.jobLimits[p] {
+			l.inFlight[p]++
+			l.mu.Unlock()
+
+			return nil
+		}
+
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		}
+	}
+}
+
+// releaseJob releases the slot of the background job.
+func (l *Limiter) releaseJob(p Priority) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight[p]--
+	l.notifyReleased()
+}
+
+// startJob starts tracking the background job after the bgapi reply.
+func (l *Limiter) startJob(id string, p Priority, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.awaiting--
+
+	_, done := l.early[id]
+	if l.awaiting == 0 {
+		clear(l.early)
+	} else {
+		delete(l.early, id)
+	}
+
+	if err != nil || id == "" || done {
+		l.inFlight[p]--
+		l.notifyReleased()
+
+		return
+	}
+
+	job := &limitJob{priority: p, timer: nil}
+	job.timer = time.AfterFunc(l.jobTimeout, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.jobs[id] == job {
+			l.finishJob(id, job)
+		}
+	})
+	l.jobs[id] = job
+}
+
+// finishJob releases the slot of the completed job.
+func (l *Limiter) finishJob(id string, job *limitJob) {
+	job.timer.Stop()
+	delete(l.jobs, id)
+	l.inFlight[job.priority]--
+	l.notifyReleased()
+}
+
+// notifyReleased wakes up the commands waiting for the job slot.
+func (l *Limiter) notifyReleased() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// refill adds the tokens accumulated since the last refill.
+func (l *Limiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	}
+
+	l.last = now
+}
+
+// waiting reports whether there are commands of the same or higher priority
+// waiting for the token.
+func (l *Limiter) waiting(p Priority) bool {
+	for i := range p + 1 {
+		if len(l.waiters[i]) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dispatch grants the available tokens to the waiting commands in the order
+// of their priority, and schedules the next call when the next token is
+// available.
+func (l *Limiter) dispatch() {
+	l.refill(time.Now())
+
+	for p := range l.waiters {
+		for len(l.waiters[p]) > 0 && (l.rate == 0 || l.tokens >= 1) {
+			w := l.waiters[p][0]
+			l.waiters[p][0] = nil
+			l.waiters[p] = l.waiters[p][1:]
+
+			if l.rate != 0 {
+				l.tokens--
+			}
+
+			w.granted = true
+			close(w.ready)
+		}
+	}
+
+	if l.timer != nil || !l.waiting(priorities-1) {
+		return
+	}
+
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.timer = time.AfterFunc(delay, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+// remove removes the waiter from the queue.
+func (l *Limiter) remove(p Priority, w *limitWaiter) {
+	for i, v := range l.waiters[p] {
+		if v == w {
+			l.waiters[p] = append(l.waiters[p][:i], l.waiters[p][i+1:]...)
+
+			return
+		}
+	}
+}
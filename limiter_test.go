@@ -0,0 +1,167 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyCommand(t *testing.T) {
+	for _, tc := range []struct {
+		cmd  Command
+		want Priority
+	}{
+		{Command{Name: "sendmsg", Params: "call-1", JobUUID: ""}, PriorityControl},
+		{Command{Name: "api", Params: "uuid_kill call-1", JobUUID: ""}, PriorityControl},
+		{Command{Name: "bgapi", Params: "hupall NORMAL_CLEARING", JobUUID: ""}, PriorityControl},
+		{Command{Name: "api", Params: "show channels", JobUUID: ""}, PriorityBulk},
+		{Command{Name: "api", Params: "sofia status profile internal", JobUUID: ""}, PriorityBulk},
+		{Command{Name: "api", Params: "showcase", JobUUID: ""}, PriorityNormal},
+		{Command{Name: "bgapi", Params: "originate user/1000 &park", JobUUID: ""}, PriorityNormal},
+		{Command{Name: "event", Params: "plain ALL", JobUUID: ""}, PriorityNormal},
+	} {
+		if got := ClassifyCommand(tc.cmd); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.cmd.Line(), got, tc.want)
+		}
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	limiter := NewLimiter(20, 1)
+
+	if err := limiter.Wait(context.Background(), PriorityBulk); err != nil {
+		t.Fatal(err) // takes the only token
+	}
+
+	order := make(chan Priority, 2)
+	wait := func(p Priority) {
+		if err := limiter.Wait(context.Background(), p); err != nil {
+			t.Error(err)
+		}
+
+		order <- p
+	}
+
+	go wait(PriorityBulk)
+	waitFor(t, func() bool { return limiter.queued(PriorityBulk) == 1 })
+	go wait(PriorityControl)
+	waitFor(t, func() bool { return limiter.queued(PriorityControl) == 1 })
+
+	if p := <-order; p != PriorityControl {
+		t.Errorf("unexpected first priority: %v", p)
+	}
+
+	if p := <-order; p != PriorityBulk {
+		t.Errorf("unexpected second priority: %v", p)
+	}
+
+	// canceled waiter does not hold the queue
+	limiter.SetRate(0.001, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Wait(ctx, PriorityNormal); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if n := limiter.queued(PriorityNormal); n != 0 {
+		t.Errorf("canceled waiter is queued: %d", n)
+	}
+
+	// the rate is changed at runtime
+	limiter.SetRate(0, 1)
+
+	if err := limiter.Wait(context.Background(), PriorityNormal); err != nil {
+		t.Errorf("unlimited rate: %v", err)
+	}
+}
+
+func TestLimiterJobs(t *testing.T) {
+	limiter := NewLimiter(0, 1)
+	limiter.SetJobLimit(PriorityNormal, 1)
+
+	jobDone := make(chan struct{})
+	client := newFakeClient(t, func(srv *fakeServer) {
+		if cmd := srv.recv(); cmd != "bgapi originate user/1000 &park" {
+			t.Errorf("unexpected command: %q", cmd)
+		}
+
+		srv.send("Content-Type: command/reply\nReply-Text: +OK Job-UUID: job-1\nJob-UUID: job-1\n\n")
+
+		<-jobDone
+		srv.sendEvent("Event-Name: BACKGROUND_JOB", "Job-UUID: job-1")
+
+		srv.recv()
+		srv.send("Content-Type: command/reply\nReply-Text: +OK Job-UUID: job-2\nJob-UUID: job-2\n\n")
+		srv.recv() // exit
+		srv.conn.Close()
+	}, WithLimiter(limiter))
+
+	if id, err := client.Job("originate user/1000 &park"); err != nil || id != "job-1" {
+		t.Fatalf("unexpected job: %q, %v", id, err)
+	}
+
+	if n := limiter.InFlight(PriorityNormal); n != 1 {
+		t.Errorf("unexpected jobs in flight: %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.JobContext(ctx, "originate user/1001 &park"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("job limit is not applied: %v", err)
+	}
+
+	close(jobDone)
+	waitFor(t, func() bool { return limiter.InFlight(PriorityNormal) == 0 })
+
+	if _, err := client.Job("originate user/1001 &park"); err != nil {
+		t.Error(err)
+	}
+
+	client.Close()
+}
+
+func TestLimiterUnknownPriority(t *testing.T) {
+	limiter := NewLimiter(0, 1)
+	limiter.SetJobLimit(Priority(7), 1)
+	limiter.SetClassifier(func(Command) Priority { return Priority(5) })
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		srv.recv()
+		srv.send("Content-Type: command/reply\nReply-Text: +OK Job-UUID: job-1\nJob-UUID: job-1\n\n")
+	}, WithLimiter(limiter))
+
+	if _, err := client.Job("status"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := limiter.InFlight(Priority(9)); n != 1 || limiter.InFlight(PriorityBulk) != 1 {
+		t.Errorf("unexpected bulk jobs in flight: %d", n)
+	}
+
+	if err := limiter.Wait(context.Background(), Priority(3)); err != nil {
+		t.Error(err)
+	}
+}
+
+// queued returns the number of the commands of the priority waiting for the token.
+func (l *Limiter) queued(p Priority) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.waiters[p])
+}
+
+// waitFor waits until the condition is true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogLevel is the level of the FreeSWITCH console log.
+type LogLevel int
+
+// Supported log levels, from the most important to the most verbose.
+const (
+	LogConsole LogLevel = iota
+	LogAlert
+	LogCrit
+	LogErr
+	LogWarning
+	LogNotice
+	LogInfo
+	LogDebug
+)
+
+// logLevelNames are the names of the log levels used by FreeSWITCH.
+var logLevelNames = [...]string{ //nolint:gochecknoglobals
+	"CONSOLE", "ALERT", "CRIT", "ERR", "WARNING", "NOTICE", "INFO", "DEBUG",
+}
+
+// String returns the name of the level as used by FreeSWITCH, e.g. "DEBUG".
+func (l LogLevel) String() string {
+	if l < LogConsole || l > LogDebug {
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+
+	return logLevelNames[l]
+}
+
+// Level returns the slog level corresponding to the FreeSWITCH log level.
+func (l LogLevel) Level() slog.Level {
+	switch l {
+	case LogDebug:
+		return slog.LevelDebug
+	case LogNotice:
+		return slog.LevelInfo + 2 //nolint:mnd // between info and warning
+	case LogWarning:
+		return slog.LevelWarn
+	case LogErr:
+		return slog.LevelError
+	case LogCrit, LogAlert:
+		return slog.LevelError + 4 //nolint:mnd // above error
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ParseLogLevel returns the log level by its name, such as "debug" or "err",
+// or by its number. The name is case-insensitive; "error" and "warn" are
+// accepted too.
+func ParseLogLevel(s string) (LogLevel, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	switch s {
+	case "ERROR":
+		return LogErr, nil
+	case "WARN":
+		return LogWarning, nil
+	}
+
+	for i, name := range logLevelNames {
+		if s == name || s == strconv.Itoa(i) {
+			return LogLevel(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level: %q", s) //nolint:err113
+}
+
+// LogLine is the line of the FreeSWITCH console log, received after the
+// log command, see Client.Log.
+type LogLine struct {
+	Level    LogLevel // the log level
+	File     string   // the source file, e.g. "switch_core_state_machine.c"
+	Line     int      // the line number in the source file
+	Function string   // the function name, e.g. "switch_core_session_run"
+	UUID     string   // the channel UUID, if the line relates to the channel
+	Text     string   // the text of the line
+}
+
+// newLogLine returns the log line from the log/data frame.
+func newLogLine(resp Response) LogLine {
+	level, err := strconv.Atoi(resp.Get("Log-Level"))
+	if err != nil {
+		level = int(LogConsole)
+	}
+
+	line, _ := strconv.Atoi(resp.Get("Log-Line"))
+
+	return LogLine{
+		Level:    LogLevel(level),
+		File:     resp.Get("Log-File"),
+		Line:     line,
+		Function: resp.Get("Log-Func"),
+		UUID:     resp.Get("User-Data"),
+		Text:     strings.TrimRight(resp.Body(), "\r\n"),
+	}
+}
+
+// String returns the text of the line.
+func (l LogLine) String() string {
+	return l.Text
+}
+
+// Record returns the log line as the slog record with the given time. The
+// source of the line and the channel UUID are added as attributes.
+func (l LogLine) Record(t time.Time) slog.Record {
+	r := slog.NewRecord(t, l.Level.Level(), l.Text, 0)
+	r.AddAttrs(slog.String("fs_level", l.Level.String()))
+
+	if l.File != "" {
+		r.AddAttrs(slog.String("file", l.File), slog.Int("line", l.Line))
+	}
+
+	if l.Function != "" {
+		r.AddAttrs(slog.String("func", l.Function))
+	}
+
+	if l.UUID != "" {
+		r.AddAttrs(slog.String("uuid", l.UUID))
+	}
+
+	return r
+}
+
+// PipeLogs writes the log lines from the channel to the slog handler, until
+// the channel is closed or the context is canceled. It is used to get the
+// FreeSWITCH log in the application log:
+//
+//	logs := make(chan esl.LogLine, 100)
+//	client, err := esl.Connect(addr, password, esl.WithLogs(logs, true))
+//	...
+//	err = client.Log(esl.LogInfo)
+//	...
+//	handler := slog.Default().Handler().WithGroup("freeswitch")
+//	err = esl.PipeLogs(ctx, logs, handler)
+//
+// It returns nil when the channel is closed, the context error or the error
+// returned by the handler.
+func PipeLogs(ctx context.Context, logs <-chan LogLine, h slog.Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case line, ok := <-logs:
+			if !ok {
+				return nil
+			}
+
+			record := line.Record(time.Now())
+			if !h.Enabled(ctx, record.Level) {
+				continue
+			}
+
+			if err := h.Handle(ctx, record); err != nil {
+				return fmt.Errorf("failed to handle log: %w", err)
+			}
+		}
+	}
+}
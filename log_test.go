@@ -0,0 +1,117 @@
+package esl
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientLog(t *testing.T) {
+	commands := make(chan string, 2)
+	logs := make(chan LogLine, 1)
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		commands <- srv.recv()
+		srv.reply("+OK log level 7 [7]")
+
+		const body = "2024-05-01 10:00:00.000 [DEBUG] switch_core_session.c:1234 Session 1 created\n"
+		srv.send("Content-Type: log/data\nContent-Length: " + strconv.Itoa(len(body)) +
+			"\nLog-Level: 7\nText-Channel: 3\nLog-File: switch_core_session.c\n" +
+			"Log-Func: switch_core_session_request\nLog-Line: 1234\nUser-Data: call-1\n\n" + body)
+
+		commands <- srv.recv()
+		srv.reply("+OK no longer logging")
+
+		srv.recv() // exit
+		srv.conn.Close()
+	}, WithLogs(logs, true))
+
+	if err := client.Log(LogDebug); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := <-commands; cmd != "log 7" {
+		t.Errorf("unexpected command: %q", cmd)
+	}
+
+	select {
+	case line := <-logs:
+		want := LogLine{
+			Level:    LogDebug,
+			File:     "switch_core_session.c",
+			Line:     1234,
+			Function: "switch_core_session_request",
+			UUID:     "call-1",
+			Text:     "2024-05-01 10:00:00.000 [DEBUG] switch_core_session.c:1234 Session 1 created",
+		}
+		if line != want {
+			t.Errorf("unexpected log line: %+v", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no log line")
+	}
+
+	if err := client.NoLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := <-commands; cmd != "nolog" {
+		t.Errorf("unexpected command: %q", cmd)
+	}
+
+	client.Close()
+
+	if _, ok := <-logs; ok {
+		t.Error("logs channel is not closed")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for s, want := range map[string]LogLevel{
+		"debug": LogDebug, "ERR": LogErr, "error": LogErr, "warn": LogWarning,
+		"0": LogConsole, "5": LogNotice,
+	} {
+		if got, err := ParseLogLevel(s); err != nil || got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v", s, got, err)
+		}
+	}
+
+	for _, s := range []string{"", "8", "verbose"} {
+		if _, err := ParseLogLevel(s); err == nil {
+			t.Errorf("ParseLogLevel(%q) is accepted", s)
+		}
+	}
+}
+
+func TestPipeLogs(t *testing.T) {
+	var buf bytes.Buffer
+
+	logs := make(chan LogLine, 2)
+	logs <- LogLine{Level: LogDebug, Text: "hidden"}
+	logs <- LogLine{Level: LogWarning, File: "mod_sofia.c", Line: 42, UUID: "call-1", Text: "no route"}
+	close(logs)
+
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	})
+
+	if err := PipeLogs(context.Background(), logs, h); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `level=WARN msg="no route" fs_level=WARNING file=mod_sofia.c line=42 uuid=call-1`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
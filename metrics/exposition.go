@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// expWriter writes the metrics in the text exposition format. The first write
+// error is kept and all further writes are skipped.
+type expWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// newExpWriter returns the writer of the metrics to w.
+func newExpWriter(w io.Writer) *expWriter {
+	return &expWriter{w: bufio.NewWriter(w), n: 0, err: nil}
+}
+
+// write writes the strings.
+func (w *expWriter) write(s ...string) {
+	for _, v := range s {
+		if w.err != nil {
+			return
+		}
+
+		n, err := w.w.WriteString(v)
+		w.n += int64(n)
+		w.err = err
+	}
+}
+
+// header writes the help and type of the metric.
+func (w *expWriter) header(name, typ, help string) {
+	w.write("# HELP ", name, " ", escapeHelp(help), "\n", "# TYPE ", name, " ", typ, "\n")
+}
+
+// sample writes the value of the metric with the labels, given as the
+// name-value pairs.
+func (w *expWriter) sample(name string, value float64, labels ...string) {
+	w.write(name)
+
+	if len(labels) > 1 {
+		w.write("{")
+
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.write(",")
+			}
+
+			w.write(labels[i], `="`, escapeLabel(labels[i+1]), `"`)
+		}
+
+		w.write("}")
+	}
+
+	w.write(" ", formatFloat(value), "\n")
+}
+
+// single writes the metric with one value without labels.
+func (w *expWriter) single(name, typ, help string, value float64) {
+	w.header(name, typ, help)
+	w.sample(name, value)
+}
+
+// labeled writes the metric with the values by the label value, sorted by it.
+func (w *expWriter) labeled(name, typ, help, label string, values map[string]uint64) {
+	if len(values) == 0 {
+		return
+	}
+
+	w.header(name, typ, help)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		w.sample(name, float64(values[k]), label, k)
+	}
+}
+
+// histogram writes the histogram with the cumulative buckets.
+func (w *expWriter) histogram(name, help string, h *histogram) {
+	w.header(name, "histogram", help)
+
+	var cumulative uint64
+
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		w.sample(name+"_bucket", float64(cumulative), "le", formatFloat(bound))
+	}
+
+	w.sample(name+"_bucket", float64(h.count), "le", "+Inf")
+	w.sample(name+"_sum", h.sum)
+	w.sample(name+"_count", float64(h.count))
+}
+
+// flush writes the buffered data and returns the number of bytes written and
+// the first error.
+func (w *expWriter) flush() (int64, error) {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+
+	return w.n, w.err
+}
+
+// formatFloat formats the value as used in the exposition format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)            //nolint:gochecknoglobals
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`) //nolint:gochecknoglobals
+)
+
+// escapeHelp escapes the help text.
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// escapeLabel escapes the label value.
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// histogram counts the observed values in the buckets.
+type histogram struct {
+	bounds []float64 // upper bounds of the buckets, sorted
+	counts []uint64  // number of values in each bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// newHistogram returns the histogram with the bucket bounds.
+func newHistogram(bounds ...float64) *histogram {
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+		sum:    0,
+		count:  0,
+	}
+}
+
+// observe adds the value to the histogram.
+func (h *histogram) observe(v float64) {
+	if i, _ := slices.BinarySearch(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+
+	h.sum += v
+	h.count++
+}
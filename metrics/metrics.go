@@ -0,0 +1,256 @@
+// Package metrics turns the FreeSWITCH events into metrics in the Prometheus
+// text exposition format.
+//
+// The Collector counts the calls, hangups by cause and registrations, measures
+// the call durations and keeps the system state reported by the HEARTBEAT
+// events. It is the event sink, so the events of the client can be piped to it:
+//
+//	events := make(chan esl.Event, 100)
+//	client, err := esl.Connect(addr, password, esl.WithEvents(events, true))
+//	...
+//	collector := metrics.New(metrics.WithClient(client))
+//	err = client.Subscribe(collector.Events()...)
+//	...
+//	go esl.Pipe(ctx, events, collector)
+//	http.Handle("/metrics", collector)
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdigger/esl"
+)
+
+// DefaultDurationBuckets are the upper bounds in seconds of the call duration
+// histogram buckets.
+var DefaultDurationBuckets = []float64{ //nolint:gochecknoglobals
+	1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600,
+}
+
+// StatsSource is the source of the client stats, such as *esl.Client.
+type StatsSource interface {
+	Stats() esl.ClientStats
+}
+
+// Option is the option of the Collector.
+type Option func(*Collector)
+
+// WithClient returns an Option that adds the stats of the client to the
+// metrics, such as the number of commands and their latency.
+func WithClient(client StatsSource) Option {
+	return func(c *Collector) {
+		c.client = client
+	}
+}
+
+// WithDurationBuckets returns an Option that sets the upper bounds in seconds
+// of the call duration histogram buckets.
+func WithDurationBuckets(bounds ...float64) Option {
+	return func(c *Collector) {
+		c.duration = newHistogram(bounds...)
+	}
+}
+
+// heartbeat contains the system state from the last HEARTBEAT event.
+type heartbeat struct {
+	received       bool
+	sessions       float64
+	sessionsPeak   float64
+	sessionsTotal  float64
+	sessionsPerSec float64
+	maxSessions    float64
+	idleCPU        float64
+	uptime         float64
+}
+
+// Collector collects the metrics from the events.
+type Collector struct {
+	mu            sync.Mutex
+	client        StatsSource
+	events        map[string]uint64 // by event name
+	created       map[string]uint64 // by call direction
+	answered      map[string]uint64 // by call direction
+	hangups       map[string]uint64 // by hangup cause
+	registrations map[string]uint64 // by registration event
+	duration      *histogram
+	heartbeat     heartbeat
+}
+
+// New returns the new Collector.
+func New(opts ...Option) *Collector {
+	c := &Collector{
+		mu:            sync.Mutex{},
+		client:        nil,
+		events:        make(map[string]uint64),
+		created:       make(map[string]uint64),
+		answered:      make(map[string]uint64),
+		hangups:       make(map[string]uint64),
+		registrations: make(map[string]uint64),
+		duration:      newHistogram(DefaultDurationBuckets...),
+		heartbeat:     heartbeat{}, //nolint:exhaustruct
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Events returns the names of the events used by the collector, to
+// subscribe to them.
+func (c *Collector) Events() []string {
+	return []string{
+		"CHANNEL_CREATE", "CHANNEL_ANSWER", "CHANNEL_HANGUP_COMPLETE", "HEARTBEAT",
+		"sofia::register", "sofia::unregister", "sofia::expire",
+	}
+}
+
+// Observe updates the metrics with the event.
+func (c *Collector) Observe(e esl.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := e.Name()
+	c.events[name]++
+
+	switch name {
+	case "CHANNEL_CREATE":
+		c.created[direction(e)]++
+	case "CHANNEL_ANSWER":
+		c.answered[direction(e)]++
+	case "CHANNEL_HANGUP_COMPLETE":
+		c.hangups[e.Get("Hangup-Cause")]++
+
+		if d, ok := billDuration(e); ok {
+			c.duration.observe(d.Seconds())
+		}
+	case "HEARTBEAT":
+		c.heartbeat = heartbeat{
+			received:       true,
+			sessions:       number(e, "Session-Count"),
+			sessionsPeak:   number(e, "Session-Peak-Max"),
+			sessionsTotal:  number(e, "Session-Since-Startup"),
+			sessionsPerSec: number(e, "Session-Per-Sec"),
+			maxSessions:    number(e, "Max-Sessions"),
+			idleCPU:        number(e, "Idle-CPU"),
+			uptime:         number(e, "Uptime-msec") / 1000, //nolint:mnd // ms
+		}
+	default:
+		if event, ok := strings.CutPrefix(name, "sofia::"); ok {
+			c.registrations[event]++
+		}
+	}
+}
+
+// WriteEvent observes the event. It implements esl.EventSink.
+func (c *Collector) WriteEvent(_ context.Context, e esl.Event) error {
+	c.Observe(e)
+
+	return nil
+}
+
+// Close implements esl.EventSink. It does nothing.
+func (c *Collector) Close() error {
+	return nil
+}
+
+// WriteTo writes the metrics in the text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	exp := newExpWriter(w)
+
+	c.mu.Lock()
+	exp.labeled("freeswitch_events_total", "counter",
+		"Number of received events.", "name", c.events)
+	exp.labeled("freeswitch_calls_created_total", "counter",
+		"Number of created channels.", "direction", c.created)
+	exp.labeled("freeswitch_calls_answered_total", "counter",
+		"Number of answered channels.", "direction", c.answered)
+	exp.labeled("freeswitch_hangups_total", "counter",
+		"Number of hung up channels by the hangup cause.", "cause", c.hangups)
+	exp.labeled("freeswitch_registrations_total", "counter",
+		"Number of SIP registration events.", "event", c.registrations)
+	exp.histogram("freeswitch_call_duration_seconds",
+		"Duration of the answered calls.", c.duration)
+
+	if hb := c.heartbeat; hb.received {
+		exp.single("freeswitch_sessions", "gauge", "Number of active sessions.", hb.sessions)
+		exp.single("freeswitch_sessions_peak", "gauge", "Peak number of sessions.", hb.sessionsPeak)
+		exp.single("freeswitch_sessions_since_startup", "counter",
+			"Number of sessions since the startup.", hb.sessionsTotal)
+		exp.single("freeswitch_sessions_per_second", "gauge", "Number of new sessions per second.",
+			hb.sessionsPerSec)
+		exp.single("freeswitch_max_sessions", "gauge", "Maximum number of sessions.", hb.maxSessions)
+		exp.single("freeswitch_idle_cpu_percent", "gauge", "Idle CPU percentage.", hb.idleCPU)
+		exp.single("freeswitch_uptime_seconds", "gauge", "Uptime of the server.", hb.uptime)
+	}
+	c.mu.Unlock()
+
+	if c.client != nil {
+		writeClientStats(exp, c.client.Stats())
+	}
+
+	return exp.flush()
+}
+
+// ServeHTTP writes the metrics as the HTTP response.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	c.WriteTo(w) //nolint:errcheck
+}
+
+// writeClientStats writes the stats of the client.
+func writeClientStats(exp *expWriter, s esl.ClientStats) {
+	exp.single("esl_client_commands_total", "counter",
+		"Number of commands sent to the server.", float64(s.Commands))
+	exp.single("esl_client_command_errors_total", "counter",
+		"Number of failed commands.", float64(s.CommandErrors))
+	exp.header("esl_client_command_latency_seconds", "summary", "Latency of the command replies.")
+	exp.sample("esl_client_command_latency_seconds_sum", s.CommandLatency.Seconds())
+	exp.sample("esl_client_command_latency_seconds_count", float64(s.Commands))
+	exp.single("esl_client_events_total", "counter",
+		"Number of events received by the client.", float64(s.Events))
+	exp.single("esl_client_events_filtered_total", "counter",
+		"Number of events dropped by the client-side filter.", float64(s.EventsFiltered))
+	exp.single("esl_client_events_invalid_total", "counter",
+		"Number of events dropped because of the parsing errors.", float64(s.EventsInvalid))
+	exp.single("esl_client_event_lag_seconds", "gauge",
+		"Delay of the last event since it was fired by the server.", s.EventLag.Seconds())
+	exp.single("esl_client_log_lines_total", "counter",
+		"Number of log lines received by the client.", float64(s.LogLines))
+}
+
+// direction returns the call direction of the channel.
+func direction(e esl.Event) string {
+	if d := e.Get("Call-Direction"); d != "" {
+		return d
+	}
+
+	return "unknown"
+}
+
+// billDuration returns the duration of the answered call.
+func billDuration(e esl.Event) (time.Duration, bool) {
+	if ms, err := strconv.ParseInt(e.Variable("billmsec"), 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+
+	if s, err := strconv.ParseInt(e.Variable("billsec"), 10, 64); err == nil {
+		return time.Duration(s) * time.Second, s > 0
+	}
+
+	return 0, false
+}
+
+// number returns the numeric value of the header, zero if it is missing.
+func number(e esl.Event, key string) float64 {
+	v, _ := strconv.ParseFloat(e.Get(key), 64)
+
+	return v
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mdigger/esl"
+)
+
+// fakeServer authenticates the client, confirms the subscription and sends
+// the events in the plain format.
+func fakeServer(t *testing.T, conn net.Conn, events ...string) {
+	t.Helper()
+
+	r := bufio.NewReader(conn)
+
+	recv := func() {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || line == "\n" {
+				return
+			}
+		}
+	}
+
+	send := func(msg string) {
+		if _, err := io.WriteString(conn, msg); err != nil {
+			t.Error("fake server write:", err)
+		}
+	}
+
+	send("Content-Type: auth/request\n\n")
+	recv()
+	send("Content-Type: command/reply\nReply-Text: +OK accepted\n\n")
+	recv()
+	send("Content-Type: command/reply\nReply-Text: +OK event listener enabled plain\n\n")
+
+	for _, e := range events {
+		body := e + "\n\n"
+		send("Content-Type: text/event-plain\nContent-Length: " + strconv.Itoa(len(body)) + "\n\n" + body)
+	}
+
+	conn.Close()
+}
+
+func TestCollector(t *testing.T) {
+	client, server := net.Pipe()
+
+	go fakeServer(t, server,
+		"Event-Name: CHANNEL_CREATE\nCall-Direction: inbound",
+		"Event-Name: CHANNEL_CREATE\nCall-Direction: outbound",
+		"Event-Name: CHANNEL_ANSWER\nCall-Direction: inbound",
+		"Event-Name: CHANNEL_HANGUP_COMPLETE\nHangup-Cause: NORMAL_CLEARING\nvariable_billmsec: 42500",
+		"Event-Name: CHANNEL_HANGUP_COMPLETE\nHangup-Cause: NO_ANSWER\nvariable_billmsec: 0",
+		"Event-Name: CUSTOM\nEvent-Subclass: sofia%3A%3Aregister",
+		"Event-Name: HEARTBEAT\nSession-Count: 3\nIdle-CPU: 97.5\nSession-Per-Sec: 2\nUptime-msec: 60000",
+	)
+
+	events := make(chan esl.Event, 10)
+
+	c, err := esl.NewClient(client, "ClueCon", esl.WithEvents(events, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	collector := New(WithClient(c))
+
+	if err := c.Subscribe(collector.Events()...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := esl.Pipe(context.Background(), events, collector); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("unexpected content type: %q", ct)
+	}
+
+	out := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE freeswitch_calls_created_total counter\n",
+		`freeswitch_calls_created_total{direction="inbound"} 1` + "\n",
+		`freeswitch_calls_created_total{direction="outbound"} 1` + "\n",
+		`freeswitch_calls_answered_total{direction="inbound"} 1` + "\n",
+		`freeswitch_hangups_total{cause="NORMAL_CLEARING"} 1` + "\n",
+		`freeswitch_hangups_total{cause="NO_ANSWER"} 1` + "\n",
+		`freeswitch_registrations_total{event="register"} 1` + "\n",
+		`freeswitch_call_duration_seconds_bucket{le="30"} 0` + "\n",
+		`freeswitch_call_duration_seconds_bucket{le="60"} 1` + "\n",
+		`freeswitch_call_duration_seconds_bucket{le="+Inf"} 1` + "\n",
+		"freeswitch_call_duration_seconds_sum 42.5\n",
+		"freeswitch_call_duration_seconds_count 1\n",
+		"freeswitch_sessions 3\n",
+		"freeswitch_idle_cpu_percent 97.5\n",
+		"freeswitch_uptime_seconds 60\n",
+		"esl_client_commands_total 1\n",
+		"esl_client_events_total 7\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaped label: %s", got)
+	}
+}
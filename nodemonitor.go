@@ -0,0 +1,287 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeAlertKind is a kind of the alert reported by NodeMonitor.
+type NodeAlertKind uint8
+
+// Kinds of the node alerts.
+const (
+	NodeOverloaded       NodeAlertKind = iota + 1 // the load exceeds the thresholds
+	NodeRecovered                                 // the load is back under the thresholds
+	NodeShutdown                                  // the shutdown of the node is requested
+	NodeHeartbeatLost                             // the heartbeats stopped
+	NodeHeartbeatResumed                          // the heartbeats are received again
+)
+
+// String returns the name of the alert kind.
+func (k NodeAlertKind) String() string {
+	switch k {
+	case NodeOverloaded:
+		return "overloaded"
+	case NodeRecovered:
+		return "recovered"
+	case NodeShutdown:
+		return "shutdown"
+	case NodeHeartbeatLost:
+		return "heartbeat lost"
+	case NodeHeartbeatResumed:
+		return "heartbeat resumed"
+	default:
+		return "unknown"
+	}
+}
+
+// NodeAlert describes the alert reported by NodeMonitor.
+type NodeAlert struct {
+	Kind   NodeAlertKind
+	Status NodeStatus // the last known status of the node
+	Reason string     // the exceeded thresholds of NodeOverloaded
+}
+
+// NodeThresholds are the thresholds of the node load of NodeMonitor. The
+// zero threshold is not checked.
+type NodeThresholds struct {
+	SessionLoad float64 // max ratio of the sessions to Max-Sessions, e.g. 0.9
+	RateLoad    float64 // max ratio of the sessions per second to SessionsPerSecLimit
+	MinIdleCPU  float64 // min percent of the idle CPU
+
+	// SessionsPerSecLimit is the limit of the sessions per second configured
+	// on the nodes, used for RateLoad: it is not reported by the heartbeats.
+	SessionsPerSecLimit int
+
+	// MissedHeartbeats is the number of the heartbeat intervals without
+	// heartbeats after which NodeHeartbeatLost is reported, 3 by default.
+	MissedHeartbeats int
+}
+
+// defaultHeartbeatInterval is used if the HEARTBEAT has no interval.
+const defaultHeartbeatInterval = 20 * time.Second
+
+// NodeMonitor keeps the latest status of the FreeSWITCH nodes per Core-UUID,
+// from the observed HEARTBEAT events, and reports the overload, the requested
+// shutdown and the stopped heartbeats. The client should be subscribed to the
+// HEARTBEAT and SHUTDOWN_REQUESTED events.
+//
+// The stopped heartbeats are detected by Check, which should be called
+// periodically, e.g. with Run.
+type NodeMonitor struct {
+	mu         sync.Mutex
+	nodes      map[string]*nodeState
+	thresholds NodeThresholds
+	handler    func(NodeAlert)
+}
+
+// nodeState is the state of the monitored node.
+type nodeState struct {
+	status     NodeStatus
+	seen       time.Time // when the last heartbeat was received
+	overloaded bool
+	lost       bool
+}
+
+// NewNodeMonitor returns a new NodeMonitor, which calls the handler for each
+// alert. The handler may be nil if only the statuses are used.
+//
+// The handler is called synchronously from the goroutine observing the events
+// or calling Check, so it should not block.
+func NewNodeMonitor(thresholds NodeThresholds, handler func(NodeAlert)) *NodeMonitor {
+	if thresholds.MissedHeartbeats <= 0 {
+		thresholds.MissedHeartbeats = 3
+	}
+
+	return &NodeMonitor{
+		mu:         sync.Mutex{},
+		nodes:      make(map[string]*nodeState),
+		thresholds: thresholds,
+		handler:    handler,
+	}
+}
+
+// Observe updates the status of the node from the HEARTBEAT event and
+// reports the SHUTDOWN_REQUESTED event. The rest of the events are ignored.
+func (m *NodeMonitor) Observe(e Event) {
+	switch e.Name() {
+	case "HEARTBEAT":
+		status, err := ParseHeartbeat(e)
+		if err != nil || status.CoreUUID == "" {
+			return
+		}
+
+		m.report(m.heartbeat(status, time.Now()))
+
+	case "SHUTDOWN_REQUESTED":
+		m.report(m.shutdown(e.Get("Core-UUID"), e.Get("FreeSWITCH-Hostname")))
+	}
+}
+
+// heartbeat updates the status of the node and returns the alerts.
+func (m *NodeMonitor) heartbeat(status NodeStatus, now time.Time) []NodeAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[status.CoreUUID]
+	if !ok {
+		node = &nodeState{} //nolint:exhaustruct // zero state
+		m.nodes[status.CoreUUID] = node
+	}
+
+	if status.SessionsPerSecLimit == 0 {
+		status.SessionsPerSecLimit = m.thresholds.SessionsPerSecLimit
+	}
+
+	node.status = status
+	node.seen = now
+
+	var alerts []NodeAlert
+
+	if node.lost {
+		node.lost = false
+		alerts = append(alerts, NodeAlert{Kind: NodeHeartbeatResumed, Status: status, Reason: ""})
+	}
+
+	reasons := m.overload(status)
+
+	switch {
+	case len(reasons) > 0 && !node.overloaded:
+		node.overloaded = true
+		alerts = append(alerts, NodeAlert{Kind: NodeOverloaded, Status: status, Reason: strings.Join(reasons, ", ")})
+	case len(reasons) == 0 && node.overloaded:
+		node.overloaded = false
+		alerts = append(alerts, NodeAlert{Kind: NodeRecovered, Status: status, Reason: ""})
+	}
+
+	return alerts
+}
+
+// overload returns the descriptions of the exceeded thresholds.
+func (m *NodeMonitor) overload(status NodeStatus) []string {
+	var reasons []string
+
+	if t := m.thresholds.SessionLoad; t > 0 && status.SessionLoad() >= t {
+		reasons = append(reasons, fmt.Sprintf("sessions %d of %d", status.Sessions, status.MaxSessions))
+	}
+
+	if t := m.thresholds.RateLoad; t > 0 && status.RateLoad() >= t {
+		reasons = append(reasons, fmt.Sprintf("sessions per second %d of %d",
+			status.SessionsPerSec, status.SessionsPerSecLimit))
+	}
+
+	if t := m.thresholds.MinIdleCPU; t > 0 && status.IdleCPU < t {
+		reasons = append(reasons, fmt.Sprintf("idle cpu %.2f%%", status.IdleCPU))
+	}
+
+	return reasons
+}
+
+// shutdown forgets the node going to shut down and returns the alert.
+func (m *NodeMonitor) shutdown(coreUUID, hostname string) []NodeAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := NodeStatus{CoreUUID: coreUUID, Hostname: hostname} //nolint:exhaustruct // unknown
+	if node, ok := m.nodes[coreUUID]; ok {
+		status = node.status
+		delete(m.nodes, coreUUID) // the heartbeats stop anyway
+	}
+
+	return []NodeAlert{{Kind: NodeShutdown, Status: status, Reason: ""}}
+}
+
+// Check reports the nodes whose heartbeats stopped.
+func (m *NodeMonitor) Check() {
+	m.report(m.check(time.Now()))
+}
+
+// check returns the alerts of the nodes whose heartbeats stopped by now.
+func (m *NodeMonitor) check(now time.Time) []NodeAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var alerts []NodeAlert
+
+	for _, node := range m.nodes {
+		interval := node.status.HeartbeatInterval
+		if interval <= 0 {
+			interval = defaultHeartbeatInterval
+		}
+
+		if !node.lost && now.Sub(node.seen) > interval*time.Duration(m.thresholds.MissedHeartbeats) {
+			node.lost = true
+			alerts = append(alerts, NodeAlert{Kind: NodeHeartbeatLost, Status: node.status, Reason: ""})
+		}
+	}
+
+	return alerts
+}
+
+// Run calls Check with the given interval until the context is done.
+func (m *NodeMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the latest status of the node with the given Core-UUID.
+func (m *NodeMonitor) Status(coreUUID string) (NodeStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[coreUUID]
+	if !ok {
+		return NodeStatus{}, false
+	}
+
+	return node.status, true
+}
+
+// Statuses returns the latest statuses of all nodes sorted by the hostname.
+func (m *NodeMonitor) Statuses() []NodeStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]NodeStatus, 0, len(m.nodes))
+	for _, node := range m.nodes {
+		statuses = append(statuses, node.status)
+	}
+
+	slices.SortFunc(statuses, func(a, b NodeStatus) int {
+		return strings.Compare(a.Hostname+a.CoreUUID, b.Hostname+b.CoreUUID)
+	})
+
+	return statuses
+}
+
+// Forget removes the node, e.g. after it is replaced.
+func (m *NodeMonitor) Forget(coreUUID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.nodes, coreUUID)
+}
+
+// report calls the handler for the alerts.
+func (m *NodeMonitor) report(alerts []NodeAlert) {
+	if m.handler == nil {
+		return
+	}
+
+	for _, alert := range alerts {
+		m.handler(alert)
+	}
+}
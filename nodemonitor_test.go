@@ -0,0 +1,100 @@
+package esl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeMonitor(t *testing.T) {
+	var alerts []string
+
+	m := NewNodeMonitor(NodeThresholds{
+		SessionLoad: 0.9, RateLoad: 0, MinIdleCPU: 10, SessionsPerSecLimit: 0, MissedHeartbeats: 0,
+	},
+		func(a NodeAlert) { alerts = append(alerts, a.Kind.String()+" "+a.Status.Hostname+" "+a.Reason) })
+
+	heartbeat := func(sessions, idle string) Event {
+		return NewEvent("HEARTBEAT", map[string]string{
+			"Core-UUID":           "core-1",
+			"FreeSWITCH-Hostname": "fs1",
+			"Session-Count":       sessions,
+			"Max-Sessions":        "100",
+			"Idle-CPU":            idle,
+			"Heartbeat-Interval":  "1",
+		}, nil)
+	}
+
+	m.Observe(heartbeat("10", "90"))
+	m.Observe(heartbeat("95", "5"))
+	m.Observe(heartbeat("96", "5")) // still overloaded: no alert
+	m.Observe(heartbeat("10", "90"))
+
+	if status, ok := m.Status("core-1"); !ok || status.Sessions != 10 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	m.report(m.check(time.Now().Add(2 * time.Second)))
+	m.report(m.check(time.Now().Add(4 * time.Second)))
+	m.report(m.check(time.Now().Add(5 * time.Second))) // already reported
+	m.Observe(heartbeat("10", "90"))
+
+	m.Observe(NewEvent("SHUTDOWN_REQUESTED", map[string]string{"Core-UUID": "core-1"}, nil))
+
+	if statuses := m.Statuses(); len(statuses) != 0 {
+		t.Errorf("node is not removed after shutdown: %+v", statuses)
+	}
+
+	want := []string{
+		"overloaded fs1 sessions 95 of 100, idle cpu 5.00%",
+		"recovered fs1 ",
+		"heartbeat lost fs1 ",
+		"heartbeat resumed fs1 ",
+		"shutdown fs1 ",
+	}
+
+	if len(alerts) != len(want) {
+		t.Fatalf("unexpected alerts: %q", alerts)
+	}
+
+	for i := range want {
+		if alerts[i] != want[i] {
+			t.Errorf("alert %d: got %q, want %q", i, alerts[i], want[i])
+		}
+	}
+}
+
+func TestWithNodeMonitor(t *testing.T) {
+	m := NewNodeMonitor(NodeThresholds{}, nil) //nolint:exhaustruct
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		srv.sendEvent("Event-Name: HEARTBEAT", "Core-UUID: core-1", "Session-Count: 7")
+		srv.recv() // exit
+		srv.conn.Close()
+	}, WithNodeMonitor(m))
+
+	waitFor(t, func() bool {
+		status, ok := m.Status("core-1")
+
+		return ok && status.Sessions == 7
+	})
+
+	client.Close()
+}
+
+func TestNodeMonitorRateLoad(t *testing.T) {
+	var reasons []string
+
+	m := NewNodeMonitor(NodeThresholds{ //nolint:exhaustruct // rate only
+		RateLoad: 0.8, SessionsPerSecLimit: 30,
+	}, func(a NodeAlert) { reasons = append(reasons, a.Reason) })
+
+	m.Observe(NewEvent("HEARTBEAT", map[string]string{
+		"Core-UUID":            "core-1",
+		"Session-Per-Sec":      "25",
+		"Session-Per-Sec-Last": "25",
+	}, nil))
+
+	if len(reasons) != 1 || reasons[0] != "sessions per second 25 of 30" {
+		t.Errorf("unexpected alerts: %q", reasons)
+	}
+}
@@ -0,0 +1,198 @@
+package esl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotHeartbeat is returned by ParseHeartbeat for the events other than
+// HEARTBEAT.
+var ErrNotHeartbeat = errors.New("not a heartbeat event")
+
+// NodeStatus is the status of the FreeSWITCH node, reported by the HEARTBEAT
+// events and the status API command. Some fields are known only from one of
+// the sources, the rest are zero.
+type NodeStatus struct {
+	CoreUUID string // Core-UUID, heartbeat only
+	Hostname string // FreeSWITCH-Hostname, heartbeat only
+	Version  string // FreeSWITCH version, e.g. "1.10.9 -release 64bit"
+	Ready    bool   // the system is up and ready
+	Uptime   time.Duration
+
+	Sessions             int   // current number of the sessions
+	MaxSessions          int   // the sessions limit
+	SessionsSinceStartup int64 // total number of the sessions since startup
+	SessionsPeak         int   // the peak number of the sessions
+	SessionsPeakFiveMin  int   // the peak number of the sessions in the last 5 minutes
+
+	SessionsPerSec        int // sessions created in the last second
+	SessionsPerSecLimit   int // the limit of the sessions per second, status only
+	SessionsPerSecPeak    int // the peak of the sessions per second
+	SessionsPerSecFiveMin int // the peak of the sessions per second in the last 5 minutes
+
+	IdleCPU    float64 // percent of the idle CPU
+	MinIdleCPU float64 // the configured min idle CPU, status only
+
+	HeartbeatInterval time.Duration // heartbeat only
+	Timestamp         time.Time     // the time of the event, heartbeat only
+}
+
+// SessionLoad returns the ratio of the current sessions to the limit, or zero
+// if the limit is unknown.
+func (s NodeStatus) SessionLoad() float64 {
+	if s.MaxSessions <= 0 {
+		return 0
+	}
+
+	return float64(s.Sessions) / float64(s.MaxSessions)
+}
+
+// RateLoad returns the ratio of the sessions per second to the limit, or zero
+// if the limit is unknown.
+func (s NodeStatus) RateLoad() float64 {
+	if s.SessionsPerSecLimit <= 0 {
+		return 0
+	}
+
+	return float64(s.SessionsPerSec) / float64(s.SessionsPerSecLimit)
+}
+
+// ParseHeartbeat returns the node status from the HEARTBEAT event. The event
+// has no limit of the sessions per second, so SessionsPerSecLimit is zero.
+func ParseHeartbeat(e Event) (NodeStatus, error) {
+	if e.Name() != "HEARTBEAT" {
+		return NodeStatus{}, fmt.Errorf("%w: %s", ErrNotHeartbeat, e.Name())
+	}
+
+	atoi := func(key string) int {
+		n, _ := strconv.Atoi(e.Get(key))
+
+		return n
+	}
+
+	msec := func(key string) time.Duration {
+		n, _ := strconv.ParseInt(e.Get(key), 10, 64)
+
+		return time.Duration(n) * time.Millisecond
+	}
+
+	status := NodeStatus{
+		CoreUUID:              e.Get("Core-UUID"),
+		Hostname:              e.Get("FreeSWITCH-Hostname"),
+		Version:               e.Get("FreeSWITCH-Version"),
+		Ready:                 e.Get("Event-Info") == "System Ready",
+		Uptime:                msec("Uptime-msec"),
+		Sessions:              atoi("Session-Count"),
+		MaxSessions:           atoi("Max-Sessions"),
+		SessionsSinceStartup:  0,
+		SessionsPeak:          atoi("Session-Peak-Max"),
+		SessionsPeakFiveMin:   atoi("Session-Peak-FiveMin"),
+		SessionsPerSec:        atoi("Session-Per-Sec-Last"),
+		SessionsPerSecLimit:   0, // Session-Per-Sec is the counter, not the limit
+		SessionsPerSecPeak:    atoi("Session-Per-Sec-Max"),
+		SessionsPerSecFiveMin: atoi("Session-Per-Sec-FiveMin"),
+		IdleCPU:               0,
+		MinIdleCPU:            0,
+		HeartbeatInterval:     time.Duration(atoi("Heartbeat-Interval")) * time.Second,
+		Timestamp:             e.Timestamp(),
+	}
+
+	status.SessionsSinceStartup, _ = strconv.ParseInt(e.Get("Session-Since-Startup"), 10, 64)
+	status.IdleCPU, _ = strconv.ParseFloat(e.Get("Idle-CPU"), 64)
+
+	if status.Uptime == 0 {
+		status.Uptime = parseUptime(e.Get("Up-Time"))
+	}
+
+	return status, nil
+}
+
+// ParseStatus returns the node status from the output of the status API
+// command:
+//
+//	UP 0 years, 0 days, 1 hour, 12 minutes, 34 seconds, 567 milliseconds, 890 microseconds
+//	FreeSWITCH (Version 1.10.9 -release 64bit) is ready
+//	12 session(s) since startup
+//	3 session(s) - peak 5, last 5min 4
+//	1 session(s) per Sec out of max 30, peak 3, last 5min 2
+//	1000 session(s) max
+//	min idle cpu 0.00/98.50
+//	Current Stack Size/Max 240K/8192K
+func ParseStatus(s string) (NodeStatus, error) {
+	var status NodeStatus
+
+	uptime, ok := strings.CutPrefix(strings.TrimSpace(firstLine(s)), "UP ")
+	if !ok {
+		return status, fmt.Errorf("%w: %s", ErrNodeDown, firstLine(s))
+	}
+
+	status.Ready = true
+	status.Uptime = parseUptime(uptime)
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(line, "FreeSWITCH (Version "):
+			version, ready, _ := strings.Cut(strings.TrimPrefix(line, "FreeSWITCH (Version "), ")")
+			status.Version = version
+			status.Ready = strings.Contains(ready, "is ready")
+
+		case strings.HasSuffix(line, " session(s) since startup"):
+			fmt.Sscanf(line, "%d", &status.SessionsSinceStartup) //nolint:errcheck
+
+		case strings.Contains(line, " session(s) - peak "):
+			fmt.Sscanf(line, "%d session(s) - peak %d, last 5min %d", //nolint:errcheck
+				&status.Sessions, &status.SessionsPeak, &status.SessionsPeakFiveMin)
+
+		case strings.Contains(line, " session(s) per Sec out of max "):
+			fmt.Sscanf(line, "%d session(s) per Sec out of max %d, peak %d, last 5min %d", //nolint:errcheck
+				&status.SessionsPerSec, &status.SessionsPerSecLimit,
+				&status.SessionsPerSecPeak, &status.SessionsPerSecFiveMin)
+
+		case strings.HasSuffix(line, " session(s) max"):
+			fmt.Sscanf(line, "%d", &status.MaxSessions) //nolint:errcheck
+
+		case strings.HasPrefix(line, "min idle cpu "):
+			fmt.Sscanf(line, "min idle cpu %f/%f", &status.MinIdleCPU, &status.IdleCPU) //nolint:errcheck
+		}
+	}
+
+	return status, nil
+}
+
+// uptimeUnits are the units of the uptime text.
+//
+//nolint:gochecknoglobals
+var uptimeUnits = map[string]time.Duration{
+	"year":        365 * 24 * time.Hour,
+	"day":         24 * time.Hour,
+	"hour":        time.Hour,
+	"minute":      time.Minute,
+	"second":      time.Second,
+	"millisecond": time.Millisecond,
+	"microsecond": time.Microsecond,
+}
+
+// parseUptime parses the uptime text, e.g. "0 years, 1 day, 2 hours".
+func parseUptime(s string) time.Duration {
+	var uptime time.Duration
+
+	for _, part := range strings.Split(s, ",") {
+		var (
+			n    int64
+			unit string
+		)
+
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "%d %s", &n, &unit); err != nil {
+			continue
+		}
+
+		uptime += time.Duration(n) * uptimeUnits[strings.TrimSuffix(unit, "s")]
+	}
+
+	return uptime
+}
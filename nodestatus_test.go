@@ -0,0 +1,109 @@
+package esl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseHeartbeat(t *testing.T) {
+	e := NewEvent("HEARTBEAT", map[string]string{
+		"Core-UUID":               "core-1",
+		"FreeSWITCH-Hostname":     "fs1",
+		"FreeSWITCH-Version":      "1.10.9-release~64bit",
+		"Event-Info":              "System Ready",
+		"Up-Time":                 "0 years, 0 days, 1 hour, 2 minutes, 3 seconds, 4 milliseconds, 5 microseconds",
+		"Session-Count":           "3",
+		"Max-Sessions":            "1000",
+		"Session-Since-Startup":   "12",
+		"Session-Peak-Max":        "5",
+		"Session-Peak-FiveMin":    "4",
+		"Session-Per-Sec":         "2",
+		"Session-Per-Sec-Last":    "1",
+		"Session-Per-Sec-Max":     "3",
+		"Session-Per-Sec-FiveMin": "2",
+		"Idle-CPU":                "98.5",
+		"Heartbeat-Interval":      "20",
+	}, nil)
+
+	status, err := ParseHeartbeat(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := NodeStatus{
+		CoreUUID:              "core-1",
+		Hostname:              "fs1",
+		Version:               "1.10.9-release~64bit",
+		Ready:                 true,
+		Uptime:                time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond + 5*time.Microsecond,
+		Sessions:              3,
+		MaxSessions:           1000,
+		SessionsSinceStartup:  12,
+		SessionsPeak:          5,
+		SessionsPeakFiveMin:   4,
+		SessionsPerSec:        1,
+		SessionsPerSecLimit:   0,
+		SessionsPerSecPeak:    3,
+		SessionsPerSecFiveMin: 2,
+		IdleCPU:               98.5,
+		MinIdleCPU:            0,
+		HeartbeatInterval:     20 * time.Second,
+		Timestamp:             time.Time{},
+	}
+
+	if status != want {
+		t.Errorf("unexpected status:\n got %+v\nwant %+v", status, want)
+	}
+
+	if _, err := ParseHeartbeat(NewEvent("CHANNEL_CREATE", nil, nil)); !errors.Is(err, ErrNotHeartbeat) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	status, err := ParseStatus("UP 0 years, 1 day, 2 hours, 0 minutes, 5 seconds, 0 milliseconds, 0 microseconds\n" +
+		"FreeSWITCH (Version 1.10.9 -release 64bit) is ready\n" +
+		"12 session(s) since startup\n" +
+		"3 session(s) - peak 5, last 5min 4\n" +
+		"1 session(s) per Sec out of max 30, peak 3, last 5min 2\n" +
+		"1000 session(s) max\n" +
+		"min idle cpu 0.00/98.50\n" +
+		"Current Stack Size/Max 240K/8192K\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := NodeStatus{
+		CoreUUID:              "",
+		Hostname:              "",
+		Version:               "1.10.9 -release 64bit",
+		Ready:                 true,
+		Uptime:                26*time.Hour + 5*time.Second,
+		Sessions:              3,
+		MaxSessions:           1000,
+		SessionsSinceStartup:  12,
+		SessionsPeak:          5,
+		SessionsPeakFiveMin:   4,
+		SessionsPerSec:        1,
+		SessionsPerSecLimit:   30,
+		SessionsPerSecPeak:    3,
+		SessionsPerSecFiveMin: 2,
+		IdleCPU:               98.5,
+		MinIdleCPU:            0,
+		HeartbeatInterval:     0,
+		Timestamp:             time.Time{},
+	}
+
+	if status != want {
+		t.Errorf("unexpected status:\n got %+v\nwant %+v", status, want)
+	}
+
+	if load := status.SessionLoad(); load != 0.003 {
+		t.Errorf("unexpected session load: %v", load)
+	}
+
+	if _, err := ParseStatus("-ERR not ready"); !errors.Is(err, ErrNodeDown) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
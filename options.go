@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"slices"
 )
 
 // Option is a function type used to modify configuration options.
@@ -20,6 +21,79 @@ func WithEvents(events chan<- Event, autoClose ...bool) Option {
 	}
 }
 
+// WithEventFilter returns an Option that sets the expression to filter the
+// events on the client side: only the events matching the expression are
+// sent to the events channel.
+//
+// When the expression can be pushed down to the server, the corresponding
+// filter commands are sent right after connecting, so that the server does
+// not send most of the events which will be dropped anyway. The server
+// filters are combined with OR and apply to the whole connection, so the
+// BACKGROUND_JOB, HEARTBEAT and SHUTDOWN_REQUESTED events used by the client
+// itself are passed by the additional filters. The pushdown is skipped with
+// the sequence monitor, which checks all events. Pass false as the pushdown
+// parameter to disable it if the other events are used bypassing the
+// expression, e.g. with Filter.
+func WithEventFilter(x *Expr, pushdown ...bool) Option {
+	return func(c *config) {
+		c.filter = x
+		c.pushdown = len(pushdown) == 0 || pushdown[0]
+	}
+}
+
+// WithSequenceMonitor returns an Option that sets the monitor to check the
+// sequence of all received events, including those filtered out on the client side.
+func WithSequenceMonitor(m *SequenceMonitor) Option {
+	return func(c *config) {
+		c.sequence = m
+	}
+}
+
+// WithNodeMonitor returns an Option that sets the monitor of the node status
+// observing the received HEARTBEAT and SHUTDOWN_REQUESTED events, including
+// those filtered out on the client side.
+func WithNodeMonitor(m *NodeMonitor) Option {
+	return func(c *config) {
+		c.monitor = m
+	}
+}
+
+// WithEventPool returns an Option that enables recycling of the received
+// events: the receiver should call Event.Release when it is done with the
+// event, so that its resources are reused for the next one. The events dropped
+// by the client-side filter are released automatically.
+//
+// It reduces the allocations at the high event rates, but the released event
+// must not be used anymore, including all of its copies.
+func WithEventPool() Option {
+	return func(c *config) {
+		c.pooled = true
+	}
+}
+
+// WithLogs returns an Option that sets the channel to receive the lines of
+// the FreeSWITCH console log, enabled with Client.Log.
+//
+// If the autoClose parameter is specified, the channel will be automatically
+// closed when the connection to the server is closed.
+func WithLogs(logs chan<- LogLine, autoClose ...bool) Option {
+	return func(c *config) {
+		c.logs = logs
+		c.logsAutoClose = len(autoClose) > 0 && autoClose[0]
+	}
+}
+
+// WithLimiter returns an Option that limits the rate of the commands and the
+// number of the background jobs in flight with the limiter. The limiter is
+// added to the interceptors of the commands at the position of this option,
+// see WithInterceptors, and observes the received BACKGROUND_JOB events.
+func WithLimiter(l *Limiter) Option {
+	return func(c *config) {
+		c.interceptors = append(c.interceptors, l.Interceptor())
+		c.limiter = l
+	}
+}
+
 // WithLog returns an Option that sets the logger for the configuration.
 func WithLog(log *slog.Logger) Option {
 	return func(c *config) {
@@ -44,10 +118,20 @@ func WithDumpOut(w io.Writer) Option {
 }
 
 type config struct {
-	events    chan<- Event
-	autoClose bool // automatically close the events channel on disconnect
-	log       *slog.Logger
-	r, w      io.Writer // in/out dumper
+	events        chan<- Event
+	autoClose     bool             // automatically close the events channel on disconnect
+	filter        *Expr            // client-side events filter
+	pushdown      bool             // push down the events filter to the server
+	sequence      *SequenceMonitor // events sequence monitor
+	monitor       *NodeMonitor     // node status monitor
+	pooled        bool             // recycle the received events
+	logs          chan<- LogLine   // console log lines
+	logsAutoClose bool             // automatically close the logs channel on disconnect
+	hooks         hooks            // instrumentation callbacks
+	interceptors  interceptors     // commands middleware
+	limiter       *Limiter         // commands rate limiter
+	log           *slog.Logger
+	r, w          io.Writer // in/out dumper
 }
 
 // getConfig returns a config object based on the provided options.
@@ -73,6 +157,34 @@ func getConfig(opts ...Option) config {
 	return cfg
 }
 
+// clientEvents are the events used by the client itself, passed by the
+// server filters pushed down from the events filter.
+//
+//nolint:gochecknoglobals
+var clientEvents = []EventFilter{
+	{Header: "Event-Name", Value: "BACKGROUND_JOB"},
+	{Header: "Event-Name", Value: "HEARTBEAT"},
+	{Header: "Event-Name", Value: "SHUTDOWN_REQUESTED"},
+}
+
+// serverFilters returns the server filters pushed down from the events filter,
+// or nil if the pushdown is disabled or not possible.
+func (c config) serverFilters() []EventFilter {
+	if !c.pushdown || c.sequence != nil {
+		return nil
+	}
+
+	filters := c.filter.ServerFilters()
+	if filters == nil {
+		return nil
+	}
+
+	filters = append(filters, clientEvents...)
+	sortFilters(filters)
+
+	return slices.Compact(filters)
+}
+
 // dumper returns an io.ReadWriter that performs additional operations on the provided io.ReadWriter based on the
 // configuration provided.
 func (cfg config) dumper(rw io.ReadWriter) io.ReadWriter {
@@ -0,0 +1,271 @@
+package esl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxScanLookups is the number of header lookups served by scanning the raw
+// headers before the header map is built. Most consumers read only a few
+// headers of each event, such as the name and the sequence, so the map is
+// never built for them.
+const maxScanLookups = 8
+
+// lazyHeaders holds the raw headers of the received event and builds the
+// header map on demand.
+type lazyHeaders struct {
+	text    string // raw header lines without the Content-Length
+	count   int    // number of header lines
+	once    sync.Once
+	headers map[string]string
+	built   atomic.Bool
+	lookups atomic.Int32
+	pooled  bool // returned to the pool on Release
+}
+
+// lookup returns the value of the header and reports whether it is present.
+func (h *lazyHeaders) lookup(key string) (string, bool) {
+	if !h.built.Load() && h.lookups.Add(1) <= maxScanLookups {
+		return h.scan(key)
+	}
+
+	value, ok := h.parse()[key]
+
+	return value, ok
+}
+
+// scan looks up the header in the raw header lines without building the map.
+func (h *lazyHeaders) scan(key string) (string, bool) {
+	if key == "" || key == "Content-Length" {
+		return "", false
+	}
+
+	for text := h.text; text != ""; {
+		var line string
+
+		line, text, _ = strings.Cut(text, "\n")
+		if len(line) > len(key) && line[len(key)] == ':' && line[:len(key)] == key {
+			return headerValue(line[len(key)+1:]), true
+		}
+	}
+
+	return "", false
+}
+
+// parse builds the header map once and returns it.
+func (h *lazyHeaders) parse() map[string]string {
+	h.once.Do(func() {
+		if h.headers == nil {
+			h.headers = make(map[string]string, h.count)
+		}
+
+		for text := h.text; text != ""; {
+			var line string
+
+			line, text, _ = strings.Cut(text, "\n")
+			idx := strings.IndexByte(line, ':')
+
+			key := internKey(line[:idx])
+			if _, ok := h.headers[key]; ok || key == "Content-Length" {
+				continue // the first value wins, just like scan
+			}
+
+			h.headers[key] = headerValue(line[idx+1:])
+		}
+
+		h.built.Store(true)
+	})
+
+	return h.headers
+}
+
+// headerValue returns the decoded value of the raw header line after the colon.
+func headerValue(s string) string {
+	s = strings.TrimLeft(s, " \t")
+	s = strings.TrimSuffix(s, "\r")
+
+	return urlDecode(s) // allocates only if the value is url-encoded
+}
+
+// eventPool recycles the headers of the events released by Event.Release.
+var eventPool = sync.Pool{ //nolint:gochecknoglobals
+	New: func() any { return new(lazyHeaders) },
+}
+
+// Release returns the resources of the event received with the WithEventPool
+// option back to the pool. It does nothing for other events.
+//
+// The event and all of its copies must not be used after Release, including
+// the header values returned by Get: copy them with GetCopy or copy the whole
+// event with Clone to keep them.
+func (e Event) Release() {
+	h := e.lazy
+	if h == nil || !h.pooled {
+		return
+	}
+
+	h.text = ""
+	h.count = 0
+	h.once = sync.Once{}
+	h.built.Store(false)
+	h.lookups.Store(0)
+	h.pooled = false
+
+	if len(h.headers) > 1000 { //nolint:mnd // don't keep huge maps in the pool
+		h.headers = nil
+	} else {
+		clear(h.headers)
+	}
+
+	eventPool.Put(h)
+}
+
+// parseEvent parses the given byte slice as an event in the plain format.
+//
+// The event takes ownership of the buffer: the header values and the body
+// reference it without copying, so the caller must not modify the buffer
+// afterwards. The header values are url-decoded only if they contain '%',
+// and the header map is built only when more than a few headers are read.
+func parseEvent(buf []byte) (Event, error) {
+	return parseEventInto(new(lazyHeaders), buf)
+}
+
+// parseEventPooled is like parseEvent, but takes the headers from the pool.
+// The event should be returned to the pool with Release.
+func parseEventPooled(buf []byte) (Event, error) {
+	h, _ := eventPool.Get().(*lazyHeaders)
+	h.pooled = true
+
+	return parseEventInto(h, buf)
+}
+
+// parseEventInto parses the event into the given lazy headers in a single
+// pass, validating the header lines and looking for the Content-Length.
+func parseEventInto(h *lazyHeaders, buf []byte) (Event, error) {
+	var (
+		head   []byte // header lines up to the Content-Length
+		length int
+		count  int
+	)
+
+	rest := buf
+
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = nil
+		}
+
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) == 0 {
+			break // the end of headers
+		}
+
+		idx := bytes.IndexByte(line, ':')
+		if idx <= 0 {
+			return Event{}, fmt.Errorf("malformed header line: %q", line)
+		}
+
+		if string(line[:idx]) == "Content-Length" {
+			length, _ = strconv.Atoi(strings.TrimSpace(string(line[idx+1:])))
+
+			continue // defined by the body; FreeSWITCH sends it as the last header
+		}
+
+		head = buf[:len(buf)-len(rest)]
+		count++
+	}
+
+	h.text = strings.TrimSuffix(bytesToString(head), "\n")
+	h.count = count
+
+	event := Event{
+		headers: nil,
+		body:    nil,
+		lazy:    h,
+		tag:     nil,
+	}
+
+	if length > 0 {
+		if len(rest) < length {
+			return event, fmt.Errorf("failed to read body: %w", io.ErrUnexpectedEOF)
+		}
+
+		event.body = rest[:length:length]
+	}
+
+	return event, nil
+}
+
+// bytesToString returns the string sharing the memory with the byte slice.
+// The byte slice must not be modified afterwards.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// internKey returns the interned copy of the well-known header name, so that
+// the header map does not keep the whole event buffer alive through its keys.
+func internKey(key string) string {
+	if s, ok := knownKeys[key]; ok {
+		return s
+	}
+
+	return key
+}
+
+// intern returns the interned string for the well-known frame header names
+// and values, or a new string otherwise. The lookup does not allocate.
+func intern(b []byte) string {
+	if s, ok := knownFrameStrings[string(b)]; ok {
+		return s
+	}
+
+	return string(b)
+}
+
+// knownKeys contains the header names present in almost every event.
+var knownKeys = makeSet( //nolint:gochecknoglobals
+	"Event-Name", "Event-Subclass", "Core-UUID", "FreeSWITCH-Hostname",
+	"FreeSWITCH-Switchname", "FreeSWITCH-IPv4", "FreeSWITCH-IPv6",
+	"Event-Date-Local", "Event-Date-GMT", "Event-Date-Timestamp",
+	"Event-Calling-File", "Event-Calling-Function", "Event-Calling-Line-Number",
+	"Event-Sequence", "Unique-ID", "Job-UUID", "Job-Command", "Job-Command-Arg",
+	"Channel-State", "Channel-Call-State", "Channel-State-Number", "Channel-Name",
+	"Channel-Call-UUID", "Answer-State", "Call-Direction", "Hangup-Cause",
+	"Caller-Direction", "Caller-Username", "Caller-Dialplan", "Caller-Caller-ID-Name",
+	"Caller-Caller-ID-Number", "Caller-Destination-Number", "Caller-Unique-ID",
+	"Caller-Source", "Caller-Context", "Caller-Channel-Name", "Caller-Network-Addr",
+	"Other-Leg-Unique-ID", "Presence-Call-Direction", "Content-Type",
+)
+
+// knownFrameStrings contains the header names and values of the frames sent
+// by the server.
+var knownFrameStrings = makeSet( //nolint:gochecknoglobals
+	"Content-Type", "Content-Length", "Reply-Text", "Job-UUID",
+	"Controlled-Session-UUID", "Content-Disposition", "Socket-Mode", "Control",
+	"Log-Level", "Text-Channel", "Log-File", "Log-Func", "Log-Line", "User-Data",
+	commandReply, apiResponse, disconnectNotice, eventPlain, eventJSON, eventXML,
+	"auth/request", "log/data", "+OK accepted", "+OK",
+)
+
+// makeSet returns the map of the strings to themselves.
+func makeSet(values ...string) map[string]string {
+	set := make(map[string]string, len(values))
+	for _, v := range values {
+		set[v] = v
+	}
+
+	return set
+}
@@ -0,0 +1,274 @@
+package esl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// testEventData returns the CHANNEL_CREATE-like event with about 150 headers,
+// as sent by FreeSWITCH for a typical SIP call.
+func testEventData() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("Event-Name: CHANNEL_CREATE\n" +
+		"Core-UUID: 6b6e1c04-5e1f-4c3b-9b5d-0d7c3b0e6a11\n" +
+		"FreeSWITCH-Hostname: fs1.example.com\n" +
+		"Event-Date-Local: 2024-05-01%2012%3A00%3A00\n" +
+		"Event-Date-Timestamp: 1714564800000000\n" +
+		"Event-Sequence: 123456\n" +
+		"Unique-ID: 0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9\n" +
+		"Channel-Name: sofia/internal/1000%40example.com\n" +
+		"Caller-Caller-ID-Name: Extension%201000\n")
+
+	for i := range 70 {
+		fmt.Fprintf(&buf, "variable_sip_h_X-Custom-%d: value-%d\n", i, i)
+		fmt.Fprintf(&buf, "variable_encoded_%d: a%%20b%%3Ac%%40d-%d\n", i, i)
+	}
+
+	body := "+OK body"
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\n\n" + body)
+
+	return buf.Bytes()
+}
+
+func TestParseEventLazy(t *testing.T) {
+	data := testEventData()
+
+	event, err := parseEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := parseEventLegacy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := event.Get("Channel-Name"); got != "sofia/internal/1000@example.com" {
+		t.Errorf("unexpected channel name: %q", got)
+	}
+
+	if got := event.Get("Content-Length"); got != "" {
+		t.Errorf("content-length is not removed: %q", got)
+	}
+
+	if _, ok := event.lookup("Missing"); ok {
+		t.Error("missing header is found")
+	}
+
+	if event.lazy.built.Load() {
+		t.Error("header map is built for a few lookups")
+	}
+
+	if !maps.Equal(event.header(), legacy.headers) {
+		t.Errorf("headers mismatch:\n got: %q\nwant: %q", event.header(), legacy.headers)
+	}
+
+	if event.Body() != legacy.Body() {
+		t.Errorf("body mismatch: %q", event.Body())
+	}
+
+	for range maxScanLookups + 1 {
+		event.Get("Unique-ID")
+	}
+
+	if got := event.Get("variable_encoded_1"); got != "a b:c@d-1" {
+		t.Errorf("unexpected value after build: %q", got)
+	}
+}
+
+func TestParseEventErrors(t *testing.T) {
+	if _, err := parseEvent([]byte("Event-Name: HEARTBEAT\nbad line\n\n")); err == nil {
+		t.Error("malformed header line is accepted")
+	}
+
+	if _, err := parseEvent([]byte("Event-Name: HEARTBEAT\nContent-Length: 10\n\nshort")); err == nil {
+		t.Error("short body is accepted")
+	}
+
+	event, err := parseEvent([]byte("Event-Name: HEARTBEAT\r\nUp-Time: 1\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := event.Get("Up-Time"); got != "1" {
+		t.Errorf("unexpected value with CRLF: %q", got)
+	}
+}
+
+func TestEventRelease(t *testing.T) {
+	data := testEventData()
+
+	event, err := parseEventPooled(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := event.Name()
+	clone := event.Clone()
+
+	event.Release()
+
+	if name != "CHANNEL_CREATE" || clone.Name() != name {
+		t.Errorf("unexpected name after release: %q, %q", name, clone.Name())
+	}
+
+	NewEvent("HEARTBEAT", nil, nil).Release() // no-op for the created events
+}
+
+func TestEventTag(t *testing.T) {
+	event, err := parseEventPooled(testEventData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagged := event.withTag(NodeHeader, "node-1")
+
+	if tagged.Get(NodeHeader) != "node-1" || event.Get(NodeHeader) != "" {
+		t.Errorf("unexpected tag: %q, %q", tagged.Get(NodeHeader), event.Get(NodeHeader))
+	}
+
+	if tagged.lazy.built.Load() {
+		t.Error("headers are parsed by the tag")
+	}
+
+	if !slices.Contains(tagged.Headers(), NodeHeader) || slices.Contains(event.Headers(), NodeHeader) {
+		t.Errorf("unexpected headers: %q", tagged.Headers())
+	}
+
+	clone := tagged.Clone()
+	tagged.Release()
+
+	if clone.Get(NodeHeader) != "node-1" || clone.Name() != "CHANNEL_CREATE" {
+		t.Errorf("unexpected clone: %s", clone)
+	}
+}
+
+func BenchmarkParseEvent(b *testing.B) {
+	data := testEventData()
+
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEventLegacy(data)
+			_ = event.Get("Event-Name")
+			_ = event.Get("Unique-ID")
+		}
+	})
+
+	b.Run("lazy", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEvent(data)
+			_ = event.Get("Event-Name")
+			_ = event.Get("Unique-ID")
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEventPooled(data)
+			_ = event.Get("Event-Name")
+			_ = event.Get("Unique-ID")
+			event.Release()
+		}
+	})
+
+	b.Run("legacy-all", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEventLegacy(data)
+			_ = event.Headers()
+		}
+	})
+
+	b.Run("lazy-all", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEvent(data)
+			_ = event.Headers()
+		}
+	})
+
+	b.Run("pooled-all", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			event, _ := parseEventPooled(data)
+			_ = event.Headers()
+			event.Release()
+		}
+	})
+}
+
+// parseEventLegacy is the previous implementation of parseEvent kept for the
+// benchmarks and as the reference for the tests.
+func parseEventLegacy(body []byte) (Event, error) {
+	event := Event{
+		headers: make(map[string]string, upcomingHeaderKeys(body)),
+		body:    nil,
+		lazy:    nil,
+	}
+
+	for len(body) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line, body = body[:i], body[i+1:]
+		}
+
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
+			break // the end of headers
+		}
+
+		idx := bytes.IndexByte(line, ':')
+		if idx <= 0 {
+			return event, fmt.Errorf("malformed header line: %q", line)
+		}
+
+		key, value := string(line[:idx]), trimLeft(line[idx+1:])
+		event.headers[key] = urlDecode(value)
+	}
+
+	length, _ := strconv.Atoi(event.headers["Content-Length"])
+	delete(event.headers, "Content-Length") // defined by the body
+
+	if length > 0 {
+		event.body = make([]byte, length)
+		if copy(event.body, body) != length {
+			return event, fmt.Errorf("failed to read body: %w", io.ErrUnexpectedEOF)
+		}
+	}
+
+	return event, nil
+}
+
+// upcomingHeaderKeys returns the number of upcoming header keys in the given byte slice.
+func upcomingHeaderKeys(body []byte) int {
+	var n int
+
+	for len(body) > 0 && n < 1000 {
+		var line []byte
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line, body = body[:i], body[i+1:]
+		}
+
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\r') {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,324 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolCheckInterval is the interval of the health checks of the idle
+// connections of Pool.
+//
+//nolint:gochecknoglobals
+var PoolCheckInterval = time.Second * 30
+
+// ErrPoolClosed is returned by Pool after it is closed.
+var ErrPoolClosed = errors.New("pool closed")
+
+// PoolStats contains the number of the connections of Pool used for the API
+// commands.
+type PoolStats struct {
+	Open int // number of the open connections, idle and busy
+	Idle int // number of the idle connections
+}
+
+// Pool is the set of the connections to the same server to execute the
+// blocking API commands concurrently: the server doesn't accept the next
+// command on the connection until the api command is finished, so one slow
+// command like "show channels" delays all other commands of the Client.
+//
+// The Pool is the Client of the dedicated connection carrying the events, the
+// logs and all other commands, including bgapi. The API commands are sent to
+// the idle connections of the pool, which are opened on demand up to the size
+// limit and never receive events. The idle connections are checked with the
+// status command every PoolCheckInterval.
+type Pool struct {
+	*Client // the connection carrying the events
+
+	addr     string
+	password string
+	opts     []Option // options of the API connections
+	sem      chan struct{}
+	mu       sync.Mutex
+	idle     []*Client
+	open     int
+	stats    clientStats // API commands sent over the pool
+	done     chan struct{}
+	once     sync.Once // closes done
+	wg       sync.WaitGroup
+}
+
+// ConnectPool connects to the server with the given address and returns the
+// Pool with up to size connections for the API commands.
+//
+// The options are applied to all connections, but only the events connection
+// receives the events and the logs, and pushes down the event filter if
+// enabled.
+func ConnectPool(addr, password string, size int, opts ...Option) (*Pool, error) {
+	client, err := Connect(addr, password, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{
+		Client:   client,
+		addr:     addr,
+		password: password,
+		opts:     append(slices.Clip(opts), WithEvents(nil), WithLogs(nil), WithEventFilter(nil)),
+		sem:      make(chan struct{}, max(size, 1)),
+		mu:       sync.Mutex{},
+		idle:     nil,
+		open:     0,
+		stats:    clientStats{},
+		done:     make(chan struct{}),
+		once:     sync.Once{},
+		wg:       sync.WaitGroup{},
+	}
+
+	p.wg.Add(1)
+
+	go p.check()
+
+	return p, nil
+}
+
+// Close closes all connections of the pool.
+func (p *Pool) Close() error {
+	var err error
+
+	p.once.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+
+		p.mu.Lock()
+		idle := p.idle
+		p.idle = nil
+		p.mu.Unlock()
+
+		for _, c := range idle {
+			p.discard(c)
+		}
+
+		err = p.Client.Close()
+	})
+
+	return err
+}
+
+// PoolStats returns the number of the connections used for the API commands.
+func (p *Pool) PoolStats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{Open: p.open, Idle: len(p.idle)}
+}
+
+// Stats returns the counters of the events connection, including the API
+// commands sent over the pool.
+func (p *Pool) Stats() ClientStats {
+	stats := p.Client.Stats()
+	api := p.stats.snapshot()
+
+	stats.Commands += api.Commands
+	stats.CommandErrors += api.CommandErrors
+	stats.CommandLatency += api.CommandLatency
+
+	return stats
+}
+
+// API sends the API command over the idle connection and returns the
+// response body. It waits for the idle connection if all of them are busy.
+func (p *Pool) API(command string) (string, error) {
+	return p.APIContext(context.Background(), command)
+}
+
+// APIContext is like API, but stops waiting for the idle connection or the
+// reply when the context is done.
+func (p *Pool) APIContext(ctx context.Context, command string) (string, error) {
+	resp, err := p.do(ctx, cmd("api", command))
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Body(), nil
+}
+
+// Do sends the raw command, see Client.Do. The api commands are sent over the
+// pool, the rest over the events connection.
+func (p *Pool) Do(ctx context.Context, command string) (Response, error) {
+	cmd, err := parseCommand(command)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if cmd.name != "api" {
+		return p.Client.sendRecv(ctx, cmd)
+	}
+
+	return p.do(ctx, cmd)
+}
+
+// do sends the command over the idle connection.
+func (p *Pool) do(ctx context.Context, cmd command) (Response, error) {
+	client, err := p.acquire(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+
+	start := time.Now()
+	resp, err := client.sendRecv(ctx, cmd)
+	p.stats.command(time.Since(start), err)
+
+	// the late reply would block the next command: drop the connection
+	p.release(client, ctx.Err() != nil)
+
+	return resp, err
+}
+
+// acquire returns the idle connection, opening the new one if there are none.
+func (p *Pool) acquire(ctx context.Context) (*Client, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err() //nolint:wrapcheck
+	case <-p.done:
+		return nil, ErrPoolClosed
+	}
+
+	p.mu.Lock()
+
+	for len(p.idle) > 0 {
+		client := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if !isDone(client) {
+			p.mu.Unlock()
+
+			return client, nil
+		}
+
+		p.open--
+	}
+
+	p.open++
+	p.mu.Unlock()
+
+	client, err := connect(ctx, p.addr, p.password, p.opts...)
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.mu.Unlock()
+		<-p.sem
+
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// release returns the connection to the idle ones, or closes it.
+func (p *Pool) release(client *Client, drop bool) {
+	defer func() { <-p.sem }()
+
+	select {
+	case <-p.done:
+		drop = true
+	default:
+	}
+
+	if drop || isDone(client) {
+		p.discard(client)
+
+		return
+	}
+
+	p.mu.Lock()
+	p.idle = append(p.idle, client)
+	p.mu.Unlock()
+}
+
+// discard closes the connection.
+func (p *Pool) discard(client *Client) {
+	p.mu.Lock()
+	p.open--
+	p.mu.Unlock()
+
+	client.Close()
+}
+
+// check checks the idle connections periodically.
+func (p *Pool) check() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(PoolCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		p.mu.Lock()
+		n := len(p.idle)
+		p.mu.Unlock()
+
+		for range n {
+			if !p.checkIdle() {
+				break
+			}
+		}
+	}
+}
+
+// checkIdle checks the oldest idle connection, taking it from the pool for
+// the time of the check. It returns false if there is no idle connection or
+// all connections are busy.
+func (p *Pool) checkIdle() bool {
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	p.mu.Lock()
+
+	if len(p.idle) == 0 {
+		p.mu.Unlock()
+		<-p.sem
+
+		return false
+	}
+
+	client := p.idle[0]
+	p.idle = slices.Delete(p.idle, 0, 1)
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), AuthTimeout)
+	status, err := client.APIContext(ctx, "status")
+
+	cancel()
+	p.release(client, err != nil || !strings.HasPrefix(status, "UP"))
+
+	return true
+}
+
+// isDone reports whether the client connection is closed.
+func isDone(c *Client) bool {
+	select {
+	case <-c.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	defer func(interval time.Duration) { PoolCheckInterval = interval }(PoolCheckInterval)
+	PoolCheckInterval = 10 * time.Millisecond
+
+	node := newFakeNode(t, "a", "0")
+
+	events := make(chan Event, 1)
+
+	pool, err := ConnectPool(node.addr(), "ClueCon", 2, WithEvents(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pool.Close()
+
+	if err := pool.Subscribe("CHANNEL_CREATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the slow command does not delay the others
+	slow := make(chan error, 1)
+
+	go func() {
+		_, err := pool.API("msleep 100")
+		slow <- err
+	}()
+
+	waitFor(t, func() bool { return node.received("api msleep 100") == 1 })
+
+	if host, err := pool.API("hostname"); err != nil || host != "a" {
+		t.Errorf("unexpected result: %q, %v", host, err)
+	}
+
+	select {
+	case err := <-slow:
+		t.Errorf("the slow command is done first: %v", err)
+	default:
+	}
+
+	// the size limit
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	go pool.API("msleep 100") //nolint:errcheck
+
+	waitFor(t, func() bool { return node.received("api msleep 100") == 2 })
+
+	if _, err := pool.APIContext(ctx, "hostname"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("size limit is not applied: %v", err)
+	}
+
+	if err := <-slow; err != nil {
+		t.Error(err)
+	}
+
+	// the idle connections are checked
+	waitFor(t, func() bool { return node.received("api status") > 0 && pool.PoolStats().Idle == 2 })
+
+	if stats := pool.PoolStats(); stats.Open != 2 {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+
+	if stats := pool.Stats(); stats.Commands < 4 {
+		t.Errorf("unexpected commands: %d", stats.Commands)
+	}
+
+	if n := node.received("event CHANNEL_CREATE"); n != 1 {
+		t.Errorf("unexpected subscriptions: %d", n)
+	}
+
+	// concurrent calls of Close are safe
+	var wg sync.WaitGroup
+
+	for range 3 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			pool.Close()
+		}()
+	}
+
+	wg.Wait()
+
+	if _, err := pool.API("hostname"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,820 @@
+package esl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyAccount defines the credentials of the downstream clients of the Proxy
+// and the API commands they are permitted to execute.
+type ProxyAccount struct {
+	Name     string   `json:"name"`     // the account name used for the audit and userauth
+	Password string   `json:"password"` // the password of the auth command
+	Allow    []string `json:"allow"`    // permitted API commands, all if empty; see Proxy
+}
+
+// ProxyOption configures the Proxy.
+type ProxyOption func(*Proxy)
+
+// WithProxyAccount returns a ProxyOption that adds the account of the downstream clients.
+func WithProxyAccount(account ProxyAccount) ProxyOption {
+	return func(p *Proxy) {
+		allow := make([]string, 0, len(account.Allow))
+		for _, cmd := range account.Allow {
+			if cmd = strings.Join(strings.Fields(cmd), " "); cmd != "" {
+				allow = append(allow, cmd)
+			}
+		}
+
+		account.Allow = allow
+		p.accounts = append(p.accounts, account)
+	}
+}
+
+// WithProxyAudit returns a ProxyOption that sets the logger recording all
+// commands of the downstream clients with their results.
+func WithProxyAudit(log *slog.Logger) ProxyOption {
+	return func(p *Proxy) {
+		p.audit = log
+	}
+}
+
+// WithProxyLog returns a ProxyOption that sets the logger of the proxy.
+func WithProxyLog(log *slog.Logger) ProxyOption {
+	return func(p *Proxy) {
+		p.log = log
+	}
+}
+
+// WithProxyQueueSize returns a ProxyOption that sets the number of events
+// queued for each downstream client. The events are dropped when a slow client
+// falls behind, so that it does not hold up the rest of the clients.
+func WithProxyQueueSize(size int) ProxyOption {
+	return func(p *Proxy) {
+		p.queueSize = max(size, 1)
+	}
+}
+
+// Proxy multiplexes many downstream ESL clients onto one upstream Client.
+//
+// The downstream clients connect to the proxy as to FreeSWITCH itself. Their
+// api and bgapi commands are sent over the upstream connection and the replies
+// are routed back to them. The event and filter commands are applied to each
+// downstream client separately: the upstream subscriptions are reference
+// counted, and each client receives only the events matching its own
+// subscriptions and filters. The BACKGROUND_JOB events are sent only to the
+// client which started the job.
+//
+// The Allow list of the account permits the API command if the command equals
+// to the entry or starts with it followed by a space, so "show channels"
+// permits "show channels like 1000", but not "show calls".
+//
+// Proxy implements EventSink, so the upstream events can be passed to it with Pipe:
+//
+//	events := make(chan esl.Event, 100)
+//	client, err := esl.Connect(addr, password, esl.WithEvents(events, true))
+//	...
+//	proxy := esl.NewProxy(client, esl.WithProxyAccount(account))
+//	go esl.Pipe(ctx, events, proxy)
+//	err = proxy.Serve(listener)
+type Proxy struct {
+	upstream  *Client
+	accounts  []ProxyAccount
+	audit     *slog.Logger
+	log       *slog.Logger
+	queueSize int
+
+	mu        sync.Mutex
+	sessions  map[*proxySession]struct{}
+	jobs      map[string]*proxySession // the owners of the background jobs
+	listeners map[net.Listener]struct{}
+	closed    bool
+}
+
+// NewProxy returns a new Proxy for the upstream client.
+func NewProxy(upstream *Client, opts ...ProxyOption) *Proxy {
+	p := &Proxy{
+		upstream:  upstream,
+		accounts:  nil,
+		audit:     nopLogger,
+		log:       nopLogger,
+		queueSize: 1000, //nolint:mnd
+		mu:        sync.Mutex{},
+		sessions:  make(map[*proxySession]struct{}),
+		jobs:      make(map[string]*proxySession),
+		listeners: make(map[net.Listener]struct{}),
+		closed:    false,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	go func() {
+		<-upstream.Done()
+		p.Close()
+	}()
+
+	return p
+}
+
+// ErrProxyClosed is returned by Serve after the Proxy is closed.
+var ErrProxyClosed = errors.New("proxy closed")
+
+// Serve accepts the downstream connections on the listener and serves each of
+// them in a separate goroutine. It returns when the listener fails or the
+// proxy is closed, with ErrProxyClosed in the latter case.
+func (p *Proxy) Serve(ln net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return ErrProxyClosed
+	}
+
+	p.listeners[ln] = struct{}{}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.listeners, ln)
+		p.mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+
+			if closed {
+				return ErrProxyClosed
+			}
+
+			return err //nolint:wrapcheck
+		}
+
+		go p.ServeConn(conn)
+	}
+}
+
+// ServeConn serves the downstream client connection until it is closed.
+func (p *Proxy) ServeConn(rwc io.ReadWriteCloser) {
+	defer rwc.Close()
+
+	s := &proxySession{
+		proxy:   p,
+		conn:    newConn(rwc, p.log),
+		closer:  rwc,
+		remote:  "",
+		account: nil,
+		subs:    newSubscriptions(),
+		format:  "",
+		queue:   make(chan proxyFrame, p.queueSize),
+		done:    make(chan struct{}),
+		dropped: atomic.Uint64{},
+	}
+
+	if c, ok := rwc.(net.Conn); ok {
+		s.remote = c.RemoteAddr().String()
+	}
+
+	if !s.auth() {
+		return
+	}
+
+	if !p.register(s) {
+		s.disconnect()
+
+		return
+	}
+
+	defer p.unregister(s)
+
+	go s.writeEvents()
+
+	s.serve()
+}
+
+// WriteEvent sends the event to the downstream clients subscribed to it.
+func (p *Proxy) WriteEvent(_ context.Context, e Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e.Get("Event-Name") == "BACKGROUND_JOB" {
+		if owner, ok := p.jobs[e.Get("Job-UUID")]; ok {
+			delete(p.jobs, e.Get("Job-UUID"))
+			owner.send(e, nil)
+
+			return nil
+		}
+	}
+
+	cache := make(map[string]proxyFrame, 1) // event encoded in the formats
+
+	for s := range p.sessions {
+		s.send(e, cache)
+	}
+
+	return nil
+}
+
+// Close disconnects all downstream clients and stops the listeners.
+// The upstream client is not closed.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	p.closed = true
+
+	for ln := range p.listeners {
+		ln.Close()
+	}
+
+	for s := range p.sessions {
+		s.closer.Close() // the session is unregistered by its goroutine
+	}
+
+	return nil
+}
+
+// register adds the authenticated session, unless the proxy is closed.
+func (p *Proxy) register(s *proxySession) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.sessions[s] = struct{}{}
+
+	return true
+}
+
+// unregister removes the session with its jobs and upstream subscriptions.
+func (p *Proxy) unregister(s *proxySession) {
+	p.mu.Lock()
+	delete(p.sessions, s)
+
+	for id, owner := range p.jobs {
+		if owner == s {
+			delete(p.jobs, id)
+		}
+	}
+
+	p.mu.Unlock()
+
+	close(s.done)
+
+	s.subs.mu.Lock()
+	names := s.subs.names()
+	s.subs.mu.Unlock()
+
+	if len(names) > 0 {
+		if err := p.upstream.Unsubscribe(names...); err != nil {
+			p.log.Warn("esl: proxy failed to unsubscribe", slog.String("err", err.Error()))
+		}
+	}
+
+	if dropped := s.dropped.Load(); dropped > 0 {
+		p.log.Warn("esl: proxy dropped events of slow client",
+			slog.String("remote", s.remote),
+			slog.Uint64("dropped", dropped))
+	}
+}
+
+// addJob registers the owner of the background job.
+func (p *Proxy) addJob(id string, s *proxySession) {
+	p.mu.Lock()
+	p.jobs[id] = s
+	p.mu.Unlock()
+}
+
+// removeJob removes the owner of the background job.
+func (p *Proxy) removeJob(id string) {
+	p.mu.Lock()
+	delete(p.jobs, id)
+	p.mu.Unlock()
+}
+
+// proxySession is the connection of the downstream client.
+type proxySession struct {
+	proxy   *Proxy
+	conn    *conn
+	closer  io.Closer
+	remote  string
+	account *ProxyAccount
+	subs    *subscriptions  // events and filters of the client
+	format  string          // plain, json or xml; guarded by subs.mu
+	queue   chan proxyFrame // encoded events
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// authTimeout limits the time for the downstream client to authenticate.
+const proxyAuthTimeout = 10 * time.Second
+
+// auth requests the password and checks it against the accounts.
+func (s *proxySession) auth() bool {
+	if c, ok := s.closer.(net.Conn); ok {
+		c.SetReadDeadline(time.Now().Add(proxyAuthTimeout)) //nolint:errcheck
+		defer c.SetReadDeadline(time.Time{})                //nolint:errcheck
+	}
+
+	if s.conn.writeFrame("auth/request", nil, nil) != nil {
+		return false
+	}
+
+	for {
+		cmd, err := s.conn.readCommand()
+		if err != nil {
+			return false
+		}
+
+		switch cmd.name {
+		case "auth":
+			s.account = s.proxy.findAccount("", cmd.params)
+		case "userauth":
+			name, password, _ := strings.Cut(cmd.params, ":")
+			s.account = s.proxy.findAccount(name, password)
+		case "exit":
+			s.reply("+OK bye")
+			s.disconnect()
+
+			return false
+		default:
+			s.reply("-ERR command not found")
+
+			continue
+		}
+
+		if s.account == nil {
+			s.proxy.audit.Warn("esl: proxy auth failed", slog.String("remote", s.remote))
+			s.reply("-ERR invalid")
+			s.disconnect()
+
+			return false
+		}
+
+		s.proxy.audit.Info("esl: proxy auth",
+			slog.String("account", s.account.Name),
+			slog.String("remote", s.remote))
+
+		return s.reply("+OK accepted") == nil
+	}
+}
+
+// findAccount returns the account with the password and the name, if it is
+// not empty.
+func (p *Proxy) findAccount(name, password string) *ProxyAccount {
+	for i, account := range p.accounts {
+		if name != "" && name != account.Name {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(account.Password), []byte(password)) == 1 {
+			return &p.accounts[i]
+		}
+	}
+
+	return nil
+}
+
+// serve executes the commands of the client until it disconnects.
+func (s *proxySession) serve() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		cmd, err := s.conn.readCommand()
+		if err != nil {
+			return
+		}
+
+		start := time.Now()
+		err = s.execute(ctx, cmd)
+
+		attrs := []any{
+			slog.String("account", s.account.Name),
+			slog.String("remote", s.remote),
+			slog.String("cmd", cmd.Line()),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("err", err.Error()))
+		}
+
+		s.proxy.audit.Info("esl: proxy command", attrs...)
+
+		if errors.Is(err, io.EOF) {
+			return // exit or connection problem
+		}
+	}
+}
+
+// errNotPermitted is returned for the commands denied by the allowlist.
+var errNotPermitted = errors.New("command not permitted")
+
+// errNotSupported is returned for the commands which can't be shared.
+var errNotSupported = errors.New("command not supported by proxy")
+
+// execute runs the command and replies to the client. It returns the error
+// of the command for the audit, or io.EOF to end the session.
+func (s *proxySession) execute(ctx context.Context, cmd command) error {
+	switch cmd.name {
+	case "api":
+		if !s.permitted(cmd.params) {
+			return s.replyErr(apiResponse, errNotPermitted)
+		}
+
+		return s.forward(ctx, cmd)
+
+	case "bgapi":
+		if !s.permitted(cmd.params) {
+			return s.replyErr(commandReply, errNotPermitted)
+		}
+
+		if cmd.jobUUID == "" {
+			cmd.jobUUID = newJobUUID()
+		}
+
+		// registered before sending, as the event may come before the reply
+		s.proxy.addJob(cmd.jobUUID, s)
+
+		resp, err := s.proxy.upstream.sendRecv(ctx, cmd)
+		if err != nil {
+			s.proxy.removeJob(cmd.jobUUID) // the job is not started
+		}
+
+		return s.respond(cmd, resp, err)
+
+	case "event":
+		return s.subscribe(cmd.params)
+
+	case "nixevent":
+		return s.unsubscribe(proxyNixNames(cmd.params)...)
+
+	case "noevents":
+		return s.unsubscribe()
+
+	case "filter":
+		return s.filter(cmd.params)
+
+	case "exit":
+		s.reply("+OK bye")
+		s.disconnect()
+
+		return io.EOF
+
+	default:
+		return s.replyErr(commandReply, errNotSupported)
+	}
+}
+
+// forward sends the command upstream and the response back to the client.
+func (s *proxySession) forward(ctx context.Context, cmd command) error {
+	resp, err := s.proxy.upstream.sendRecv(ctx, cmd)
+
+	return s.respond(cmd, resp, err)
+}
+
+// respond sends the upstream response of the command to the client, or the
+// error reply if there is no response.
+func (s *proxySession) respond(cmd command, resp Response, err error) error {
+	if resp.isZero() {
+		contentType := commandReply
+		if cmd.name == "api" {
+			contentType = apiResponse
+		}
+
+		if err == nil {
+			err = ErrNoReply
+		}
+
+		return errors.Join(err, s.replyErr(contentType, err))
+	}
+
+	if werr := s.conn.writeFrame(resp.ContentType(), resp.headers, resp.body); werr != nil {
+		return errors.Join(err, io.EOF)
+	}
+
+	return err
+}
+
+// subscribe handles the "event [<format>] <names>" command. Like FreeSWITCH,
+// the format may be given in any position and defaults to plain.
+func (s *proxySession) subscribe(params string) error {
+	var fields []string
+
+	format := "plain"
+
+	for _, field := range strings.Fields(params) {
+		switch lower := strings.ToLower(field); lower {
+		case "plain", "json", "xml":
+			format = lower
+		default:
+			fields = append(fields, field)
+		}
+	}
+
+	names := proxyEventNames(fields)
+
+	// the lock is not held while waiting for the upstream, which may be
+	// blocked by the events sent to this client
+	s.subs.mu.Lock()
+	saved := s.subs.save()
+	added := s.subs.subscribe(names...)
+	s.format = format
+	s.subs.mu.Unlock()
+
+	if len(added) > 0 {
+		if err := s.proxy.upstream.Subscribe(added...); err != nil {
+			s.subs.mu.Lock()
+			s.subs.restore(saved)
+			s.subs.mu.Unlock()
+
+			return errors.Join(err, s.replyErr(commandReply, err))
+		}
+	}
+
+	return s.reply("+OK event listener enabled " + format)
+}
+
+// unsubscribe handles the "nixevent" and "noevents" commands.
+func (s *proxySession) unsubscribe(names ...string) error {
+	var removed []string
+
+	s.subs.mu.Lock()
+
+	saved := s.subs.save()
+
+	if len(names) == 0 {
+		removed = s.subs.names()
+		clear(s.subs.events)
+	} else {
+		removed = s.subs.unsubscribe(proxyEventNames(names)...)
+	}
+
+	s.subs.mu.Unlock()
+
+	if len(removed) > 0 {
+		if err := s.proxy.upstream.Unsubscribe(removed...); err != nil {
+			s.subs.mu.Lock()
+			s.subs.restore(saved)
+			s.subs.mu.Unlock()
+
+			return errors.Join(err, s.replyErr(commandReply, err))
+		}
+	}
+
+	if len(names) == 0 {
+		return s.reply("+OK no longer listening for events")
+	}
+
+	return s.reply("+OK events nixed")
+}
+
+// filter handles the "filter <header> <value>" and "filter delete <header> [<value>]"
+// commands. The filters are applied on the proxy only, because the upstream
+// connection is shared.
+func (s *proxySession) filter(params string) error {
+	fields := strings.SplitN(params, " ", 3) //nolint:mnd
+
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+
+	switch {
+	case len(fields) >= 2 && fields[0] == "delete":
+		f := EventFilter{Header: fields[1], Value: ""}
+		if len(fields) == 3 { //nolint:mnd
+			f.Value = fields[2]
+		}
+
+		if !s.subs.filterDelete(f) {
+			return s.reply("-ERR filter not found.")
+		}
+
+		return s.reply("+OK filter deleted. [" + f.Header + "]=[" + f.Value + "]")
+
+	case len(fields) >= 2 && fields[0] != "delete":
+		f := EventFilter{Header: fields[0], Value: strings.Join(fields[1:], " ")}
+		s.subs.filter(f)
+
+		return s.reply("+OK filter added. [" + f.Header + "]=[" + f.Value + "]")
+
+	default:
+		return s.reply("-ERR invalid syntax")
+	}
+}
+
+// permitted reports whether the API command is allowed for the account.
+func (s *proxySession) permitted(command string) bool {
+	if len(s.account.Allow) == 0 {
+		return true
+	}
+
+	command = strings.Join(strings.Fields(command), " ")
+	for _, allowed := range s.account.Allow {
+		if command == allowed || strings.HasPrefix(command, allowed+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match reports whether the client is subscribed to the event and the event
+// passes its filters, the same way as FreeSWITCH does.
+// It must be called with subs.mu held.
+func (s *proxySession) match(e Event) bool {
+	events := s.subs.events
+
+	switch name := e.Get("Event-Name"); {
+	case events[eventAll] > 0:
+	case name == "CUSTOM":
+		subclass := e.Get("Event-Subclass")
+		if subclass == "" && events["CUSTOM"] == 0 || subclass != "" && events[subclass] == 0 {
+			return false
+		}
+	case events[name] == 0:
+		return false
+	}
+
+	if len(s.subs.filters) == 0 {
+		return true
+	}
+
+	for f := range s.subs.filters {
+		if e.Get(f.Header) == f.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// send queues the event for the client if it matches the subscriptions.
+// The cache keeps the event encoded in the formats used by the clients.
+func (s *proxySession) send(e Event, cache map[string]proxyFrame) {
+	s.subs.mu.Lock()
+	format := s.format
+	ok := s.match(e)
+	s.subs.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	frame, ok := cache[format]
+	if !ok {
+		frame = newProxyFrame(e, format)
+		if cache != nil {
+			cache[format] = frame
+		}
+	}
+
+	select {
+	case s.queue <- frame:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// writeEvents writes the queued events to the client until the session ends.
+func (s *proxySession) writeEvents() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case frame := <-s.queue:
+			if s.conn.writeFrame(frame.contentType, nil, frame.body) != nil {
+				s.closer.Close()
+
+				return
+			}
+		}
+	}
+}
+
+// proxyFrame is the event message encoded for the client.
+type proxyFrame struct {
+	contentType string
+	body        []byte
+}
+
+// newProxyFrame encodes the event in the format of the event command.
+func newProxyFrame(e Event, format string) proxyFrame {
+	switch format {
+	case "json":
+		body, _ := json.Marshal(e)
+
+		return proxyFrame{contentType: eventJSON, body: body}
+	case "xml":
+		body, _ := xml.Marshal(e)
+
+		return proxyFrame{contentType: eventXML, body: body}
+	default:
+		return proxyFrame{contentType: eventPlain, body: []byte(e.String())}
+	}
+}
+
+// reply sends the command reply to the client.
+func (s *proxySession) reply(text string) error {
+	return s.conn.writeFrame(commandReply, map[string]string{"Reply-Text": text}, nil)
+}
+
+// replyErr sends the error reply to the client in the format of the command
+// and returns the error for the audit.
+func (s *proxySession) replyErr(contentType string, err error) error {
+	text := "-ERR " + err.Error()
+
+	if contentType == apiResponse {
+		s.conn.writeFrame(apiResponse, nil, []byte(text+"\n")) //nolint:errcheck
+	} else {
+		s.reply(text) //nolint:errcheck
+	}
+
+	return err
+}
+
+// disconnect sends the disconnect notice to the client.
+func (s *proxySession) disconnect() {
+	s.conn.writeFrame(disconnectNotice, nil, []byte("Disconnected, goodbye.\n")) //nolint:errcheck
+}
+
+// proxyEventNames converts the names of the event command to the names of
+// the subscriptions: the names following CUSTOM are the subclass names.
+func proxyEventNames(fields []string) []string {
+	names := make([]string, 0, len(fields))
+	custom := false
+
+	for i, name := range fields {
+		switch {
+		case custom:
+			names = append(names, "CUSTOM "+name)
+		case name == "CUSTOM":
+			custom = true
+
+			if i == len(fields)-1 {
+				names = append(names, name) // custom events without subclass
+			}
+		default:
+			if upper := strings.ToUpper(name); upper != "CUSTOM" {
+				if _, ok := eventNames[upper]; ok || upper == "ALL" {
+					name = upper // native names are case-insensitive
+				}
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// proxyNixNames returns the event names of the nixevent command without the format.
+func proxyNixNames(params string) []string {
+	var names []string
+
+	for _, field := range strings.Fields(params) {
+		switch strings.ToLower(field) {
+		case "plain", "json", "xml":
+		default:
+			names = append(names, field)
+		}
+	}
+
+	return names
+}
+
+// newJobUUID returns a random UUID for the background job.
+func newJobUUID() string {
+	var b [16]byte
+
+	rand.Read(b[:]) //nolint:errcheck // never fails
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant
+
+	s := hex.EncodeToString(b[:])
+
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
@@ -0,0 +1,231 @@
+package esl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is the buffer safe for the concurrent writes of the logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestProxy(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		upstream []string // commands received by the server
+	)
+
+	srvReady := make(chan *fakeServer, 1)
+	served := make(chan struct{})
+	events := make(chan Event, 10)
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		defer close(served)
+
+		srvReady <- srv
+
+		for {
+			cmd := srv.recv()
+			if cmd == "" {
+				return
+			}
+
+			mu.Lock()
+			upstream = append(upstream, cmd)
+			mu.Unlock()
+
+			switch {
+			case cmd == "api status":
+				srv.send("Content-Type: api/response\nContent-Length: 2\n\nUP")
+			case strings.HasPrefix(cmd, "bgapi status\nJob-UUID: "):
+				id := strings.TrimPrefix(cmd, "bgapi status\nJob-UUID: ")
+				srv.send("Content-Type: command/reply\nReply-Text: +OK Job-UUID: " + id + "\nJob-UUID: " + id + "\n\n")
+				srv.sendEvent("Event-Name: BACKGROUND_JOB", "Job-UUID: "+id)
+			case strings.HasPrefix(cmd, "bgapi fail\n"):
+				srv.reply("-ERR fail")
+			default:
+				srv.reply("+OK")
+			}
+		}
+	}, WithEvents(events, true))
+
+	// the server must not write after the test is completed
+	t.Cleanup(func() {
+		client.Close()
+		<-served
+	})
+
+	srv := <-srvReady
+
+	var audit syncBuffer
+
+	proxy := NewProxy(client,
+		WithProxyAccount(ProxyAccount{Name: "monitor", Password: "secret", Allow: []string{"status"}}),
+		WithProxyAccount(ProxyAccount{Name: "admin", Password: "admin", Allow: nil}),
+		WithProxyAudit(slog.New(slog.NewTextHandler(&audit, nil))),
+	)
+	defer proxy.Close()
+
+	go Pipe(context.Background(), events, proxy) //nolint:errcheck
+
+	connect := func(password string, events chan Event) (*Client, error) {
+		down, up := net.Pipe()
+		go proxy.ServeConn(up)
+
+		return NewClient(down, password, WithEvents(events))
+	}
+
+	if _, err := connect("wrong", nil); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("unexpected auth error: %v", err)
+	}
+
+	monitorEvents, adminEvents := make(chan Event, 10), make(chan Event, 10)
+
+	monitor, err := connect("secret", monitorEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer monitor.Close()
+
+	admin, err := connect("admin", adminEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	if result, err := monitor.API("status"); err != nil || result != "UP" {
+		t.Errorf("unexpected api result: %q, %v", result, err)
+	}
+
+	if _, err := monitor.API("version"); err == nil || !strings.Contains(err.Error(), "not permitted") {
+		t.Errorf("command is not denied: %v", err)
+	}
+
+	if err := monitor.Subscribe("CHANNEL_ANSWER", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := admin.Subscribe("HEARTBEAT", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := admin.Filter("Event-Info", "System Ready"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.sendEvent("Event-Name: CHANNEL_ANSWER", "Unique-ID: call-1")
+	srv.sendEvent("Event-Name: HEARTBEAT", "Event-Info: Busy")
+	srv.sendEvent("Event-Name: HEARTBEAT", "Event-Info: System%20Ready")
+
+	if err := monitor.JobWithID("status", "job-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		events <-chan Event
+		name   string
+	}{
+		{monitorEvents, "CHANNEL_ANSWER"},
+		{monitorEvents, "BACKGROUND_JOB"},
+		{adminEvents, "HEARTBEAT"},
+	}
+
+	for _, w := range want {
+		select {
+		case e := <-w.events:
+			if e.Name() != w.name {
+				t.Errorf("unexpected event %s, want %s", e.Name(), w.name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", w.name)
+		}
+	}
+
+	select {
+	case e := <-adminEvents:
+		t.Errorf("unexpected event: %s", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := admin.JobWithID("fail", "job-2"); err == nil {
+		t.Error("expected job error")
+	}
+
+	proxy.mu.Lock()
+	_, ok := proxy.jobs["job-2"]
+	proxy.mu.Unlock()
+
+	if ok {
+		t.Error("failed job is not removed")
+	}
+
+	admin.Close()
+
+	// the monitor subscription to BACKGROUND_JOB is still active
+	deadline := time.Now().Add(time.Second)
+	for !slicesContainsPrefix(upstreamCommands(&mu, &upstream), "nixevent HEARTBEAT") {
+		if time.Now().After(deadline) {
+			t.Fatalf("admin subscriptions are not canceled: %q", upstreamCommands(&mu, &upstream))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, cmd := range upstreamCommands(&mu, &upstream) {
+		if strings.HasPrefix(cmd, "filter") || cmd == "api version" {
+			t.Errorf("unexpected upstream command: %q", cmd)
+		}
+	}
+
+	if log := audit.String(); !strings.Contains(log, "account=monitor") ||
+		!strings.Contains(log, `cmd="api version"`) || !strings.Contains(log, "not permitted") {
+		t.Errorf("unexpected audit log:\n%s", log)
+	}
+}
+
+func upstreamCommands(mu *sync.Mutex, cmds *[]string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return append([]string(nil), *cmds...)
+}
+
+func slicesContainsPrefix(list []string, prefix string) bool {
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestProxyEventNames(t *testing.T) {
+	got := strings.Join(proxyEventNames(strings.Fields("channel_create HEARTBEAT CUSTOM sofia::register")), ",")
+	if want := "CHANNEL_CREATE,HEARTBEAT,CUSTOM sofia::register"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -2,59 +2,81 @@ package esl
 
 import (
 	"bufio"
-	"bytes"
-	"errors"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 )
 
-type response struct {
-	contentType string // Content-Type
-	text        string // Reply-Text
-	jobUUID     string // Job-UUID
-	body        []byte // Body
+// Response represents a message received from the server in response to a
+// command, or a notification sent by the server itself.
+//
+// All headers of the message are preserved, except Content-Length, which is
+// defined by the length of the body.
+type Response struct {
+	headers map[string]string
+	body    []byte
 }
 
 // ContentType returns the content type of the response.
-func (r response) ContentType() string {
-	return r.contentType
+func (r Response) ContentType() string {
+	return r.Get("Content-Type")
 }
 
 // Text returns the text value of the response.
-func (r response) Text() string {
-	return r.text
+func (r Response) Text() string {
+	return r.Get("Reply-Text")
 }
 
 // JobUUID returns the job UUID of the response.
-func (r response) JobUUID() string {
-	return r.jobUUID
+func (r Response) JobUUID() string {
+	return r.Get("Job-UUID")
+}
+
+// Get returns the value associated with the given key from the response headers.
+func (r Response) Get(key string) string {
+	return r.headers[key]
+}
+
+// Header returns a copy of all response headers.
+func (r Response) Header() map[string]string {
+	if r.headers == nil {
+		return map[string]string{}
+	}
+
+	return maps.Clone(r.headers)
 }
 
 // ContentLength returns the length of the response body in bytes.
-func (r response) ContentLength() int {
+func (r Response) ContentLength() int {
 	return len(r.body)
 }
 
 // Body returns the body of the response as a string.
-func (r response) Body() string {
+func (r Response) Body() string {
 	return string(r.body)
 }
 
 // AsErr checks the content type of the response and returns an error if it matches a specific case.
-func (r response) AsErr() error {
-	switch r.contentType {
+//
+// The error reported by the server is returned as *ReplyError.
+func (r Response) AsErr() error {
+	switch r.ContentType() {
 	case disconnectNotice:
 		return io.EOF
 	case commandReply:
-		if strings.HasPrefix(r.text, "-ERR") {
-			return errors.New(r.text)
+		if text := r.Text(); isReplyError(text) {
+			return newReplyError(text)
 		}
-	case "api/response":
-		if bytes.HasPrefix(r.body, []byte("-ERR")) {
-			return errors.New(string(r.body))
+	case apiResponse:
+		const maxPrefix = len("-USAGE")
+		if isReplyError(string(r.body[:min(len(r.body), maxPrefix)])) {
+			return newReplyError(r.Body())
 		}
 	}
 
@@ -63,24 +85,32 @@ func (r response) AsErr() error {
 
 // WriteTo writes the response to the provided io.Writer.
 //
-// It writes the response headers to the writer, including the Content-Type,
-// Reply-Text, Job-UUID, and Content-Length if applicable. It then writes the
-// response body to the writer.
-func (r response) WriteTo(w io.Writer) (int64, error) {
+// It writes the Content-Type header first, followed by the rest of the
+// headers sorted by name, and the Content-Length if applicable. It then writes
+// the response body to the writer.
+func (r Response) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]string, 0, len(r.headers))
+
+	for k := range r.headers {
+		if k == "Content-Type" || strings.EqualFold(k, "Content-Length") {
+			continue // written separately
+		}
+
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
 	//nolint:errcheck // writing to buffer
 	return writeTo(w, func(buf *bufio.Writer) {
 		buf.WriteString("Content-Type: ")
-		buf.WriteString(r.contentType)
+		buf.WriteString(r.ContentType())
 
-		if r.text != "" {
+		for _, key := range keys {
 			buf.WriteByte('\n')
-			buf.WriteString("Reply-Text: ")
-			buf.WriteString(r.text)
-		}
-
-		if r.jobUUID != "" {
-			buf.WriteString("\nJob-UUID: ")
-			buf.WriteString(r.jobUUID)
+			buf.WriteString(key)
+			buf.WriteString(": ")
+			buf.WriteString(r.headers[key])
 		}
 
 		if length := len(r.body); length > 0 {
@@ -93,17 +123,17 @@ func (r response) WriteTo(w io.Writer) (int64, error) {
 }
 
 // String returns the string representation of the response.
-func (r response) String() string {
+func (r Response) String() string {
 	return writeStr(r)
 }
 
 // LogValue returns a slog.Value object that represents the log attributes for the response.
-func (r response) LogValue() slog.Value {
+func (r Response) LogValue() slog.Value {
 	attr := make([]slog.Attr, 0, 3)
-	attr = append(attr, slog.String("type", r.contentType))
+	attr = append(attr, slog.String("type", r.ContentType()))
 
-	if r.jobUUID != "" {
-		attr = append(attr, slog.String("job-uuid", r.jobUUID))
+	if jobUUID := r.JobUUID(); jobUUID != "" {
+		attr = append(attr, slog.String("job-uuid", jobUUID))
 	}
 
 	if err := r.AsErr(); err != nil {
@@ -116,18 +146,41 @@ func (r response) LogValue() slog.Value {
 }
 
 // isZero checks if the response is zero.
-func (r response) isZero() bool {
-	return r.contentType == ""
+func (r Response) isZero() bool {
+	return r.ContentType() == ""
 }
 
 // toEvent converts a response to an Event struct.
 //
-// It expects the response to have a content type of "text/event-plain".
+// It expects the response to have a content type of "text/event-plain",
+// "text/event-json" or "text/event-xml".
 // It returns an Event struct and an error if the content type is not supported.
-func (r response) toEvent() (Event, error) {
-	if ct := r.ContentType(); ct != eventPlain {
+// The plain events reference the response body, which must not be modified.
+// If pooled is set, the headers of the plain events are taken from the pool.
+func (r Response) toEvent(pooled bool) (Event, error) {
+	var (
+		event Event
+		err   error
+	)
+
+	switch ct := r.ContentType(); ct {
+	case eventPlain:
+		if pooled {
+			return parseEventPooled(r.body)
+		}
+
+		return parseEvent(r.body)
+	case eventJSON:
+		err = json.Unmarshal(r.body, &event)
+	case eventXML:
+		err = xml.Unmarshal(r.body, &event)
+	default:
 		return Event{}, fmt.Errorf("unsupported event content type: %s", ct)
 	}
 
-	return parseEvent(r.body)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	return event, nil
 }
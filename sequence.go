@@ -0,0 +1,178 @@
+package esl
+
+import (
+	"sync"
+)
+
+// AnomalyKind is a kind of the anomaly detected by SequenceMonitor.
+type AnomalyKind uint8
+
+// Kinds of the sequence anomalies.
+const (
+	SequenceGap       AnomalyKind = iota + 1 // some events were lost
+	SequenceDuplicate                        // the event with the same or lower sequence was received again
+	ServerRestart                            // the server was restarted: Core-UUID has changed
+)
+
+// String returns the name of the anomaly kind.
+func (k AnomalyKind) String() string {
+	switch k {
+	case SequenceGap:
+		return "gap"
+	case SequenceDuplicate:
+		return "duplicate"
+	case ServerRestart:
+		return "restart"
+	default:
+		return "unknown"
+	}
+}
+
+// SequenceAnomaly describes the anomaly detected in the sequence of events.
+type SequenceAnomaly struct {
+	Kind     AnomalyKind
+	CoreUUID string // Core-UUID of the event
+	Hostname string // FreeSWITCH-Hostname of the event
+	Expected int64  // the next expected sequence number
+	Got      int64  // the received sequence number
+	PrevCore string // Core-UUID before the restart
+}
+
+// Lost returns the number of the lost events for the gap.
+func (a SequenceAnomaly) Lost() int64 {
+	if a.Kind != SequenceGap {
+		return 0
+	}
+
+	return a.Got - a.Expected
+}
+
+// SequenceStats contains the counters of SequenceMonitor.
+type SequenceStats struct {
+	Events     uint64 // number of the observed events with a sequence
+	Gaps       uint64 // number of the detected gaps
+	Lost       uint64 // total number of the lost events
+	Duplicates uint64 // number of the duplicated or reordered events
+	Restarts   uint64 // number of the detected server restarts
+}
+
+// SequenceMonitor tracks the Event-Sequence of the received events to detect
+// the lost and duplicated events, e.g. after a slow consumer stall, and the
+// server restarts.
+//
+// The last sequence is tracked per Core-UUID. A restart is reported when the
+// Core-UUID for the same FreeSWITCH-Hostname changes.
+//
+// FreeSWITCH numbers all events it fires, so the gaps are meaningful only
+// when the connection is subscribed to all events without server-side filters.
+// Otherwise, use only the duplicate and restart reports.
+type SequenceMonitor struct {
+	mu      sync.Mutex
+	last    map[string]int64  // the last sequence per Core-UUID
+	cores   map[string]string // Core-UUID per FreeSWITCH-Hostname
+	stats   SequenceStats
+	handler func(SequenceAnomaly)
+}
+
+// NewSequenceMonitor returns a new SequenceMonitor, which calls the handler
+// for each detected anomaly. The handler may be nil if only the counters are used.
+//
+// The handler is called synchronously from the goroutine observing the events,
+// so it should not block.
+func NewSequenceMonitor(handler func(SequenceAnomaly)) *SequenceMonitor {
+	return &SequenceMonitor{
+		mu:      sync.Mutex{},
+		last:    make(map[string]int64),
+		cores:   make(map[string]string),
+		stats:   SequenceStats{}, //nolint:exhaustruct
+		handler: handler,
+	}
+}
+
+// Observe checks the sequence of the event. The events without Event-Sequence
+// or Core-UUID headers are ignored.
+func (m *SequenceMonitor) Observe(e Event) {
+	seq, core := e.Sequence(), e.Get("Core-UUID")
+	if seq == 0 || core == "" {
+		return
+	}
+
+	host := e.Get("FreeSWITCH-Hostname")
+	anomalies := m.observe(seq, core, host)
+
+	if m.handler != nil {
+		for _, a := range anomalies {
+			m.handler(a)
+		}
+	}
+}
+
+// observe updates the state and returns the detected anomalies.
+func (m *SequenceMonitor) observe(seq int64, core, host string) []SequenceAnomaly {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Events++
+
+	var anomalies []SequenceAnomaly
+
+	if prev, ok := m.cores[host]; ok && prev != core {
+		m.stats.Restarts++
+
+		delete(m.last, prev)
+
+		anomalies = append(anomalies, SequenceAnomaly{
+			Kind: ServerRestart, CoreUUID: core, Hostname: host,
+			Expected: 0, Got: seq, PrevCore: prev,
+		})
+	}
+
+	m.cores[host] = core
+
+	last, ok := m.last[core]
+	if !ok {
+		m.last[core] = seq // first event from the server
+
+		return anomalies
+	}
+
+	anomaly := SequenceAnomaly{
+		Kind: 0, CoreUUID: core, Hostname: host,
+		Expected: last + 1, Got: seq, PrevCore: "",
+	}
+
+	switch {
+	case seq == last+1: // OK
+		m.last[core] = seq
+
+		return anomalies
+	case seq > last+1:
+		m.last[core] = seq
+		m.stats.Gaps++
+		m.stats.Lost += uint64(seq - last - 1)
+		anomaly.Kind = SequenceGap
+	default:
+		m.stats.Duplicates++
+		anomaly.Kind = SequenceDuplicate
+	}
+
+	return append(anomalies, anomaly)
+}
+
+// Stats returns the snapshot of the counters.
+func (m *SequenceMonitor) Stats() SequenceStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stats
+}
+
+// Reset forgets the last sequences, e.g. after reconnecting or changing the
+// subscriptions, so that the expected gaps are not reported. The counters are kept.
+func (m *SequenceMonitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.last)
+	clear(m.cores)
+}
@@ -0,0 +1,52 @@
+package esl
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestSequenceMonitor(t *testing.T) {
+	var got []string
+
+	m := NewSequenceMonitor(func(a SequenceAnomaly) {
+		got = append(got, a.Kind.String()+":"+strconv.FormatInt(a.Got, 10))
+	})
+
+	event := func(core string, seq int) Event {
+		return NewEvent("HEARTBEAT", map[string]string{
+			"Core-UUID":           core,
+			"FreeSWITCH-Hostname": "fs1",
+			"Event-Sequence":      strconv.Itoa(seq),
+		}, nil)
+	}
+
+	for _, e := range []Event{
+		event("core-1", 10),
+		event("core-1", 11),
+		event("core-1", 15), // lost 12, 13, 14
+		event("core-1", 15), // duplicate
+		event("core-1", 16),
+		event("core-2", 1), // restart
+		event("core-2", 2),
+		NewEvent("HEARTBEAT", nil, nil), // ignored
+	} {
+		m.Observe(e)
+	}
+
+	if want := []string{"gap:15", "duplicate:15", "restart:1"}; !slices.Equal(got, want) {
+		t.Errorf("anomalies: %v, want %v", got, want)
+	}
+
+	want := SequenceStats{Events: 7, Gaps: 1, Lost: 3, Duplicates: 1, Restarts: 1}
+	if stats := m.Stats(); stats != want {
+		t.Errorf("stats: %+v, want %+v", stats, want)
+	}
+
+	m.Reset()
+	m.Observe(event("core-2", 100))
+
+	if len(got) != 3 {
+		t.Errorf("anomaly after reset: %v", got)
+	}
+}
@@ -0,0 +1,287 @@
+package esl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APICaller sends the API commands, implemented by Client, Pool and Cluster.
+type APICaller interface {
+	APIContext(ctx context.Context, command string) (string, error)
+}
+
+// ErrShowFormat is returned when the output of the show command can't be parsed.
+var ErrShowFormat = errors.New("bad show output")
+
+// showDelim is the delimiter of the columns of the delimited show output: the
+// ASCII unit separator, which does not appear in the values, unlike "|" or ","
+// used in the dial strings and the application data.
+const showDelim = "\x1f"
+
+// Show sends the "show <what> as json" command and decodes the rows into the
+// structs of type T with the json tags named as the columns. If the JSON
+// output is not supported, the delimited format is requested and decoded the
+// same way.
+//
+// The number of the returned rows is the row count reported by FreeSWITCH:
+// the output with the count not matching the rows is rejected.
+//
+//	rows, err := esl.Show[esl.Channel](ctx, client, "channels like 1000")
+func Show[T any](ctx context.Context, api APICaller, what string) ([]T, error) {
+	rows, err := showRows(ctx, api, what)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]T, len(rows))
+
+	for i, row := range rows {
+		data, err := json.Marshal(row)
+		if err == nil {
+			err = json.Unmarshal(data, &result[i])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrShowFormat, err)
+		}
+	}
+
+	return result, nil
+}
+
+// showRows returns the rows of the show command as the maps of the columns.
+func showRows(ctx context.Context, api APICaller, what string) ([]map[string]string, error) {
+	result, err := api.APIContext(ctx, "show "+what+" as json")
+	if err != nil && !errors.Is(err, ErrUnknownCommand) && !errors.Is(err, ErrInvalidArgs) {
+		return nil, err
+	}
+
+	if err == nil {
+		if rows, err := parseShowJSON(result); err == nil {
+			return rows, nil
+		}
+	}
+
+	result, err = api.APIContext(ctx, "show "+what+" as delim "+showDelim)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseShowDelim(result, showDelim)
+}
+
+// parseShowJSON parses the JSON show output. The empty result has no rows:
+// {"row_count":0}.
+func parseShowJSON(s string) ([]map[string]string, error) {
+	var output struct {
+		RowCount int                 `json:"row_count"`
+		Rows     []map[string]string `json:"rows"`
+	}
+
+	if err := json.Unmarshal([]byte(s), &output); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrShowFormat, err)
+	}
+
+	if output.RowCount != len(output.Rows) {
+		return nil, fmt.Errorf("%w: %d rows of %d", ErrShowFormat, len(output.Rows), output.RowCount)
+	}
+
+	return output.Rows, nil
+}
+
+// parseShowDelim parses the delimited show output: the line of the column
+// names, the rows and the total line, e.g. "2 total.".
+func parseShowDelim(s, delim string) ([]map[string]string, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, fmt.Errorf("%w: empty output", ErrShowFormat)
+	}
+
+	total, ok := parseTotal(lines[len(lines)-1])
+	if !ok {
+		return nil, fmt.Errorf("%w: no total", ErrShowFormat)
+	}
+
+	columns := strings.Split(lines[0], delim)
+	rows := make([]map[string]string, 0, total)
+
+	for _, line := range lines[1 : len(lines)-1] {
+		if line == "" {
+			continue
+		}
+
+		values := strings.Split(line, delim)
+		if len(values) != len(columns) {
+			return nil, fmt.Errorf("%w: %d columns of %d", ErrShowFormat, len(values), len(columns))
+		}
+
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) != total {
+		return nil, fmt.Errorf("%w: %d rows of %d", ErrShowFormat, len(rows), total)
+	}
+
+	return rows, nil
+}
+
+// parseTotal parses the total line of the show output, e.g. "2 total.".
+func parseTotal(s string) (int, bool) {
+	count, ok := strings.CutSuffix(strings.TrimSpace(s), " total.")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(count)
+
+	return n, err == nil
+}
+
+// Channel is the row of the "show channels" output.
+type Channel struct {
+	UUID            string `json:"uuid"`
+	Direction       string `json:"direction"`
+	Created         string `json:"created"`
+	CreatedEpoch    string `json:"created_epoch"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	CIDName         string `json:"cid_name"`
+	CIDNum          string `json:"cid_num"`
+	IPAddr          string `json:"ip_addr"`
+	Dest            string `json:"dest"`
+	Application     string `json:"application"`
+	ApplicationData string `json:"application_data"`
+	Dialplan        string `json:"dialplan"`
+	Context         string `json:"context"`
+	ReadCodec       string `json:"read_codec"`
+	ReadRate        string `json:"read_rate"`
+	WriteCodec      string `json:"write_codec"`
+	WriteRate       string `json:"write_rate"`
+	Secure          string `json:"secure"`
+	Hostname        string `json:"hostname"`
+	PresenceID      string `json:"presence_id"`
+	PresenceData    string `json:"presence_data"`
+	AccountCode     string `json:"accountcode"`
+	CallState       string `json:"callstate"`
+	CalleeName      string `json:"callee_name"`
+	CalleeNum       string `json:"callee_num"`
+	CalleeDirection string `json:"callee_direction"`
+	CallUUID        string `json:"call_uuid"`
+}
+
+// CreatedAt returns the time the channel was created.
+func (c Channel) CreatedAt() time.Time {
+	return parseEpoch(c.CreatedEpoch)
+}
+
+// Call is the row of the "show calls" output: the A leg channel with the
+// columns of the B leg.
+type Call struct {
+	Channel
+
+	BUUID         string `json:"b_uuid"`
+	BDirection    string `json:"b_direction"`
+	BCreated      string `json:"b_created"`
+	BCreatedEpoch string `json:"b_created_epoch"`
+	BName         string `json:"b_name"`
+	BState        string `json:"b_state"`
+	BCIDName      string `json:"b_cid_name"`
+	BCIDNum       string `json:"b_cid_num"`
+	BIPAddr       string `json:"b_ip_addr"`
+	BDest         string `json:"b_dest"`
+	BCallState    string `json:"b_callstate"`
+	CallCreated   string `json:"call_created_epoch"`
+}
+
+// Registration is the row of the "show registrations" output.
+type Registration struct {
+	User         string `json:"reg_user"`
+	Realm        string `json:"realm"`
+	Token        string `json:"token"`
+	URL          string `json:"url"`
+	Expires      string `json:"expires"`
+	NetworkIP    string `json:"network_ip"`
+	NetworkPort  string `json:"network_port"`
+	NetworkProto string `json:"network_proto"`
+	Hostname     string `json:"hostname"`
+	Metadata     string `json:"metadata"`
+}
+
+// ExpiresAt returns the time the registration expires.
+func (r Registration) ExpiresAt() time.Time {
+	return parseEpoch(r.Expires)
+}
+
+// Module is the row of the "show modules" output.
+type Module struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	IKey     string `json:"ikey"`
+	Filename string `json:"filename"`
+}
+
+// Codec is the row of the "show codecs" output.
+type Codec struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	IKey string `json:"ikey"`
+}
+
+// Interface is the row of the "show interfaces" output.
+type Interface struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	IKey     string `json:"ikey"`
+	Filename string `json:"filename"`
+}
+
+// Channels returns the active channels.
+func Channels(ctx context.Context, api APICaller) ([]Channel, error) {
+	return Show[Channel](ctx, api, "channels")
+}
+
+// Calls returns the active calls.
+func Calls(ctx context.Context, api APICaller) ([]Call, error) {
+	return Show[Call](ctx, api, "calls")
+}
+
+// Registrations returns the SIP registrations.
+func Registrations(ctx context.Context, api APICaller) ([]Registration, error) {
+	return Show[Registration](ctx, api, "registrations")
+}
+
+// Modules returns the loaded modules.
+func Modules(ctx context.Context, api APICaller) ([]Module, error) {
+	return Show[Module](ctx, api, "modules")
+}
+
+// Codecs returns the available codecs.
+func Codecs(ctx context.Context, api APICaller) ([]Codec, error) {
+	return Show[Codec](ctx, api, "codecs")
+}
+
+// Interfaces returns the interfaces of the loaded modules.
+func Interfaces(ctx context.Context, api APICaller) ([]Interface, error) {
+	return Show[Interface](ctx, api, "interfaces")
+}
+
+// parseEpoch parses the Unix time in seconds, returning the zero time if it
+// is empty or invalid.
+func parseEpoch(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0)
+}
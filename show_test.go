@@ -0,0 +1,96 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAPI replies to the API commands with the prepared results.
+type fakeAPI map[string]string
+
+func (f fakeAPI) APIContext(_ context.Context, command string) (string, error) {
+	result, ok := f[command]
+	if !ok {
+		return "", newReplyError("-ERR " + command + " Command not found!")
+	}
+
+	if isReplyError(result) {
+		return "", newReplyError(result)
+	}
+
+	return result, nil
+}
+
+// delimited returns the delimited show output with ";" replaced by showDelim.
+func delimited(s string) string {
+	return strings.ReplaceAll(s, ";", showDelim)
+}
+
+func TestShow(t *testing.T) {
+	api := fakeAPI{
+		"show channels as json": `{"row_count":1,"rows":[{"uuid":"call-1","direction":"inbound",` +
+			`"created_epoch":"1700000000","name":"sofia/internal/1000@example.com","state":"CS_EXECUTE",` +
+			`"cid_num":"1000","dest":"1001","read_codec":"PCMU","presence_data":null}]}`,
+		"show calls as json":         `{"row_count":0}`,
+		"show registrations as json": "-USAGE: show registrations",
+		"show registrations as delim " + showDelim: delimited("reg_user;realm;token;url;expires;network_ip;network_port;network_proto;hostname;metadata\n" +
+			"1000;example.com;abc;sofia/internal/sip:1000@10.0.0.2:5060;1700003600;10.0.0.2;5060;udp;fs1;a|b\n\n1 total.\n"),
+		"show modules as json":               `{"row_count":2,"rows":[{"type":"api","name":"status","ikey":"mod_commands","filename":"/usr/lib/mod_commands.so"}]}`,
+		"show modules as delim " + showDelim: delimited("type;name;ikey;filename\n\n0 total.\n"),
+		"show codecs as json":                `{"row_count":1,"rows":[{"type":"codec","name":"G.711 ulaw","ikey":"CORE_PCM_MODULE"}]}`,
+	}
+	ctx := context.Background()
+
+	channels, err := Channels(ctx, api)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channels) != 1 || channels[0].UUID != "call-1" || channels[0].ReadCodec != "PCMU" ||
+		!channels[0].CreatedAt().Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("unexpected channels: %+v", channels)
+	}
+
+	if calls, err := Calls(ctx, api); err != nil || calls == nil || len(calls) != 0 {
+		t.Errorf("unexpected calls: %+v, %v", calls, err)
+	}
+
+	registrations, err := Registrations(ctx, api)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(registrations) != 1 || registrations[0].User != "1000" || registrations[0].NetworkProto != "udp" ||
+		registrations[0].ExpiresAt().Unix() != 1700003600 || registrations[0].Metadata != "a|b" {
+		t.Errorf("unexpected registrations: %+v", registrations)
+	}
+
+	// the row count does not match the rows: fall back to the delimited output
+	if modules, err := Modules(ctx, api); err != nil || len(modules) != 0 {
+		t.Errorf("unexpected modules: %+v, %v", modules, err)
+	}
+
+	if codecs, err := Codecs(ctx, api); err != nil || len(codecs) != 1 || codecs[0].Name != "G.711 ulaw" {
+		t.Errorf("unexpected codecs: %+v, %v", codecs, err)
+	}
+
+	if _, err := Interfaces(ctx, api); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseShowDelim(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"type|name\ncodec|PCMU\n",
+		"type|name\ncodec|PCMU|extra\n\n1 total.\n",
+		"type|name\n\n1 total.\n",
+	} {
+		if _, err := parseShowDelim(s, "|"); !errors.Is(err, ErrShowFormat) {
+			t.Errorf("%q: unexpected error: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package esl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// EventSink is the destination of the events, such as a file, an HTTP
+// endpoint or a syslog server.
+//
+// WriteEvent must not keep the event after it returns: the event may be
+// released to the pool, see WithEventPool.
+type EventSink interface {
+	WriteEvent(ctx context.Context, e Event) error
+	Close() error
+}
+
+// Pipe writes all events from the channel to the sinks one by one, until the
+// channel is closed or the context is canceled. The sinks are not closed.
+//
+// The next event is not read from the channel until all sinks accept the
+// current one, so a slow sink slows down the client, which in turn stops
+// reading the events from the server. To pipe the events of the client, pass
+// the channel set with WithEvents with autoClose:
+//
+//	events := make(chan esl.Event, 100)
+//	client, err := esl.Connect(addr, password, esl.WithEvents(events, true))
+//	...
+//	err = esl.Pipe(ctx, events, esl.NewWriterSink(os.Stdout, esl.FormatJSON))
+//
+// It returns nil when the channel is closed, the context error or the first
+// error returned by a sink. The events are released after writing.
+func Pipe(ctx context.Context, events <-chan Event, sinks ...EventSink) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			for _, sink := range sinks {
+				if err := sink.WriteEvent(ctx, event); err != nil {
+					return fmt.Errorf("sink %T: %w", sink, err)
+				}
+			}
+
+			event.Release()
+		}
+	}
+}
+
+// EventFormat is the format the events are written by the sinks.
+type EventFormat string
+
+// Supported event formats, the same as used for the event subscription.
+const (
+	FormatPlain EventFormat = "plain" // headers and body, separated by an empty line
+	FormatJSON  EventFormat = "json"  // one JSON object per line
+	FormatXML   EventFormat = "xml"   // one XML document per line
+)
+
+// WriterSink writes the events to the io.Writer in the given format.
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format EventFormat
+}
+
+// NewWriterSink returns the sink writing the events to w, such as os.Stdout.
+// The unknown format is treated as FormatPlain.
+func NewWriterSink(w io.Writer, format EventFormat) *WriterSink {
+	return &WriterSink{
+		mu:     sync.Mutex{},
+		w:      w,
+		format: format,
+	}
+}
+
+// WriteEvent writes the event in the format of the sink.
+func (s *WriterSink) WriteEvent(_ context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := writeTo(s.w, func(buf *bufio.Writer) {
+		switch s.format {
+		case FormatJSON:
+			json.NewEncoder(buf).Encode(e) //nolint:errcheck,errchkjson // writing to buffer
+		case FormatXML:
+			xml.NewEncoder(buf).Encode(e) //nolint:errcheck // writing to buffer
+			buf.WriteByte('\n')
+		default:
+			e.WriteTo(buf) //nolint:errcheck // writing to buffer
+			buf.WriteByte('\n')
+		}
+	})
+
+	return err
+}
+
+// Close closes the underlying writer if it implements io.Closer.
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close() //nolint:wrapcheck
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package esl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// RotatingFile is the file for appending, which is rotated when it grows over
+// the maximum size.
+//
+// On rotation, the current file is renamed to path.1, the previous path.1 to
+// path.2 and so on, keeping at most maxBackups old files. The file is rotated
+// before the write that would exceed the maximum size, so each written chunk,
+// such as an event, is kept in one file.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	closed     bool
+}
+
+// OpenRotatingFile opens the file for appending, creating it if necessary.
+// If maxSize is not positive, the file is never rotated.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		mu:         sync.Mutex{},
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: max(maxBackups, 0),
+		file:       nil,
+		size:       0,
+		closed:     false,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write writes the data to the file, rotating it if needed.
+//
+// If the file can't be rotated, the data is appended to the current file and
+// the rotation error is returned; the rotation is retried on the next write.
+func (f *RotatingFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	if f.file == nil { // failed to reopen on the previous rotation
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	var rotateErr error
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if rotateErr = f.rotate(); f.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+
+	return n, errors.Join(rotateErr, err)
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err //nolint:wrapcheck
+}
+
+// open opens the file for appending.
+func (f *RotatingFile) open() error {
+	//nolint:mnd // rw-r--r--
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts the backup files, moves the current file to the first backup
+// and opens the new one. If the files can't be moved, the current file is
+// reopened to keep writing to it.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate file: %w", err)
+	}
+
+	f.file = nil
+
+	if err := f.shift(); err != nil {
+		return errors.Join(fmt.Errorf("failed to rotate file: %w", err), f.open())
+	}
+
+	return f.open()
+}
+
+// shift removes the current file, or moves it to the first backup after
+// shifting the older backups.
+func (f *RotatingFile) shift() error {
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err //nolint:wrapcheck
+		}
+
+		return nil
+	}
+
+	for i := f.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(f.backup(i), f.backup(i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return os.Rename(f.path, f.backup(1)) //nolint:wrapcheck
+}
+
+// backup returns the name of the n-th backup file.
+func (f *RotatingFile) backup(n int) string {
+	return f.path + "." + strconv.Itoa(n)
+}
+
+// FileSink writes the events to the RotatingFile in the JSON Lines format.
+type FileSink struct {
+	file *RotatingFile
+}
+
+// NewFileSink opens the file for appending the events, see OpenRotatingFile.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	file, err := OpenRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+// WriteEvent writes the event as a JSON line, rotating the file if needed.
+func (s *FileSink) WriteEvent(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = s.file.Write(append(data, '\n'))
+
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,161 @@
+package esl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog severities used for the events.
+const (
+	syslogWarning = 4
+	syslogInfo    = 6
+)
+
+// SyslogSink sends the events to the syslog server in the RFC 5424 format.
+//
+// The event name is used as MSGID, the main event headers are sent as the
+// structured data and the message is the event in the JSON format. Over the
+// stream connections, the messages are framed with the octet counting as
+// described in RFC 6587.
+type SyslogSink struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	stream   bool
+	facility int
+	app      string
+	hostname string
+}
+
+// NewSyslogSink connects to the syslog server over the network ("udp", "tcp"
+// or "unix") with the application name and the facility code, such as 16 for
+// local0.
+func NewSyslogSink(network, addr, app string, facility int) (*SyslogSink, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &SyslogSink{
+		mu:       sync.Mutex{},
+		conn:     conn,
+		stream:   !strings.HasPrefix(network, "udp") && network != "unixgram",
+		facility: facility,
+		app:      syslogName(app, 48),       //nolint:mnd // RFC 5424 limit
+		hostname: syslogName(hostname, 255), //nolint:mnd // RFC 5424 limit
+	}, nil
+}
+
+// WriteEvent sends the event as a syslog message.
+func (s *SyslogSink) WriteEvent(ctx context.Context, e Event) error {
+	msg, err := s.format(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)          //nolint:errcheck
+		defer s.conn.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	}
+
+	if s.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_, err = s.conn.Write(msg)
+
+	return err //nolint:wrapcheck
+}
+
+// Close closes the connection to the syslog server.
+func (s *SyslogSink) Close() error {
+	return s.conn.Close() //nolint:wrapcheck
+}
+
+// format returns the event as the RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *SyslogSink) format(e Event) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	severity := syslogInfo
+	if cause, ok := e.HangupCause(); ok && cause.Class() != ClassNormal {
+		severity = syslogWarning
+	}
+
+	ts := e.Timestamp()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	hostname := syslogName(e.Get("FreeSWITCH-Hostname"), 255) //nolint:mnd // RFC 5424 limit
+	if hostname == "-" {
+		hostname = s.hostname
+	}
+
+	var buf strings.Builder
+
+	w := bufio.NewWriter(&buf)
+	fmt.Fprintf(w, "<%d>1 %s %s %s %d %s ",
+		s.facility*8+severity, //nolint:mnd // RFC 5424 priority
+		ts.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		hostname, s.app, os.Getpid(),
+		syslogName(e.Name(), 32)) //nolint:mnd // RFC 5424 limit
+
+	sd := [...][2]string{
+		{"core", e.Get("Core-UUID")},
+		{"seq", e.Get("Event-Sequence")},
+		{"uuid", e.Get("Unique-ID")},
+		{"job", e.Get("Job-UUID")},
+	}
+
+	w.WriteString("[esl@32473")
+
+	for _, p := range sd {
+		if p[1] != "" {
+			w.WriteString(" " + p[0] + `="` + syslogParamEscaper.Replace(p[1]) + `"`)
+		}
+	}
+
+	w.WriteString("] ")
+	w.Write(data)
+	w.Flush()
+
+	return []byte(buf.String()), nil
+}
+
+// syslogParamEscaper escapes the values of the structured data parameters.
+var syslogParamEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`) //nolint:gochecknoglobals
+
+// syslogName returns the header field value of printable US-ASCII characters
+// limited to the given length, or "-" if it is empty.
+func syslogName(s string, limit int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+
+		return r
+	}, s)
+
+	if s == "" {
+		return "-"
+	}
+
+	return s[:min(len(s), limit)]
+}
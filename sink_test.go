@@ -0,0 +1,282 @@
+package esl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSinkEvent(seq string) Event {
+	return NewEvent("CHANNEL_HANGUP", map[string]string{
+		"Core-UUID":           "core-1",
+		"FreeSWITCH-Hostname": "fs1",
+		"Event-Sequence":      seq,
+		"Unique-ID":           "call-1",
+		"Hangup-Cause":        "USER_BUSY",
+	}, nil)
+}
+
+func TestPipe(t *testing.T) {
+	var buf bytes.Buffer
+
+	events := make(chan Event, 3)
+	events <- testSinkEvent("1")
+	events <- testSinkEvent("2")
+	close(events)
+
+	if err := Pipe(context.Background(), events, NewWriterSink(&buf, FormatJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Sequence() != 2 {
+		t.Errorf("unexpected event: %s", event)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Pipe(ctx, make(chan Event)); err == nil {
+		t.Error("canceled pipe returned no error")
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	for _, format := range []EventFormat{FormatPlain, FormatXML} {
+		var buf bytes.Buffer
+
+		if err := NewWriterSink(&buf, format).WriteEvent(context.Background(), testSinkEvent("1")); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(buf.String(), "CHANNEL_HANGUP") {
+			t.Errorf("%s: unexpected output: %q", format, buf.String())
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	sink, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 10 {
+		if err := sink.WriteEvent(context.Background(), testSinkEvent("1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(data) == 0 || len(data) > 200 {
+			t.Errorf("%s: unexpected size %d", name, len(data))
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("too many backups")
+	}
+}
+
+func TestRotatingFileRotateError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	// the backup can't be replaced by the current file
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write([]byte("first line\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := file.Write([]byte("second line\n")); err == nil || n == 0 {
+		t.Errorf("unexpected write result: %d, %v", n, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "first line\nsecond line\n" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]Event
+		calls   int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // retried
+
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		var batch []Event
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+
+		batches = append(batches, batch)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, 2, time.Hour)
+	sink.RetryDelay = time.Millisecond
+	sink.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	for _, seq := range []string{"1", "2", "3"} {
+		if err := sink.WriteEvent(context.Background(), testSinkEvent(seq)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+
+	if batches[1][0].Sequence() != 3 {
+		t.Errorf("unexpected event: %s", batches[1][0])
+	}
+
+	if err := sink.WriteEvent(context.Background(), testSinkEvent("4")); err == nil {
+		t.Error("write to closed sink")
+	}
+}
+
+func TestWebhookSinkInterval(t *testing.T) {
+	received := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, 100, 10*time.Millisecond)
+	defer sink.Close()
+
+	if err := sink.WriteEvent(context.Background(), testSinkEvent("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Error("batch is not flushed by interval")
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	sink, err := NewSyslogSink("tcp", ln.Addr().String(), "esl test", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := sink.WriteEvent(context.Background(), testSinkEvent("7")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatalf("bad octet count: %q", length)
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	line := string(msg)
+
+	// local0.warning for the busy hangup
+	if !strings.HasPrefix(line, "<132>1 ") {
+		t.Errorf("unexpected priority: %q", line)
+	}
+
+	for _, part := range []string{" fs1 esl_test ", " CHANNEL_HANGUP ", `[esl@32473 core="core-1" seq="7" uuid="call-1"] {`} {
+		if !strings.Contains(line, part) {
+			t.Errorf("missing %q in %q", part, line)
+		}
+	}
+}
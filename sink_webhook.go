@@ -0,0 +1,219 @@
+package esl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSinkClosed is returned when writing to the closed sink.
+var ErrSinkClosed = errors.New("sink closed")
+
+// WebhookSink posts the events to the HTTP endpoint in batches, as the JSON
+// array of the events.
+//
+// The batch is sent when it reaches the batch size, or when the flush interval
+// passes after the first event of the batch was written. The failed requests
+// are retried with the exponential backoff if the server is not available or
+// responds with 429 or 5xx status.
+//
+// The exported fields may be changed only before the first event is written.
+type WebhookSink struct {
+	Client     *http.Client  // http.DefaultClient if nil
+	Header     http.Header   // additional request headers, such as Authorization
+	Retries    int           // number of retries of the failed request
+	RetryDelay time.Duration // delay before the first retry, doubled for the next ones
+
+	url       string
+	batchSize int
+	interval  time.Duration
+
+	mu     sync.Mutex
+	batch  []json.RawMessage
+	timer  *time.Timer
+	err    error // error of the background flush
+	closed bool
+
+	send sync.Mutex // held while the batch is taken and posted to keep the order
+}
+
+// NewWebhookSink returns the sink posting the batches of events to the url.
+// If batchSize is less than 2, every event is sent immediately.
+func NewWebhookSink(url string, batchSize int, interval time.Duration) *WebhookSink {
+	return &WebhookSink{
+		Client:     nil,
+		Header:     nil,
+		Retries:    3,                      //nolint:mnd
+		RetryDelay: 500 * time.Millisecond, //nolint:mnd
+		url:        url,
+		batchSize:  max(batchSize, 1),
+		interval:   interval,
+		mu:         sync.Mutex{},
+		batch:      nil,
+		timer:      nil,
+		err:        nil,
+		closed:     false,
+		send:       sync.Mutex{},
+	}
+}
+
+// WriteEvent adds the event to the batch and sends the batch if it is full.
+// It returns the error of the previous background flush, if any.
+func (s *WebhookSink) WriteEvent(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	s.mu.Lock()
+
+	if s.closed {
+		s.mu.Unlock()
+
+		return ErrSinkClosed
+	}
+
+	if err := s.err; err != nil {
+		s.err = nil
+		s.mu.Unlock()
+
+		return err
+	}
+
+	s.batch = append(s.batch, data)
+
+	if len(s.batch) < s.batchSize {
+		if s.timer == nil && s.interval > 0 {
+			s.timer = time.AfterFunc(s.interval, s.flushAsync)
+		}
+
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	s.mu.Unlock()
+
+	return s.Flush(ctx)
+}
+
+// Flush sends the pending events immediately.
+func (s *WebhookSink) Flush(ctx context.Context) error {
+	s.send.Lock()
+	defer s.send.Unlock()
+
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+
+	return s.post(ctx, batch)
+}
+
+// Close sends the pending events and returns the error of the last flush.
+func (s *WebhookSink) Close() error {
+	s.send.Lock()
+	defer s.send.Unlock()
+
+	s.mu.Lock()
+	s.closed = true
+	batch := s.take()
+	err := s.err
+	s.err = nil
+	s.mu.Unlock()
+
+	return errors.Join(err, s.post(context.Background(), batch))
+}
+
+// take returns the current batch and stops the flush timer.
+// It must be called with the lock held.
+func (s *WebhookSink) take() []json.RawMessage {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	batch := s.batch
+	s.batch = nil
+
+	return batch
+}
+
+// flushAsync sends the batch by the timer and keeps the error for the next write.
+func (s *WebhookSink) flushAsync() {
+	if err := s.Flush(context.Background()); err != nil {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+	}
+}
+
+// post sends the batch to the server, retrying on the temporary errors.
+// It must be called with the send lock held.
+func (s *WebhookSink) post(ctx context.Context, batch []json.RawMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	delay := s.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.do(ctx, body)
+		if err == nil || !retry || attempt >= s.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+// do sends the request and reports whether it can be retried on error.
+func (s *WebhookSink) do(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("webhook: %w", err)
+	}
+
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("webhook: %w", err)
+	}
+
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck // drain to reuse the connection
+	resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code < http.StatusMultipleChoices:
+		return false, nil
+	case code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+		return true, fmt.Errorf("webhook: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook: %s", resp.Status)
+	}
+}
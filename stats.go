@@ -0,0 +1,62 @@
+package esl
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ClientStats contains the counters of the client activity since it was
+// connected, see Client.Stats.
+type ClientStats struct {
+	Commands       uint64        // number of commands sent to the server
+	CommandErrors  uint64        // number of commands failed or replied with an error
+	CommandLatency time.Duration // total time spent waiting for the replies
+	Events         uint64        // number of events received, including the filtered out
+	EventsFiltered uint64        // number of events dropped by the client-side filter
+	EventsInvalid  uint64        // number of events dropped because of the parsing errors
+	EventLag       time.Duration // delay of the last event since it was fired by the server
+	LogLines       uint64        // number of log lines received
+}
+
+// clientStats is the concurrent-safe storage of the client counters.
+type clientStats struct {
+	commands      atomic.Uint64
+	commandErrors atomic.Uint64
+	latency       atomic.Int64
+	events        atomic.Uint64
+	filtered      atomic.Uint64
+	invalid       atomic.Uint64
+	lag           atomic.Int64
+	logLines      atomic.Uint64
+}
+
+// command counts the command with its reply latency.
+func (s *clientStats) command(latency time.Duration, err error) {
+	s.commands.Add(1)
+	s.latency.Add(int64(latency))
+
+	if err != nil {
+		s.commandErrors.Add(1)
+	}
+}
+
+// event records the lag of the received event, if it has the timestamp.
+func (s *clientStats) event(e Event) {
+	if ts := e.Timestamp(); !ts.IsZero() {
+		s.lag.Store(int64(time.Since(ts)))
+	}
+}
+
+// snapshot returns the current values of the counters.
+func (s *clientStats) snapshot() ClientStats {
+	return ClientStats{
+		Commands:       s.commands.Load(),
+		CommandErrors:  s.commandErrors.Load(),
+		CommandLatency: time.Duration(s.latency.Load()),
+		Events:         s.events.Load(),
+		EventsFiltered: s.filtered.Load(),
+		EventsInvalid:  s.invalid.Load(),
+		EventLag:       time.Duration(s.lag.Load()),
+		LogLines:       s.logLines.Load(),
+	}
+}
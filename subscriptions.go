@@ -0,0 +1,183 @@
+package esl
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// subscriptions is a reference-counted registry of the event subscriptions
+// and filters requested on the connection.
+//
+// The event commands are sent to the server only when the first reference to
+// the event name appears or the last one disappears, so several subsystems
+// can subscribe to the same events without canceling each other.
+type subscriptions struct {
+	mu      sync.Mutex // held while the commands are sent to keep the server in sync
+	events  map[string]int
+	filters map[EventFilter]int
+}
+
+// newSubscriptions returns a new empty registry.
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		mu:      sync.Mutex{},
+		events:  make(map[string]int),
+		filters: make(map[EventFilter]int),
+	}
+}
+
+// subscribe adds the references to the events and returns the names of the
+// events not subscribed before. The all events are identified by the "all" name.
+func (s *subscriptions) subscribe(names ...string) []string {
+	list := normalizeEventNames(names...)
+	if list == nil {
+		list = []string{eventAll}
+	}
+
+	var added []string
+
+	for _, name := range list {
+		if s.events[name]++; s.events[name] == 1 {
+			added = append(added, name)
+		}
+	}
+
+	return added
+}
+
+// unsubscribe removes the references to the events and returns the names of
+// the events which are no longer referenced.
+func (s *subscriptions) unsubscribe(names ...string) []string {
+	list := normalizeEventNames(names...)
+	if list == nil {
+		list = []string{eventAll}
+	}
+
+	var removed []string
+
+	for _, name := range list {
+		switch count := s.events[name]; count {
+		case 0:
+			continue // not subscribed
+		case 1:
+			delete(s.events, name)
+
+			removed = append(removed, name)
+		default:
+			s.events[name] = count - 1
+		}
+	}
+
+	return removed
+}
+
+// unsubscribeCmds returns the commands to cancel the subscriptions to the
+// removed events, keeping the rest of the subscriptions active.
+func (s *subscriptions) unsubscribeCmds(removed []string) []command {
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if !slices.Contains(removed, eventAll) {
+		if s.events[eventAll] > 0 {
+			return nil // still subscribed to all events
+		}
+
+		return []command{cmd("nixevent", buildEventNamesCmd(removed...))}
+	}
+
+	cmds := []command{cmd("noevents")}
+	if len(s.events) > 0 {
+		cmds = append(cmds, cmd("event", buildEventNamesCmd(s.names()...)))
+	}
+
+	return cmds
+}
+
+// filter adds the reference to the filter and reports whether it is new.
+func (s *subscriptions) filter(f EventFilter) bool {
+	s.filters[f]++
+
+	return s.filters[f] == 1
+}
+
+// filterDelete removes the reference to the filter and reports whether it is
+// no longer referenced. The empty value removes all filters for the header.
+func (s *subscriptions) filterDelete(f EventFilter) bool {
+	if f.Value == "" {
+		var found bool
+
+		for k := range s.filters {
+			if k.Header == f.Header {
+				delete(s.filters, k)
+
+				found = true
+			}
+		}
+
+		return found
+	}
+
+	switch count := s.filters[f]; count {
+	case 0:
+		return false
+	case 1:
+		delete(s.filters, f)
+
+		return true
+	default:
+		s.filters[f] = count - 1
+
+		return false
+	}
+}
+
+// names returns the sorted names of the subscribed events.
+func (s *subscriptions) names() []string {
+	names := make([]string, 0, len(s.events))
+	for name := range s.events {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// filterList returns the sorted list of the filters.
+func (s *subscriptions) filterList() []EventFilter {
+	filters := make([]EventFilter, 0, len(s.filters))
+	for f := range s.filters {
+		filters = append(filters, f)
+	}
+
+	sortFilters(filters)
+
+	return filters
+}
+
+// sortFilters sorts the filters by the header and value.
+func sortFilters(filters []EventFilter) {
+	slices.SortFunc(filters, func(a, b EventFilter) int {
+		return cmp.Or(cmp.Compare(a.Header, b.Header), cmp.Compare(a.Value, b.Value))
+	})
+}
+
+// subscriptionsState is the saved state of the registry.
+type subscriptionsState struct {
+	events  map[string]int
+	filters map[EventFilter]int
+}
+
+// save returns the copy of the registry state, restored by restore when the
+// server commands fail.
+func (s *subscriptions) save() subscriptionsState {
+	return subscriptionsState{events: maps.Clone(s.events), filters: maps.Clone(s.filters)}
+}
+
+// restore restores the registry state returned by save.
+func (s *subscriptions) restore(state subscriptionsState) {
+	s.events, s.filters = state.events, state.filters
+}
@@ -0,0 +1,177 @@
+package esl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildEventNamesCmd(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		// spell-checker:disable
+		{nil, "all"},
+		{[]string{""}, "all"},
+		{[]string{"CHANNEL_ANSWER", "ALL"}, "all"},
+		{[]string{"CHANNEL_ANSWER", "CHANNEL_HANGUP"}, "CHANNEL_ANSWER CHANNEL_HANGUP"},
+		{[]string{"CUSTOM"}, "CUSTOM"},
+		{[]string{"sofia::register", "CHANNEL_ANSWER"}, "CHANNEL_ANSWER CUSTOM sofia::register"},
+		{[]string{"CUSTOM sofia::register", "CUSTOM", "HEARTBEAT"}, "HEARTBEAT CUSTOM sofia::register"},
+		// spell-checker:enable
+	}
+
+	for _, tc := range tests {
+		if got := buildEventNamesCmd(tc.names...); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.names, got, tc.want)
+		}
+	}
+}
+
+func TestClientSubscriptions(t *testing.T) {
+	// commands expected to be sent to the server in order
+	want := []string{
+		"event CHANNEL_ANSWER",
+		"event CUSTOM sofia::register",
+		"event all",
+		"noevents",
+		"event CUSTOM sofia::register",
+		"filter Unique-ID 1234",
+		"filter delete Unique-ID 1234",
+		"filter Unique-ID 1234",
+		"filter Unique-ID 5678",
+		"filter delete Unique-ID",
+	}
+
+	got := make(chan string, len(want))
+
+	client := newFakeClient(t, func(srv *fakeServer) {
+		for range want {
+			got <- srv.recv()
+			srv.reply("+OK")
+		}
+	})
+
+	steps := []struct {
+		name string
+		fn   func() error
+		subs []string
+	}{
+		{"subscribe", func() error { return client.Subscribe("CHANNEL_ANSWER") }, []string{"CHANNEL_ANSWER"}},
+		{"subscribe again", func() error { return client.Subscribe("CHANNEL_ANSWER") }, []string{"CHANNEL_ANSWER"}},
+		{
+			"subscribe custom", func() error { return client.Subscribe("CUSTOM sofia::register", "CHANNEL_ANSWER") },
+			[]string{"CHANNEL_ANSWER", "sofia::register"},
+		},
+		{
+			"subscribe all", func() error { return client.Subscribe() },
+			[]string{"CHANNEL_ANSWER", "all", "sofia::register"},
+		},
+		{
+			"unsubscribe once", func() error { return client.Unsubscribe("CHANNEL_ANSWER") },
+			[]string{"CHANNEL_ANSWER", "all", "sofia::register"},
+		},
+		{
+			"unsubscribe twice", func() error { return client.Unsubscribe("CHANNEL_ANSWER") },
+			[]string{"CHANNEL_ANSWER", "all", "sofia::register"},
+		},
+		{
+			"unsubscribe last", func() error { return client.Unsubscribe("CHANNEL_ANSWER") },
+			[]string{"all", "sofia::register"},
+		},
+		{
+			"unsubscribe all", func() error { return client.Unsubscribe("all") },
+			[]string{"sofia::register"},
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if subs := client.Subscriptions(); !slices.Equal(subs, step.subs) {
+			t.Errorf("%s: subscriptions %q, want %q", step.name, subs, step.subs)
+		}
+	}
+
+	for range 2 {
+		if err := client.Filter("Unique-ID", "1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if filters := client.Filters(); !slices.Equal(filters, []EventFilter{{"Unique-ID", "1234"}}) {
+		t.Errorf("unexpected filters: %v", filters)
+	}
+
+	for range 2 {
+		if err := client.FilterDelete("Unique-ID", "1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if filters := client.Filters(); len(filters) != 0 {
+		t.Errorf("unexpected filters: %v", filters)
+	}
+
+	for _, value := range []string{"1234", "1234", "5678"} {
+		if err := client.Filter("Unique-ID", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the empty value deletes all filters of the header with all references
+	if err := client.FilterDelete("Unique-ID", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if filters := client.Filters(); len(filters) != 0 {
+		t.Errorf("unexpected filters after delete all: %v", filters)
+	}
+
+	close(got)
+
+	var sent []string
+	for cmd := range got {
+		sent = append(sent, cmd)
+	}
+
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent commands:\n%q\nwant:\n%q", sent, want)
+	}
+}
+
+func TestClientSubscriptionsRollback(t *testing.T) {
+	client := newFakeClient(t, func(srv *fakeServer) {
+		for _, reply := range []string{"+OK", "+OK", "-ERR failed", "-ERR failed"} {
+			srv.recv()
+			srv.reply(reply)
+		}
+	})
+
+	if err := client.Subscribe("CHANNEL_ANSWER"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Filter("Unique-ID", "1234"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Unsubscribe("CHANNEL_ANSWER"); err == nil {
+		t.Error("unsubscribe: expected error")
+	}
+
+	if err := client.FilterDelete("Unique-ID", "1234"); err == nil {
+		t.Error("filter delete: expected error")
+	}
+
+	// the failed commands keep the references
+	if subs := client.Subscriptions(); !slices.Equal(subs, []string{"CHANNEL_ANSWER"}) {
+		t.Errorf("unexpected subscriptions: %q", subs)
+	}
+
+	if filters := client.Filters(); !slices.Equal(filters, []EventFilter{{"Unique-ID", "1234"}}) {
+		t.Errorf("unexpected filters: %v", filters)
+	}
+}
@@ -0,0 +1,176 @@
+// Package telemetry maps the client hooks to the spans and metrics in the
+// OpenTelemetry style.
+//
+// The package has no dependencies: the tracer and meter are the small
+// interfaces, which are easily implemented over the OpenTelemetry SDK or any
+// other tracing and metrics library. The attributes are passed as slog.Attr.
+//
+//	hooks := telemetry.Hooks(tracer, meter)
+//	client, err := esl.Connect(addr, password, esl.WithHooks(hooks))
+//	...
+//	// the span of the command is the child of the span in ctx
+//	result, err := client.APIContext(ctx, "status")
+//
+// The command spans are named "esl <command>", e.g. "esl api". The metrics are:
+//
+//   - esl.client.dial.duration (histogram, seconds)
+//   - esl.client.auth.duration (histogram, seconds)
+//   - esl.client.commands (counter)
+//   - esl.client.command.duration (histogram, seconds)
+//   - esl.client.events (counter)
+//   - esl.client.events.dropped (counter)
+//   - esl.client.disconnects (counter)
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mdigger/esl"
+)
+
+// Tracer starts the spans, like trace.Tracer of OpenTelemetry.
+type Tracer interface {
+	// Start starts the span as the child of the span in the context, if any,
+	// and returns the context with the new span.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is the traced operation.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// Meter creates the metric instruments, like metric.Meter of OpenTelemetry.
+type Meter interface {
+	Counter(name, unit, description string) Counter
+	Histogram(name, unit, description string) Histogram
+}
+
+// Counter is the monotonic counter.
+type Counter interface {
+	Add(ctx context.Context, n int64, attrs ...slog.Attr)
+}
+
+// Histogram records the distribution of the values.
+type Histogram interface {
+	Record(ctx context.Context, v float64, attrs ...slog.Attr)
+}
+
+// spanKey is the context key of the command span.
+type spanKey struct{}
+
+// instruments are the metric instruments used by the hooks.
+type instruments struct {
+	dialDuration    Histogram
+	authDuration    Histogram
+	commands        Counter
+	commandDuration Histogram
+	events          Counter
+	eventsDropped   Counter
+	disconnects     Counter
+}
+
+// Hooks returns the client hooks starting the span for each command and
+// recording the metrics. Either tracer or meter may be nil.
+func Hooks(tracer Tracer, meter Meter) esl.Hooks {
+	var hooks esl.Hooks //nolint:exhaustruct // filled below
+
+	if tracer != nil {
+		hooks.CommandSent = func(ctx context.Context, cmd esl.Command) context.Context {
+			ctx, span := tracer.Start(ctx, "esl "+cmd.Name, commandAttrs(cmd)...)
+
+			return context.WithValue(ctx, spanKey{}, span)
+		}
+	}
+
+	var m *instruments
+
+	if meter != nil {
+		m = &instruments{
+			dialDuration: meter.Histogram("esl.client.dial.duration", "s",
+				"Duration of dialing the server."),
+			authDuration: meter.Histogram("esl.client.auth.duration", "s",
+				"Duration of the authentication."),
+			commands: meter.Counter("esl.client.commands", "{command}",
+				"Number of commands sent to the server."),
+			commandDuration: meter.Histogram("esl.client.command.duration", "s",
+				"Latency of the command replies."),
+			events: meter.Counter("esl.client.events", "{event}",
+				"Number of received events."),
+			eventsDropped: meter.Counter("esl.client.events.dropped", "{event}",
+				"Number of events not delivered to the application."),
+			disconnects: meter.Counter("esl.client.disconnects", "{disconnect}",
+				"Number of closed connections."),
+		}
+
+		hooks.Dial = func(_ string, d time.Duration, err error) {
+			m.dialDuration.Record(context.Background(), d.Seconds(), resultAttr(err))
+		}
+		hooks.Auth = func(d time.Duration, err error) {
+			m.authDuration.Record(context.Background(), d.Seconds(), resultAttr(err))
+		}
+		hooks.EventReceived = func(e esl.Event) {
+			m.events.Add(context.Background(), 1, slog.String("esl.event.name", e.Name()))
+		}
+		hooks.EventDropped = func(reason string) {
+			m.eventsDropped.Add(context.Background(), 1, slog.String("esl.drop.reason", reason))
+		}
+		hooks.Disconnect = func(error) {
+			m.disconnects.Add(context.Background(), 1)
+		}
+	}
+
+	if tracer != nil || meter != nil {
+		hooks.ReplyReceived = func(ctx context.Context, cmd esl.Command, _ esl.Response,
+			latency time.Duration, err error,
+		) {
+			if span, ok := ctx.Value(spanKey{}).(Span); ok {
+				if err != nil {
+					span.RecordError(err)
+				}
+
+				span.SetAttributes(resultAttr(err))
+				span.End()
+			}
+
+			if m != nil {
+				name := slog.String("esl.command.name", cmd.Name)
+				m.commands.Add(ctx, 1, name, resultAttr(err))
+				m.commandDuration.Record(ctx, latency.Seconds(), name, resultAttr(err))
+			}
+		}
+	}
+
+	return hooks
+}
+
+// commandAttrs returns the span attributes of the command. The API command
+// name is added without the arguments, which may contain the sensitive data.
+func commandAttrs(cmd esl.Command) []slog.Attr {
+	attrs := []slog.Attr{slog.String("esl.command.name", cmd.Name)}
+
+	if cmd.Name == "api" || cmd.Name == "bgapi" {
+		api, _, _ := strings.Cut(cmd.Params, " ")
+		attrs = append(attrs, slog.String("esl.api.command", api))
+	}
+
+	if cmd.JobUUID != "" {
+		attrs = append(attrs, slog.String("esl.job.uuid", cmd.JobUUID))
+	}
+
+	return attrs
+}
+
+// resultAttr returns the attribute of the operation result.
+func resultAttr(err error) slog.Attr {
+	if err != nil {
+		return slog.String("esl.result", "error")
+	}
+
+	return slog.String("esl.result", "ok")
+}
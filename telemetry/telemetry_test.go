@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mdigger/esl"
+)
+
+// recorder implements the tracer and meter, recording the calls.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(s string, attrs ...slog.Attr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, a := range attrs {
+		s += " " + a.String()
+	}
+
+	r.calls = append(r.calls, s)
+}
+
+type spanCtxKey struct{}
+
+type span struct {
+	r    *recorder
+	name string
+}
+
+func (r *recorder) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	parent, _ := ctx.Value(spanCtxKey{}).(string)
+	r.record("start "+name+" parent="+parent, attrs...)
+
+	return context.WithValue(ctx, spanCtxKey{}, name), &span{r: r, name: name}
+}
+
+func (s *span) SetAttributes(attrs ...slog.Attr) { s.r.record("attrs "+s.name, attrs...) }
+func (s *span) RecordError(err error)            { s.r.record("error " + s.name + " " + err.Error()) }
+func (s *span) End()                             { s.r.record("end " + s.name) }
+
+type instrument struct {
+	r    *recorder
+	name string
+}
+
+func (r *recorder) Counter(name, _, _ string) Counter     { return &instrument{r: r, name: name} }
+func (r *recorder) Histogram(name, _, _ string) Histogram { return &instrument{r: r, name: name} }
+
+func (i *instrument) Add(_ context.Context, n int64, attrs ...slog.Attr) {
+	i.r.record("add "+i.name, attrs...)
+}
+
+func (i *instrument) Record(_ context.Context, _ float64, attrs ...slog.Attr) {
+	i.r.record("record "+i.name, attrs...)
+}
+
+func TestHooks(t *testing.T) {
+	client, server := net.Pipe()
+
+	go func() {
+		r := bufio.NewReader(server)
+		recv := func() {
+			for line, err := r.ReadString('\n'); err == nil && line != "\n"; line, err = r.ReadString('\n') {
+			}
+		}
+
+		io.WriteString(server, "Content-Type: auth/request\n\n") //nolint:errcheck
+		recv()
+		io.WriteString(server, "Content-Type: command/reply\nReply-Text: +OK accepted\n\n") //nolint:errcheck
+		recv()
+		io.WriteString(server, "Content-Type: api/response\nContent-Length: 2\n\nUP") //nolint:errcheck
+		recv()
+		server.Close()
+	}()
+
+	var rec recorder
+
+	c, err := esl.NewClient(client, "ClueCon", esl.WithHooks(Hooks(&rec, &rec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), spanCtxKey{}, "request")
+
+	if _, err := c.APIContext(ctx, "status"); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	<-c.Done()
+
+	rec.mu.Lock()
+	got := strings.Join(rec.calls, "\n")
+	rec.mu.Unlock()
+
+	for _, want := range []string{
+		"record esl.client.auth.duration esl.result=ok",
+		"start esl api parent=request esl.command.name=api esl.api.command=status",
+		"attrs esl api esl.result=ok\nend esl api",
+		"add esl.client.commands esl.command.name=api esl.result=ok",
+		"record esl.client.command.duration esl.command.name=api esl.result=ok",
+		"add esl.client.disconnects",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -50,3 +50,83 @@ func trimLeft(b []byte) string {
 
 	return ""
 }
+
+// urlUnsafe contains the printable characters FreeSWITCH url-encodes in the
+// header values of the events, in addition to the control and non-ASCII ones.
+const urlUnsafe = "\r\n \"#%&+:;<=>?@[\\]^`{|}"
+
+// urlEncode encodes the value the same way as FreeSWITCH does when it
+// serializes the event headers.
+func urlEncode(s string) string {
+	var n int
+
+	for i := range len(s) {
+		if shouldEscape(s[i]) {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return s // nothing to encode
+	}
+
+	const hex = "0123456789ABCDEF"
+
+	buf := make([]byte, 0, len(s)+2*n)
+	for i := range len(s) {
+		if c := s[i]; shouldEscape(c) {
+			buf = append(buf, '%', hex[c>>4], hex[c&0x0f])
+		} else {
+			buf = append(buf, c)
+		}
+	}
+
+	return string(buf)
+}
+
+// shouldEscape reports whether the character must be url-encoded.
+func shouldEscape(c byte) bool {
+	return c < ' ' || c > '~' || strings.IndexByte(urlUnsafe, c) >= 0
+}
+
+// urlDecode decodes the url-encoded value. Unlike url.PathUnescape, it keeps
+// malformed escape sequences as is instead of failing, just like FreeSWITCH.
+func urlDecode(s string) string {
+	idx := strings.IndexByte(s, '%')
+	if idx < 0 {
+		return s // nothing to decode
+	}
+
+	buf := make([]byte, 0, len(s))
+	buf = append(buf, s[:idx]...)
+
+	for i := idx; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			buf = append(buf, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+
+			continue
+		}
+
+		buf = append(buf, s[i])
+	}
+
+	return string(buf)
+}
+
+// isHex reports whether the character is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of the hexadecimal digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}